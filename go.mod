@@ -3,7 +3,10 @@ module github.com/bigkevmcd/askja
 go 1.16
 
 require (
+	github.com/Masterminds/semver/v3 v3.1.1
 	github.com/fluxcd/helm-controller/api v0.9.0
+	github.com/fluxcd/kustomize-controller/api v0.10.0
+	github.com/fluxcd/pkg/runtime v0.10.1
 	github.com/fluxcd/source-controller/api v0.10.0
 	github.com/go-git/go-billy/v5 v5.1.0
 	github.com/go-git/go-git/v5 v5.3.0
@@ -12,7 +15,9 @@ require (
 	github.com/spf13/viper v1.7.1
 	github.com/weaveworks/profiles v0.0.0-20210330083943-94d298f39a05
 	gopkg.in/h2non/gock.v1 v1.0.16
+	gopkg.in/yaml.v3 v3.0.1
 	k8s.io/api v0.20.5
+	k8s.io/apiextensions-apiserver v0.20.2
 	k8s.io/apimachinery v0.20.5
 	sigs.k8s.io/yaml v1.2.0
 )
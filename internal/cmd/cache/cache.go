@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bigkevmcd/askja/pkg/operations"
+)
+
+func MakeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "manage the cache of fetched profile files",
+	}
+
+	cmd.AddCommand(makePruneCmd())
+	return cmd
+}
+
+func makePruneCmd() *cobra.Command {
+	const (
+		cacheDirParam  = "cache-dir"
+		olderThanParam = "older-than"
+	)
+	var (
+		cacheDir  string
+		olderThan time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "remove cached profile files",
+		Run: func(cmd *cobra.Command, args []string) {
+			if cacheDir == "" {
+				dir, err := operations.DefaultCacheDir()
+				if err != nil {
+					log.Fatal(err)
+				}
+				cacheDir = dir
+			}
+			removed, err := operations.PruneCache(cacheDir, time.Now().Add(-olderThan))
+			if err != nil {
+				log.Fatalf("failed to prune the cache: %s", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "removed %d cached files from %s\n", removed, cacheDir)
+		},
+	}
+
+	cmd.Flags().StringVar(
+		&cacheDir,
+		cacheDirParam,
+		"",
+		"directory that profile files are cached in, defaults to the askja directory in the user cache directory",
+	)
+
+	cmd.Flags().DurationVar(
+		&olderThan,
+		olderThanParam,
+		0,
+		"only remove files cached longer ago than this e.g. 168h, by default all files are removed",
+	)
+	return cmd
+}
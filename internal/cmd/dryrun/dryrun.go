@@ -0,0 +1,66 @@
+package dryrun
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/bigkevmcd/askja/pkg/operations"
+)
+
+const (
+	dryRunParam = "dry-run"
+	outputParam = "output"
+)
+
+// Options are the fields that the dry-run flags are bound to.
+type Options struct {
+	DryRun bool
+	Output string
+}
+
+// AddFlags adds the flags for printing the generated resources rather than
+// committing them to the command.
+func AddFlags(cmd *cobra.Command, opts *Options) {
+	cmd.Flags().BoolVar(
+		&opts.DryRun,
+		dryRunParam,
+		false,
+		"print the generated resources rather than writing them to a new branch",
+	)
+
+	cmd.Flags().StringVar(
+		&opts.Output,
+		outputParam,
+		string(operations.YAMLOutput),
+		"format for printing the resources with --dry-run, one of yaml or json",
+	)
+}
+
+// Validate returns an error if the output format is unknown, or if the flags
+// that are required unless this is a dry-run are not set.
+func (o *Options) Validate(cmd *cobra.Command, names ...string) error {
+	if _, err := operations.ParseOutputFormat(o.Output); err != nil {
+		return err
+	}
+	if o.DryRun {
+		return nil
+	}
+	for _, name := range names {
+		if !cmd.Flags().Changed(name) {
+			return fmt.Errorf("required flag %q not set", name)
+		}
+	}
+	return nil
+}
+
+// Print writes the resources to stdout in the output format.
+func (o *Options) Print(objs []runtime.Object) error {
+	format, err := operations.ParseOutputFormat(o.Output)
+	if err != nil {
+		return err
+	}
+	return operations.WriteResources(os.Stdout, objs, format)
+}
@@ -1,9 +1,18 @@
 package helm
 
 import (
+	"context"
+	"log"
+	"os"
+
 	"github.com/spf13/cobra"
 
+	"github.com/bigkevmcd/askja/internal/cmd/dryrun"
+	"github.com/bigkevmcd/askja/internal/cmd/namespaces"
+	"github.com/bigkevmcd/askja/internal/cmd/values"
+	"github.com/bigkevmcd/askja/pkg/operations"
 	"github.com/bigkevmcd/askja/pkg/operations/helm"
+	"github.com/bigkevmcd/askja/pkg/profiles"
 )
 
 func MakeCmd() *cobra.Command {
@@ -18,17 +27,53 @@ func MakeCmd() *cobra.Command {
 
 func makeHelmInstallCmd() *cobra.Command {
 	var opts helm.InstallOptions
+	var valuesOpts operations.ValuesOptions
+	var dryRunOpts dryrun.Options
+	var layout string
 	const (
 		repositoryURLParam = "repository-url"
 		chartNameParam     = "chart"
 		chartVersionParam  = "version"
 		profileParam       = "profile"
+		newBranchParam     = "new-branch"
+		namePrefixParam    = "name-prefix"
+		outputDirParam     = "output-dir"
+		layoutParam        = "layout"
 	)
 
 	cmd := &cobra.Command{
 		Use:   "install",
 		Short: "add a helm chart to a profile",
 		Run: func(cmd *cobra.Command, args []string) {
+			if err := dryRunOpts.Validate(cmd, newBranchParam); err != nil {
+				log.Fatal(err)
+			}
+			values, err := valuesOpts.Build()
+			if err != nil {
+				log.Fatal(err)
+			}
+			opts.Values = values
+			opts.Layout, err = operations.ParseLayout(layout)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if dryRunOpts.DryRun {
+				objs, err := helm.Generate(context.Background(), &opts)
+				if err != nil {
+					log.Fatalf("failed to generate the helm chart resources: %s", err)
+				}
+				if err := dryRunOpts.Print(objs); err != nil {
+					log.Fatal(err)
+				}
+				return
+			}
+			cwd, err := os.Getwd()
+			if err != nil {
+				log.Fatalf("failed to get the working directory: %s", err)
+			}
+			if err := helm.Install(context.Background(), cwd, &opts); err != nil {
+				log.Fatalf("failed to install the helm chart: %s", err)
+			}
 		},
 	}
 
@@ -36,7 +81,7 @@ func makeHelmInstallCmd() *cobra.Command {
 		&opts.Chart.URL,
 		repositoryURLParam,
 		"",
-		"the chart repository URL e.g. https://charts.bitnami.com/bitnami",
+		"the chart repository URL e.g. https://charts.bitnami.com/bitnami or oci://ghcr.io/stefanprodan/charts",
 	)
 	cmd.MarkFlagRequired(repositoryURLParam)
 
@@ -52,7 +97,7 @@ func makeHelmInstallCmd() *cobra.Command {
 		&opts.Chart.Version,
 		chartVersionParam,
 		"",
-		"the chart version to install e.g. v1.19.0",
+		"the chart version to install e.g. 1.19.0, or a semver range e.g. \"~1.19\"",
 	)
 	cmd.MarkFlagRequired(chartVersionParam)
 
@@ -63,5 +108,42 @@ func makeHelmInstallCmd() *cobra.Command {
 		"profile to install in e.g. demo, this will modify the profile in the profile directory relative to the current dir",
 	)
 	cmd.MarkFlagRequired(profileParam)
+
+	cmd.Flags().StringVar(
+		&opts.NewBranchName,
+		newBranchParam,
+		"",
+		"new branch name to apply changes to e.g. test-branch, required unless --dry-run is set",
+	)
+
+	cmd.Flags().StringVar(
+		&opts.NamePrefix,
+		namePrefixParam,
+		profiles.DefaultNamePrefix,
+		"prefix for the names of the generated resources",
+	)
+
+	cmd.Flags().StringVar(
+		&opts.OutputDir,
+		outputDirParam,
+		"",
+		"directory in the repository to write the generated resources to, defaults to the profile directory",
+	)
+
+	cmd.Flags().StringVar(
+		&layout,
+		layoutParam,
+		"",
+		"how the generated resources are laid out in the output directory, one of flat, per-profile or per-kind, defaults to flat",
+	)
+
+	namespaces.AddFlags(cmd, &namespaces.Options{
+		Namespace:       &opts.Namespace,
+		TargetNamespace: &opts.TargetNamespace,
+		SourceNamespace: &opts.SourceNamespace,
+		CreateNamespace: &opts.CreateNamespace,
+	})
+	values.AddFlags(cmd, &valuesOpts)
+	dryrun.AddFlags(cmd, &dryRunOpts)
 	return cmd
 }
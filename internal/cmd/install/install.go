@@ -5,29 +5,76 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 
+	"github.com/bigkevmcd/askja/internal/cmd/dryrun"
+	"github.com/bigkevmcd/askja/internal/cmd/namespaces"
+	"github.com/bigkevmcd/askja/internal/cmd/values"
 	"github.com/bigkevmcd/askja/pkg/operations"
 	"github.com/bigkevmcd/askja/pkg/profiles"
 )
 
 const (
-	profileURLParam    = "profile-url"
-	profileBranchParam = "profile-branch"
-	newBranchParam     = "new-branch"
+	profileURLParam     = "profile-url"
+	profileBranchParam  = "profile-branch"
+	profileTagParam     = "profile-tag"
+	profileCommitParam  = "profile-commit"
+	profileVersionParam = "profile-version"
+	newBranchParam      = "new-branch"
+	gitHostParam        = "git-host"
+	gitCloneParam       = "git-clone"
+	sshKeyFileParam     = "ssh-key-file"
+	offlineParam        = "offline"
+	cacheDirParam       = "cache-dir"
+	timeoutParam        = "timeout"
+	paramParam          = "param"
+	namePrefixParam     = "name-prefix"
+	outputDirParam      = "output-dir"
+	layoutParam         = "layout"
 )
 
 func MakeCmd() *cobra.Command {
 	opts := &operations.InstallOptions{
 		ProfileOptions: &profiles.ProfileOptions{},
 	}
+	var gitHosts map[string]string
+	var gitClone bool
+	cloneOpts := operations.GitCloneOptions{Credentials: operations.DefaultCredentials()}
+	var cacheOpts operations.CacheOptions
+	var timeout time.Duration
+	var valuesOpts operations.ValuesOptions
+	var layout string
+	var dryRunOpts dryrun.Options
 
 	cmd := &cobra.Command{
 		Use:   "install",
 		Short: "install a WeaveWorks profile",
 		Run: func(cmd *cobra.Command, args []string) {
-			if err := generateProfileResources(opts); err != nil {
+			if err := dryRunOpts.Validate(cmd, newBranchParam); err != nil {
+				log.Fatal(err)
+			}
+			if err := addGitHosts(gitHosts); err != nil {
+				log.Fatal(err)
+			}
+			values, err := valuesOpts.Build()
+			if err != nil {
+				log.Fatal(err)
+			}
+			opts.ProfileOptions.Values = values
+			opts.Layout, err = operations.ParseLayout(layout)
+			if err != nil {
+				log.Fatal(err)
+			}
+			configureGitClone(gitClone, cloneOpts)
+			if err := configureCache(cacheOpts); err != nil {
+				log.Fatal(err)
+			}
+			if err := generateProfileResources(opts, &dryRunOpts, timeout); err != nil {
+				if operations.IsNotFoundOrUnauthorised(err) {
+					log.Fatalf("profile not found or not authorised: %s", opts.ProfileOptions.ProfileURL)
+				}
 				log.Fatalf("failed to generate profile resources: %s", err)
 			}
 		},
@@ -44,24 +91,164 @@ func MakeCmd() *cobra.Command {
 	cmd.Flags().StringVar(
 		&opts.ProfileOptions.Branch,
 		profileBranchParam,
-		"main",
-		"branch name within the profile repo to fetch",
+		"",
+		"branch name within the profile repo to fetch, defaults to main if no tag, commit or version is provided",
+	)
+
+	cmd.Flags().StringVar(
+		&opts.ProfileOptions.Tag,
+		profileTagParam,
+		"",
+		"tag within the profile repo to fetch e.g. v0.1.0, this takes precedence over the branch",
+	)
+
+	cmd.Flags().StringVar(
+		&opts.ProfileOptions.Commit,
+		profileCommitParam,
+		"",
+		"commit SHA within the profile repo to fetch, this takes precedence over the tag and branch",
+	)
+
+	cmd.Flags().StringVar(
+		&opts.ProfileOptions.SemVer,
+		profileVersionParam,
+		"",
+		"semver range to resolve against the tags in the profile repo e.g. \">=1.2.0 <2.0.0\", this takes precedence over the tag and branch",
 	)
 
 	cmd.Flags().StringVar(
 		&opts.NewBranchName,
 		newBranchParam,
 		"",
-		"new branch name to apply changes to e.g. test-branch",
+		"new branch name to apply changes to e.g. test-branch, required unless --dry-run is set",
+	)
+
+	cmd.Flags().StringToStringVar(
+		&gitHosts,
+		gitHostParam,
+		nil,
+		"map self-hosted git servers to a driver e.g. gitlab.example.com=gitlab, drivers are github, gitlab, bitbucket-server and gitea",
+	)
+
+	cmd.Flags().BoolVar(
+		&gitClone,
+		gitCloneParam,
+		false,
+		"clone the profile repo rather than using the git host's API, this also verifies that artifact paths exist",
+	)
+
+	cmd.Flags().StringVar(
+		&cloneOpts.SSHKeyFile,
+		sshKeyFileParam,
+		"",
+		"private key to use when cloning profiles over SSH, the SSH agent is used if this is not provided",
+	)
+
+	cmd.Flags().BoolVar(
+		&cacheOpts.Offline,
+		offlineParam,
+		false,
+		"only use profile files that have already been cached",
+	)
+
+	cmd.Flags().StringVar(
+		&cacheOpts.Dir,
+		cacheDirParam,
+		"",
+		"directory to cache fetched profile files in, defaults to the askja directory in the user cache directory",
+	)
+
+	cmd.Flags().DurationVar(
+		&timeout,
+		timeoutParam,
+		5*time.Minute,
+		"maximum time to spend fetching the profile, including retries",
+	)
+
+	cmd.Flags().StringToStringVar(
+		&opts.ProfileOptions.Params,
+		paramParam,
+		nil,
+		"value for a parameter declared in the profile e.g. replicas=3, can be repeated",
 	)
-	cmd.MarkFlagRequired(newBranchParam)
+
+	cmd.Flags().StringVar(
+		&opts.ProfileOptions.NamePrefix,
+		namePrefixParam,
+		profiles.DefaultNamePrefix,
+		"prefix for the names of the generated resources, use a different prefix to install profiles whose resources have the same names",
+	)
+
+	cmd.Flags().StringVar(
+		&opts.OutputDir,
+		outputDirParam,
+		"",
+		"directory in the repository to write the manifests to, defaults to the directory the profile was last installed to, or the root of the repository",
+	)
+
+	cmd.Flags().StringVar(
+		&layout,
+		layoutParam,
+		"",
+		"how the manifests are laid out in the output directory, one of flat, per-profile, per-kind or single, defaults to the layout the profile was last installed with, or flat",
+	)
+
+	namespaces.AddFlags(cmd, &namespaces.Options{
+		Namespace:       &opts.ProfileOptions.Namespace,
+		TargetNamespace: &opts.ProfileOptions.TargetNamespace,
+		SourceNamespace: &opts.ProfileOptions.SourceNamespace,
+		CreateNamespace: &opts.ProfileOptions.CreateNamespace,
+	})
+	values.AddFlags(cmd, &valuesOpts)
+	dryrun.AddFlags(cmd, &dryRunOpts)
 	return cmd
 }
 
-func generateProfileResources(opts *operations.InstallOptions) error {
+// configureCache wraps the client factory so that fetched files are cached.
+func configureCache(opts operations.CacheOptions) error {
+	if opts.Dir == "" {
+		dir, err := operations.DefaultCacheDir()
+		if err != nil {
+			return err
+		}
+		opts.Dir = dir
+	}
+	operations.DefaultClientFactory = operations.NewCachingClientFactory(operations.DefaultClientFactory, opts)
+	return nil
+}
+
+// configureGitClone sets up cloning for repositories on hosts without a
+// driver, and for all repositories if forced.
+func configureGitClone(force bool, opts operations.GitCloneOptions) {
+	factory := operations.NewGitCloneClientFactory(opts)
+	operations.DefaultClientRegistry.Fallback = factory
+	if force {
+		operations.DefaultClientFactory = factory
+	}
+}
+
+func addGitHosts(hosts map[string]string) error {
+	for host, driver := range hosts {
+		if err := operations.DefaultClientRegistry.AddHost(host, driver); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func generateProfileResources(opts *operations.InstallOptions, dryRunOpts *dryrun.Options, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if dryRunOpts.DryRun {
+		objs, err := operations.GenerateProfile(ctx, opts.ProfileOptions)
+		if err != nil {
+			return err
+		}
+		return dryRunOpts.Print(objs)
+	}
 	cwd, err := os.Getwd()
 	if err != nil {
 		return fmt.Errorf("failed to get the working directory: %w", err)
 	}
-	return operations.InstallProfile(context.TODO(), cwd, opts)
+	return operations.InstallProfile(ctx, cwd, opts)
 }
@@ -0,0 +1,52 @@
+package namespaces
+
+import (
+	"github.com/spf13/cobra"
+)
+
+const (
+	namespaceParam       = "namespace"
+	targetNamespaceParam = "target-namespace"
+	sourceNamespaceParam = "source-namespace"
+	createNamespaceParam = "create-namespace"
+)
+
+// Options are the fields that the namespace flags are bound to.
+type Options struct {
+	Namespace       *string
+	TargetNamespace *string
+	SourceNamespace *string
+	CreateNamespace *bool
+}
+
+// AddFlags adds the flags for the namespaces of the generated resources to
+// the command.
+func AddFlags(cmd *cobra.Command, opts *Options) {
+	cmd.Flags().StringVar(
+		opts.Namespace,
+		namespaceParam,
+		"",
+		"namespace for the generated HelmReleases and Kustomizations e.g. apps",
+	)
+
+	cmd.Flags().StringVar(
+		opts.TargetNamespace,
+		targetNamespaceParam,
+		"",
+		"namespace that the artifacts are deployed to, unless the artifact has a namespace",
+	)
+
+	cmd.Flags().StringVar(
+		opts.SourceNamespace,
+		sourceNamespaceParam,
+		"",
+		"namespace for the generated GitRepositories and HelmRepositories e.g. flux-system, defaults to the namespace",
+	)
+
+	cmd.Flags().BoolVar(
+		opts.CreateNamespace,
+		createNamespaceParam,
+		false,
+		"generate Namespace resources for the namespace and the target namespaces",
+	)
+}
@@ -0,0 +1,216 @@
+package profile
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bigkevmcd/askja/pkg/operations"
+	"github.com/bigkevmcd/askja/pkg/profiles"
+)
+
+const profileFilename = "profile.yaml"
+
+func MakeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "profile",
+		Short: "profile authoring operations",
+	}
+
+	cmd.AddCommand(makeLintCmd())
+	cmd.AddCommand(makeConvertCmd())
+	return cmd
+}
+
+func makeLintCmd() *cobra.Command {
+	opts := &profiles.ProfileOptions{}
+	const (
+		branchParam  = "branch"
+		tagParam     = "tag"
+		commitParam  = "commit"
+		timeoutParam = "timeout"
+	)
+	var timeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "lint [path|url]",
+		Short: "check a profile.yaml for problems",
+		Long:  "check a profile.yaml in a local directory or file, or in a profile repo, for problems, this exits with a non-zero code if any are found",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			source := "."
+			if len(args) == 1 {
+				source = args[0]
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			name, b, err := readProfile(ctx, source, opts)
+			if err != nil {
+				log.Fatalf("failed to read the profile: %s", err)
+			}
+			if !lintProfile(os.Stdout, name, b) {
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(
+		&opts.Branch,
+		branchParam,
+		"main",
+		"branch name within the profile repo to fetch, when linting a URL",
+	)
+
+	cmd.Flags().StringVar(
+		&opts.Tag,
+		tagParam,
+		"",
+		"tag within the profile repo to fetch, when linting a URL, this takes precedence over the branch",
+	)
+
+	cmd.Flags().StringVar(
+		&opts.Commit,
+		commitParam,
+		"",
+		"commit SHA within the profile repo to fetch, when linting a URL, this takes precedence over the tag and branch",
+	)
+
+	cmd.Flags().DurationVar(
+		&timeout,
+		timeoutParam,
+		time.Minute,
+		"maximum time to spend fetching the profile, including retries",
+	)
+	return cmd
+}
+
+// readProfile reads the profile.yaml from a local file or directory, or from
+// a profile repo URL, and returns a name to report problems with.
+func readProfile(ctx context.Context, source string, opts *profiles.ProfileOptions) (string, []byte, error) {
+	if isRemote(source) {
+		opts.ProfileURL = source
+		b, err := operations.FetchProfile(ctx, opts)
+		if err != nil {
+			return "", nil, err
+		}
+		return source + "@" + opts.Ref() + ":" + profileFilename, b, nil
+	}
+	return readLocalProfile(source)
+}
+
+// readLocalProfile reads the profile.yaml from a file, or from the
+// profile.yaml in a directory, and returns the path that was read.
+func readLocalProfile(source string) (string, []byte, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return "", nil, err
+	}
+	if info.IsDir() {
+		source = filepath.Join(source, profileFilename)
+	}
+	b, err := os.ReadFile(source)
+	return source, b, err
+}
+
+// lintProfile writes the problems with the profile to out, and returns true
+// if there were no problems.
+func lintProfile(out io.Writer, name string, b []byte) bool {
+	_, problems := profiles.Lint(b)
+	for _, p := range problems {
+		location := name
+		if p.Line > 0 {
+			location = fmt.Sprintf("%s:%d", name, p.Line)
+		}
+		if p.Field != "" {
+			fmt.Fprintf(out, "%s: %s: %s\n", location, p.Field, p.Message)
+			continue
+		}
+		fmt.Fprintf(out, "%s: %s\n", location, p.Message)
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(out, "%d problem(s) found\n", len(problems))
+		return false
+	}
+	return true
+}
+
+func isRemote(source string) bool {
+	return strings.Contains(source, "://") || strings.HasPrefix(source, "git@")
+}
+
+func makeConvertCmd() *cobra.Command {
+	const (
+		toParam     = "to"
+		outputParam = "output"
+	)
+	var to, output string
+
+	cmd := &cobra.Command{
+		Use:   "convert [path]",
+		Short: "rewrite a profile.yaml in another version of the profile API",
+		Long:  "rewrite a profile.yaml in a local directory or file in another version of the profile API, comments in the profile.yaml are not preserved",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			source := "."
+			if len(args) == 1 {
+				source = args[0]
+			}
+			if err := convertProfile(source, to, output); err != nil {
+				log.Fatalf("failed to convert the profile: %s", err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(
+		&to,
+		toParam,
+		profiles.LatestAPIVersion,
+		"version of the profile API to convert to e.g. v1alpha2",
+	)
+
+	cmd.Flags().StringVar(
+		&output,
+		outputParam,
+		"",
+		"file to write the converted profile to, use - for stdout, defaults to overwriting the profile",
+	)
+	return cmd
+}
+
+func convertProfile(source, to, output string) error {
+	apiVersion, err := profiles.ResolveAPIVersion(to)
+	if err != nil {
+		return err
+	}
+	if isRemote(source) {
+		return fmt.Errorf("only local profiles can be converted, %q is a URL", source)
+	}
+	name, b, err := readLocalProfile(source)
+	if err != nil {
+		return err
+	}
+	p, err := profiles.ParseBytes(b)
+	if err != nil {
+		return err
+	}
+	p.APIVersion = apiVersion
+	converted, err := profiles.Marshal(p)
+	if err != nil {
+		return err
+	}
+	switch output {
+	case "-":
+		_, err = os.Stdout.Write(converted)
+		return err
+	case "":
+		output = name
+	}
+	return os.WriteFile(output, converted, 0644)
+}
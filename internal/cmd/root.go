@@ -3,8 +3,11 @@ package cmd
 import (
 	"log"
 
+	"github.com/bigkevmcd/askja/internal/cmd/cache"
 	"github.com/bigkevmcd/askja/internal/cmd/helm"
 	"github.com/bigkevmcd/askja/internal/cmd/install"
+	"github.com/bigkevmcd/askja/internal/cmd/profile"
+	"github.com/bigkevmcd/askja/internal/cmd/uninstall"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -20,7 +23,10 @@ func makeRootCmd() *cobra.Command {
 		SilenceErrors: true,
 	}
 	cmd.AddCommand(install.MakeCmd())
+	cmd.AddCommand(uninstall.MakeCmd())
 	cmd.AddCommand(helm.MakeCmd())
+	cmd.AddCommand(cache.MakeCmd())
+	cmd.AddCommand(profile.MakeCmd())
 	return cmd
 }
 
@@ -0,0 +1,59 @@
+package uninstall
+
+import (
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bigkevmcd/askja/pkg/operations"
+	"github.com/bigkevmcd/askja/pkg/profiles"
+)
+
+const (
+	profileURLParam = "profile-url"
+	newBranchParam  = "new-branch"
+	namePrefixParam = "name-prefix"
+)
+
+func MakeCmd() *cobra.Command {
+	opts := &operations.UninstallOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "uninstall",
+		Short: "remove the files generated for an installed profile",
+		Run: func(cmd *cobra.Command, args []string) {
+			cwd, err := os.Getwd()
+			if err != nil {
+				log.Fatalf("failed to get the working directory: %s", err)
+			}
+			if err := operations.UninstallProfile(cwd, opts); err != nil {
+				log.Fatalf("failed to uninstall the profile: %s", err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(
+		&opts.ProfileURL,
+		profileURLParam,
+		"",
+		"URL that the profile was installed from e.g. https://github.com/weaveworks/nginx-profile.git",
+	)
+	cmd.MarkFlagRequired(profileURLParam)
+
+	cmd.Flags().StringVar(
+		&opts.NamePrefix,
+		namePrefixParam,
+		profiles.DefaultNamePrefix,
+		"name prefix that the profile was installed with",
+	)
+
+	cmd.Flags().StringVar(
+		&opts.NewBranchName,
+		newBranchParam,
+		"",
+		"new branch name to apply changes to e.g. test-branch",
+	)
+	cmd.MarkFlagRequired(newBranchParam)
+	return cmd
+}
@@ -0,0 +1,49 @@
+package values
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/bigkevmcd/askja/pkg/operations"
+)
+
+const (
+	valuesParam              = "values"
+	setParam                 = "set"
+	valuesFromConfigMapParam = "values-from-configmap"
+	valuesFromSecretParam    = "values-from-secret"
+)
+
+// artifactHelp is how the values for each flag are targeted at a single
+// artifact, see operations.ValuesOptions.
+const artifactHelp = `, prefix with an artifact name and "=" to target a single artifact e.g. `
+
+// AddFlags adds the flags for setting Helm values to the command.
+func AddFlags(cmd *cobra.Command, opts *operations.ValuesOptions) {
+	cmd.Flags().StringArrayVar(
+		&opts.Files,
+		valuesParam,
+		nil,
+		"values file for the helm releases, can be repeated and files are merged in order"+artifactHelp+"nginx-server=nginx.yaml",
+	)
+
+	cmd.Flags().StringArrayVar(
+		&opts.Set,
+		setParam,
+		nil,
+		"set a value for the helm releases e.g. service.port=8080, can be repeated and takes precedence over all values files"+artifactHelp+"nginx-server=replicaCount=2, a value with an \"=\" for all artifacts needs an empty prefix e.g. =podAnnotations.owner=team=web",
+	)
+
+	cmd.Flags().StringArrayVar(
+		&opts.ConfigMaps,
+		valuesFromConfigMapParam,
+		nil,
+		"ConfigMap with values for the helm releases, optionally with the key e.g. my-values:values.yaml"+artifactHelp+"nginx-server=my-values:values.yaml",
+	)
+
+	cmd.Flags().StringArrayVar(
+		&opts.Secrets,
+		valuesFromSecretParam,
+		nil,
+		"Secret with values for the helm releases, optionally with the key e.g. my-values:values.yaml"+artifactHelp+"nginx-server=my-values:values.yaml",
+	)
+}
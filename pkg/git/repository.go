@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
@@ -56,6 +57,20 @@ func (r *Repository) CreateAndSwitchBranch(name string) error {
 	return nil
 }
 
+// Filesystem returns the filesystem for the worktree.
+func (r *Repository) Filesystem() billy.Filesystem {
+	return r.wt.Filesystem
+}
+
+// Add adds the named file to the current worktree, so that it will be
+// included in the next commit.
+func (r *Repository) Add(name string) error {
+	if _, err := r.wt.Add(name); err != nil {
+		return fmt.Errorf("failed to add file %q: %w", name, err)
+	}
+	return nil
+}
+
 // WriteFile writes data to the named file, creating it if necessary.
 // If the file does not exist, WriteFile creates it with permissions perm
 // (before umask); otherwise WriteFile truncates it before writing, without
@@ -79,6 +94,15 @@ func (r *Repository) WriteFile(name string, data []byte, perm os.FileMode) error
 	return nil
 }
 
+// RemoveFile removes the named file from the worktree, and the removal will
+// be included in the next commit.
+func (r *Repository) RemoveFile(name string) error {
+	if _, err := r.wt.Remove(name); err != nil {
+		return fmt.Errorf("failed to remove file %q: %w", name, err)
+	}
+	return nil
+}
+
 // Commit creates a new commit in the git repository.
 //
 // It returns the sha of the commit.
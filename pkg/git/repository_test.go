@@ -52,6 +52,34 @@ func TestWriteFile(t *testing.T) {
 	}
 }
 
+func TestRemoveFile(t *testing.T) {
+	tmpDir, bfs := test.MakeTempGitRepo(t)
+	g, err := New(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.WriteFile(testFilename, []byte(`testing: value\n`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Commit("Add testing file", makeOpts(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.RemoveFile(testFilename); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bfs.Stat(testFilename); err == nil {
+		t.Fatalf("file %q was not removed", testFilename)
+	}
+	status, err := g.wt.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := status.File(testFilename).Staging; s != git.Deleted {
+		t.Fatalf("got staging status %q, want the removal to be staged", s)
+	}
+}
+
 func TestCommit(t *testing.T) {
 	tmpDir, _ := test.MakeTempGitRepo(t)
 	g, err := New(tmpDir)
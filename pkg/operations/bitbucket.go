@@ -0,0 +1,115 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const bitbucketTagsPageSize = 100
+
+// BitbucketServerClient fetches files via the Bitbucket Server REST API.
+type BitbucketServerClient struct {
+	*http.Client
+	BaseURL string
+}
+
+// NewBitbucketServerClient returns an implementation of the client that can
+// fetch files from the Bitbucket Server at baseURL e.g.
+// https://bitbucket.example.com.
+func NewBitbucketServerClient(baseURL string, c *http.Client) *BitbucketServerClient {
+	return &BitbucketServerClient{Client: c, BaseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// FileContents implements the Client interface.
+//
+// The repo can be the path from a clone URL e.g. scm/PROJECT/repo.
+func (c BitbucketServerClient) FileContents(ctx context.Context, repo, path, ref string) ([]byte, error) {
+	base, err := c.repoURL(repo)
+	if err != nil {
+		return nil, err
+	}
+	fileURL := fmt.Sprintf("%s/raw/%s?at=%s", base, strings.TrimPrefix(path, "/"), url.QueryEscape(ref))
+	b, err := getBody(ctx, c.Client, fileURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch file: %w", err)
+	}
+	return b, nil
+}
+
+// ResolveRef implements the RefResolver interface.
+func (c BitbucketServerClient) ResolveRef(ctx context.Context, repo, ref string) (string, error) {
+	base, err := c.repoURL(repo)
+	if err != nil {
+		return "", err
+	}
+	commitsURL := fmt.Sprintf("%s/commits?until=%s&limit=1", base, url.QueryEscape(ref))
+	var page struct {
+		Values []struct {
+			ID string `json:"id"`
+		} `json:"values"`
+	}
+	if err := getJSON(ctx, c.Client, commitsURL, &page); err != nil {
+		return "", fmt.Errorf("failed to resolve ref %q: %w", ref, err)
+	}
+	if len(page.Values) == 0 {
+		return "", NewClientError(http.StatusNotFound, fmt.Sprintf("ref %q not found in %s", ref, repo))
+	}
+	return page.Values[0].ID, nil
+}
+
+// ListTags implements the TagLister interface.
+func (c BitbucketServerClient) ListTags(ctx context.Context, repo string) ([]string, error) {
+	base, err := c.repoURL(repo)
+	if err != nil {
+		return nil, err
+	}
+	tags := []string{}
+	start := 0
+	for {
+		tagsURL := fmt.Sprintf("%s/tags?limit=%d&start=%d", base, bitbucketTagsPageSize, start)
+		var page struct {
+			Values []struct {
+				DisplayID string `json:"displayId"`
+			} `json:"values"`
+			IsLastPage    bool `json:"isLastPage"`
+			NextPageStart int  `json:"nextPageStart"`
+		}
+		if err := getJSON(ctx, c.Client, tagsURL, &page); err != nil {
+			return nil, fmt.Errorf("failed to fetch tags: %w", err)
+		}
+		for _, t := range page.Values {
+			tags = append(tags, t.DisplayID)
+		}
+		if page.IsLastPage {
+			return tags, nil
+		}
+		if page.NextPageStart <= start {
+			return nil, fmt.Errorf("failed to fetch tags: the next page start %d doesn't advance from %d", page.NextPageStart, start)
+		}
+		start = page.NextPageStart
+	}
+}
+
+// repoURL returns the API URL for the repo, servers that are hosted under a
+// context path e.g. https://example.com/bitbucket have clone URLs with the
+// context path before the scm/ e.g. bitbucket/scm/PROJECT/repo, and the
+// context path is added to the BaseURL if it's not already there.
+func (c BitbucketServerClient) repoURL(repo string) (string, error) {
+	base := c.BaseURL
+	parts := strings.Split(strings.Trim(repo, "/"), "/")
+	if len(parts) >= 3 && parts[len(parts)-3] == "scm" {
+		if contextPath := strings.Join(parts[:len(parts)-3], "/"); contextPath != "" && !strings.HasSuffix(base, "/"+contextPath) {
+			base = base + "/" + contextPath
+		}
+		parts = parts[len(parts)-2:]
+	} else if parts[0] == "scm" {
+		parts = parts[1:]
+	}
+	if len(parts) != 2 {
+		return "", fmt.Errorf("invalid Bitbucket Server repository %q, must be PROJECT/repo", repo)
+	}
+	return fmt.Sprintf("%s/rest/api/1.0/projects/%s/repos/%s", base, parts[0], parts[1]), nil
+}
@@ -0,0 +1,109 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ Client = (*BitbucketServerClient)(nil)
+var _ TagLister = (*BitbucketServerClient)(nil)
+var _ RefResolver = (*BitbucketServerClient)(nil)
+
+func TestBitbucketServerClient(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/api/1.0/projects/PRJ/repos/repo/raw/profile.yaml" || r.URL.Query().Get("at") != "main" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "testing")
+	}))
+	t.Cleanup(ts.Close)
+	c := NewBitbucketServerClient(ts.URL, ts.Client())
+
+	b, err := c.FileContents(context.TODO(), "scm/PRJ/repo", "profile.yaml", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "testing" {
+		t.Fatalf("got %s, want %s", b, "testing")
+	}
+}
+
+func TestBitbucketServerClient_context_path(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bitbucket/rest/api/1.0/projects/PRJ/repos/repo/raw/profile.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "testing")
+	}))
+	t.Cleanup(ts.Close)
+
+	for _, baseURL := range []string{ts.URL, ts.URL + "/bitbucket"} {
+		c := NewBitbucketServerClient(baseURL, ts.Client())
+		b, err := c.FileContents(context.TODO(), "bitbucket/scm/PRJ/repo", "profile.yaml", "main")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "testing" {
+			t.Fatalf("got %s, want %s", b, "testing")
+		}
+	}
+}
+
+func TestBitbucketServerClient_invalid_repo(t *testing.T) {
+	c := NewBitbucketServerClient("https://bitbucket.example.com", http.DefaultClient)
+
+	_, err := c.FileContents(context.TODO(), "scm/repo", "profile.yaml", "main")
+
+	want := `invalid Bitbucket Server repository "scm/repo", must be PROJECT/repo`
+	if err == nil || err.Error() != want {
+		t.Fatalf("got error %v, want %q", err, want)
+	}
+}
+
+func TestBitbucketServerClient_ListTags(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/api/1.0/projects/PRJ/repos/repo/tags" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Query().Get("start") == "0" {
+			fmt.Fprint(w, `{"values": [{"displayId": "v0.2.0"}], "isLastPage": false, "nextPageStart": 1}`)
+			return
+		}
+		fmt.Fprint(w, `{"values": [{"displayId": "v0.1.0"}], "isLastPage": true}`)
+	}))
+	t.Cleanup(ts.Close)
+	c := NewBitbucketServerClient(ts.URL, ts.Client())
+
+	tags, err := c.ListTags(context.TODO(), "scm/PRJ/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]string{"v0.2.0", "v0.1.0"}, tags); diff != "" {
+		t.Fatalf("failed to list tags:\n%s", diff)
+	}
+}
+
+func TestBitbucketServerClient_ListTags_page_does_not_advance(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"values": [{"displayId": "v0.2.0"}], "isLastPage": false, "nextPageStart": 0}`)
+	}))
+	t.Cleanup(ts.Close)
+	c := NewBitbucketServerClient(ts.URL, ts.Client())
+
+	_, err := c.ListTags(context.TODO(), "scm/PRJ/repo")
+
+	want := "failed to fetch tags: the next page start 0 doesn't advance from 0"
+	if err == nil || err.Error() != want {
+		t.Fatalf("got error %v, want %q", err, want)
+	}
+}
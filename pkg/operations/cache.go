@@ -0,0 +1,278 @@
+package operations
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const cacheDirMode os.FileMode = 0755
+
+var fullCommitSHA = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// RefResolver implementations can resolve a branch, tag or commit to the SHA
+// of the commit.
+type RefResolver interface {
+	ResolveRef(ctx context.Context, repo, ref string) (string, error)
+}
+
+// CacheOptions configures the CachingClient.
+type CacheOptions struct {
+	// Dir is the directory that files are cached in.
+	Dir string
+	// Offline disables fetching, only cached files are returned.
+	Offline bool
+}
+
+// DefaultCacheDir returns the askja directory in the user's cache directory,
+// this is $XDG_CACHE_HOME/askja on Linux.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get the user cache directory: %w", err)
+	}
+	return filepath.Join(dir, "askja"), nil
+}
+
+// CachingClient is a Client that caches the files fetched by another Client
+// on disk.
+//
+// Files are cached by the commit SHA that the ref resolves to, branches are
+// resolved on each request unless the client is offline, tags and commits
+// (see withFixedRef) are only resolved the first time they're fetched, this
+// requires the wrapped Client to implement RefResolver, or the ref to be a
+// full commit SHA, otherwise files are fetched without caching.
+type CachingClient struct {
+	client  Client
+	repoURL string
+	opts    CacheOptions
+}
+
+// NewCachingClient creates and returns a CachingClient that caches files
+// fetched by the client from the repo at repoURL.
+func NewCachingClient(client Client, repoURL string, opts CacheOptions) *CachingClient {
+	return &CachingClient{client: client, repoURL: repoURL, opts: opts}
+}
+
+// NewCachingClientFactory returns a ClientFactory that wraps the Clients
+// created by the factory in a CachingClient.
+func NewCachingClientFactory(factory ClientFactory, opts CacheOptions) ClientFactory {
+	return func(ctx context.Context, repoURL string) (Client, error) {
+		c, err := factory(ctx, repoURL)
+		if err != nil {
+			return nil, err
+		}
+		return NewCachingClient(c, repoURL, opts), nil
+	}
+}
+
+// FileContents implements the Client interface.
+func (c *CachingClient) FileContents(ctx context.Context, repo, path, ref string) ([]byte, error) {
+	sha, err := c.resolve(ctx, repo, ref)
+	if err != nil {
+		return nil, err
+	}
+	if sha == "" {
+		return c.client.FileContents(ctx, repo, path, ref)
+	}
+	cached, err := cachePath(filepath.Join(c.repoDir(repo), "commits", sha), path)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(cached)
+	if err == nil {
+		return b, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read cached file %q: %w", cached, err)
+	}
+	if c.opts.Offline {
+		return nil, fmt.Errorf("file %q from %s at %s is not cached and askja is offline", path, c.repoURL, ref)
+	}
+	b, err = c.client.FileContents(ctx, repo, path, sha)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeCacheFile(cached, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// ListTags implements the TagLister interface.
+//
+// The tags are cached, so that semver ranges can be resolved offline.
+func (c *CachingClient) ListTags(ctx context.Context, repo string) ([]string, error) {
+	cached := filepath.Join(c.repoDir(repo), "tags.json")
+	if c.opts.Offline {
+		b, err := os.ReadFile(cached)
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("tags for %s are not cached and askja is offline", c.repoURL)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cached tags %q: %w", cached, err)
+		}
+		tags := []string{}
+		if err := json.Unmarshal(b, &tags); err != nil {
+			return nil, fmt.Errorf("failed to decode cached tags %q: %w", cached, err)
+		}
+		return tags, nil
+	}
+	lister, ok := c.client.(TagLister)
+	if !ok {
+		return nil, errors.New("the client does not support listing tags")
+	}
+	tags, err := lister.ListTags(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(tags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode tags: %w", err)
+	}
+	if err := writeCacheFile(cached, b); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// PathExists implements the PathChecker interface.
+//
+// Paths can only be checked if the wrapped client implements PathChecker and
+// askja is online, otherwise paths are assumed to exist.
+func (c *CachingClient) PathExists(ctx context.Context, repo, path, ref string) (bool, error) {
+	checker, ok := c.client.(PathChecker)
+	if !ok || c.opts.Offline {
+		return true, nil
+	}
+	return checker.PathExists(ctx, repo, path, ref)
+}
+
+// resolve returns the commit SHA for the ref, or an empty string if the ref
+// can't be resolved, refs that have been resolved are recorded so that they
+// can be used offline, and so that tags and commits aren't resolved again.
+func (c *CachingClient) resolve(ctx context.Context, repo, ref string) (string, error) {
+	if fullCommitSHA.MatchString(ref) {
+		return ref, nil
+	}
+	refFile, err := cachePath(filepath.Join(c.repoDir(repo), "refs"), url.PathEscape(ref))
+	if err != nil {
+		return "", err
+	}
+	if c.opts.Offline || isFixedRef(ctx) {
+		b, err := os.ReadFile(refFile)
+		if err == nil {
+			return strings.TrimSpace(string(b)), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed to read cached ref %q: %w", refFile, err)
+		}
+		if c.opts.Offline {
+			return "", fmt.Errorf("%s at %s is not cached and askja is offline", c.repoURL, ref)
+		}
+	}
+	resolver, ok := c.client.(RefResolver)
+	if !ok {
+		return "", nil
+	}
+	sha, err := resolver.ResolveRef(ctx, repo, ref)
+	if err != nil {
+		return "", err
+	}
+	if !fullCommitSHA.MatchString(sha) {
+		return "", fmt.Errorf("ref %q in %s resolved to an invalid commit SHA %q", ref, c.repoURL, sha)
+	}
+	if err := writeCacheFile(refFile, []byte(sha)); err != nil {
+		return "", err
+	}
+	return sha, nil
+}
+
+type fixedRefKey struct{}
+
+// withFixedRef returns a context for fetching files at a ref that is known to
+// be a tag or commit, unlike branches, these don't move, so the CachingClient
+// can use the commit that the ref was last resolved to.
+func withFixedRef(ctx context.Context) context.Context {
+	return context.WithValue(ctx, fixedRefKey{}, true)
+}
+
+func isFixedRef(ctx context.Context) bool {
+	fixed, _ := ctx.Value(fixedRefKey{}).(bool)
+	return fixed
+}
+
+// cachePath returns the path to the file in the cache directory, paths that
+// are absolute or outside the directory are rejected.
+func cachePath(dir, path string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(path))
+	if filepath.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid path %q, must be inside the repository", path)
+	}
+	return filepath.Join(dir, clean), nil
+}
+
+func (c *CachingClient) repoDir(repo string) string {
+	h := sha256.Sum256([]byte(c.repoURL + "\x00" + repo))
+	return filepath.Join(c.opts.Dir, hex.EncodeToString(h[:16]))
+}
+
+func writeCacheFile(name string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), cacheDirMode); err != nil {
+		return fmt.Errorf("failed to create cache directory for %q: %w", name, err)
+	}
+	if err := os.WriteFile(name, b, defaultFileMode); err != nil {
+		return fmt.Errorf("failed to write cache file %q: %w", name, err)
+	}
+	return nil
+}
+
+// PruneCache removes files from the cache directory that were last written
+// before the cutoff, and any directories left empty.
+//
+// It returns the number of files that were removed.
+func PruneCache(dir string, cutoff time.Time) (int, error) {
+	removed := 0
+	dirs := []string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			if path != dir {
+				dirs = append(dirs, path)
+			}
+			return nil
+		}
+		if info.ModTime().Before(cutoff) {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	if err != nil {
+		return removed, fmt.Errorf("failed to prune cache %q: %w", dir, err)
+	}
+	// Directories are walked in lexical order, so children are removed first
+	// when iterating in reverse.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if entries, err := os.ReadDir(dirs[i]); err == nil && len(entries) == 0 {
+			os.Remove(dirs[i])
+		}
+	}
+	return removed, nil
+}
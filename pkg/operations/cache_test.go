@@ -0,0 +1,211 @@
+package operations
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bigkevmcd/askja/test"
+)
+
+var _ Client = (*CachingClient)(nil)
+var _ TagLister = (*CachingClient)(nil)
+var _ PathChecker = (*CachingClient)(nil)
+
+const (
+	testRepoURL = "https://github.com/test/repo.git"
+	testSHA     = "0e8da0c7d1e1ec1f4fd0a8ab53bda0a4c6d3b4a5"
+)
+
+func TestCachingClient(t *testing.T) {
+	dir := test.MakeTempDir(t)
+	inner := newMockClient()
+	inner.addRef("test/repo", "main", testSHA)
+	inner.add("test/repo", "profile.yaml", testSHA, []byte("testing"))
+	c := NewCachingClient(inner, testRepoURL, CacheOptions{Dir: dir})
+
+	for i := 0; i < 2; i++ {
+		b, err := c.FileContents(context.TODO(), "test/repo", "profile.yaml", "main")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "testing" {
+			t.Fatalf("got %s, want %s", b, "testing")
+		}
+	}
+
+	if inner.fetches != 1 {
+		t.Fatalf("got %d fetches, want 1", inner.fetches)
+	}
+}
+
+func TestCachingClient_new_commit(t *testing.T) {
+	dir := test.MakeTempDir(t)
+	inner := newMockClient()
+	inner.addRef("test/repo", "main", testSHA)
+	inner.add("test/repo", "profile.yaml", testSHA, []byte("testing"))
+	inner.add("test/repo", "profile.yaml", "1111111111111111111111111111111111111111", []byte("updated"))
+	c := NewCachingClient(inner, testRepoURL, CacheOptions{Dir: dir})
+	if _, err := c.FileContents(context.TODO(), "test/repo", "profile.yaml", "main"); err != nil {
+		t.Fatal(err)
+	}
+
+	inner.addRef("test/repo", "main", "1111111111111111111111111111111111111111")
+	b, err := c.FileContents(context.TODO(), "test/repo", "profile.yaml", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "updated" {
+		t.Fatalf("got %s, want %s", b, "updated")
+	}
+}
+
+func TestCachingClient_fixed_ref(t *testing.T) {
+	dir := test.MakeTempDir(t)
+	inner := newMockClient()
+	inner.addRef("test/repo", "v0.1.0", testSHA)
+	inner.add("test/repo", "profile.yaml", testSHA, []byte("testing"))
+	c := NewCachingClient(inner, testRepoURL, CacheOptions{Dir: dir})
+
+	for i := 0; i < 2; i++ {
+		b, err := c.FileContents(withFixedRef(context.TODO()), "test/repo", "profile.yaml", "v0.1.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "testing" {
+			t.Fatalf("got %s, want %s", b, "testing")
+		}
+	}
+
+	if inner.resolves != 1 {
+		t.Fatalf("got %d resolves, want 1", inner.resolves)
+	}
+}
+
+func TestCachingClient_invalid_paths(t *testing.T) {
+	dir := test.MakeTempDir(t)
+	inner := newMockClient()
+	inner.addRef("test/repo", "main", testSHA)
+	inner.addRef("test/repo", "invalid", "../../outside")
+	c := NewCachingClient(inner, testRepoURL, CacheOptions{Dir: dir})
+
+	errorTests := []struct {
+		path string
+		ref  string
+		want string
+	}{
+		{"../../../profile.yaml", "main", `invalid path "../../../profile.yaml", must be inside the repository`},
+		{"/etc/profile.yaml", "main", `invalid path "/etc/profile.yaml", must be inside the repository`},
+		{"profile.yaml", "..", `invalid path "..", must be inside the repository`},
+		{"profile.yaml", "invalid", `ref "invalid" in https://github.com/test/repo.git resolved to an invalid commit SHA "../../outside"`},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.path, func(t *testing.T) {
+			_, err := c.FileContents(context.TODO(), "test/repo", tt.path, tt.ref)
+			if err == nil || err.Error() != tt.want {
+				t.Fatalf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCachingClient_offline(t *testing.T) {
+	dir := test.MakeTempDir(t)
+	inner := newMockClient()
+	inner.addRef("test/repo", "main", testSHA)
+	inner.add("test/repo", "profile.yaml", testSHA, []byte("testing"))
+	inner.addTags("test/repo", "v0.1.0")
+	online := NewCachingClient(inner, testRepoURL, CacheOptions{Dir: dir})
+	if _, err := online.FileContents(context.TODO(), "test/repo", "profile.yaml", "main"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := online.ListTags(context.TODO(), "test/repo"); err != nil {
+		t.Fatal(err)
+	}
+
+	offline := NewCachingClient(newMockClient(), testRepoURL, CacheOptions{Dir: dir, Offline: true})
+	b, err := offline.FileContents(context.TODO(), "test/repo", "profile.yaml", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "testing" {
+		t.Fatalf("got %s, want %s", b, "testing")
+	}
+	tags, err := offline.ListTags(context.TODO(), "test/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"v0.1.0"}, tags); diff != "" {
+		t.Fatalf("failed to list cached tags:\n%s", diff)
+	}
+
+	_, err = offline.FileContents(context.TODO(), "test/repo", "profile.yaml", "v0.1.0")
+	want := "https://github.com/test/repo.git at v0.1.0 is not cached and askja is offline"
+	if err == nil || err.Error() != want {
+		t.Fatalf("got error %v, want %q", err, want)
+	}
+}
+
+func TestCachingClient_no_resolver(t *testing.T) {
+	dir := test.MakeTempDir(t)
+	inner := newMockClient()
+	inner.add("test/repo", "profile.yaml", "main", []byte("testing"))
+	c := NewCachingClient(struct{ Client }{inner}, testRepoURL, CacheOptions{Dir: dir})
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.FileContents(context.TODO(), "test/repo", "profile.yaml", "main"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if inner.fetches != 2 {
+		t.Fatalf("got %d fetches, want 2", inner.fetches)
+	}
+}
+
+func TestPruneCache(t *testing.T) {
+	dir := test.MakeTempDir(t)
+	old := filepath.Join(dir, "repo", "commits", "old", "profile.yaml")
+	recent := filepath.Join(dir, "repo", "commits", "recent", "profile.yaml")
+	for _, f := range []string{old, recent} {
+		if err := writeCacheFile(f, []byte("testing")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lastWeek := time.Now().Add(-7 * 24 * time.Hour)
+	if err := os.Chtimes(old, lastWeek, lastWeek); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := PruneCache(dir, time.Now().Add(-24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if removed != 1 {
+		t.Fatalf("got %d removed, want 1", removed)
+	}
+	if _, err := os.Stat(filepath.Dir(old)); !os.IsNotExist(err) {
+		t.Fatalf("pruned directory %q was not removed: %v", filepath.Dir(old), err)
+	}
+	if _, err := os.Stat(recent); err != nil {
+		t.Fatalf("recent file was removed: %s", err)
+	}
+}
+
+func TestPruneCache_missing_dir(t *testing.T) {
+	removed, err := PruneCache(filepath.Join(test.MakeTempDir(t), "missing"), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if removed != 0 {
+		t.Fatalf("got %d removed, want 0", removed)
+	}
+}
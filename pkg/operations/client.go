@@ -4,22 +4,33 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 )
 
+const githubTagsPageSize = 100
+
+// DefaultClientRegistry is the registry used to create clients by the
+// DefaultClientFactory, repositories on hosts without a driver are cloned.
+var DefaultClientRegistry = newDefaultClientRegistry()
+
 // DefaultClientFactory is the default client factory implementation.
-var DefaultClientFactory ClientFactory = RawGitHubClientFactory
+var DefaultClientFactory ClientFactory = DefaultClientRegistry.ClientFactory
 
 // Client implementations access git hosts to fetch files.
 type Client interface {
 	FileContents(ctx context.Context, repo, path, ref string) ([]byte, error)
 }
 
+// TagLister implementations can list the tags in a git repository.
+type TagLister interface {
+	ListTags(ctx context.Context, repo string) ([]string, error)
+}
+
 // ClientFactory implementations should return a Client interface ready to be
-// used to access the provided repoURL.
-type ClientFactory func(repoURL string) (Client, error)
+// used to access the provided repoURL, the context is used when looking up the
+// credentials for the repoURL.
+type ClientFactory func(ctx context.Context, repoURL string) (Client, error)
 
 // ClientError is an error from Client implementations.
 type ClientError struct {
@@ -36,8 +47,25 @@ func NewClientError(code int, message string) ClientError {
 	return ClientError{StatusCode: code, Message: message}
 }
 
+// IsNotFoundOrUnauthorised returns true if the error is a ClientError
+// indicating that the file does not exist, or that the credentials used to
+// access it are missing or lack permission.
+//
+// Git hosts commonly return 404 rather than 403 for private repositories.
+func IsNotFoundOrUnauthorised(err error) bool {
+	var ce ClientError
+	if !errors.As(err, &ce) {
+		return false
+	}
+	switch ce.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return true
+	}
+	return false
+}
+
 // RawGitHubClient is a very naive client that fetches files via
-// raw.githubusercontent.com, without authentication.
+// raw.githubusercontent.com.
 type RawGitHubClient struct {
 	*http.Client
 }
@@ -45,7 +73,7 @@ type RawGitHubClient struct {
 // NewRawGitHubClient returns an implementation of the client that can fetch using
 // the raw GitHub access.
 //
-// This will not work for authenticated requests, or anything other than GitHub.
+// Requests are authenticated by the transport of the provided http.Client.
 func NewRawGitHubClient(c *http.Client) *RawGitHubClient {
 	return &RawGitHubClient{Client: c}
 }
@@ -53,24 +81,68 @@ func NewRawGitHubClient(c *http.Client) *RawGitHubClient {
 // FileContents implements the Client interface.
 func (c RawGitHubClient) FileContents(ctx context.Context, repo, path, ref string) ([]byte, error) {
 	fileURL := fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s", repo, ref, path)
-	resp, err := c.Client.Get(fileURL)
+	b, err := getBody(ctx, c.Client, fileURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch file from %s: %w", fileURL, err)
+		return nil, fmt.Errorf("failed to fetch file: %w", err)
+	}
+	return b, nil
+}
+
+// ListTags implements the TagLister interface.
+//
+// The tags are fetched from the GitHub API.
+func (c RawGitHubClient) ListTags(ctx context.Context, repo string) ([]string, error) {
+	tags := []string{}
+	for page := 1; ; page++ {
+		found, err := c.listTagsPage(ctx, repo, page)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, found...)
+		if len(found) < githubTagsPageSize {
+			return tags, nil
+		}
 	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to fetch file from %s: status code %d", fileURL, resp.StatusCode)
+}
+
+// ResolveRef implements the RefResolver interface.
+func (c RawGitHubClient) ResolveRef(ctx context.Context, repo, ref string) (string, error) {
+	commitURL := fmt.Sprintf("https://api.github.com/repos/%s/commits/%s", repo, url.PathEscape(ref))
+	var commit struct {
+		SHA string `json:"sha"`
 	}
-	defer resp.Body.Close()
-	b, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read file contents from %s: %w", fileURL, err)
+	if err := getJSON(ctx, c.Client, commitURL, &commit); err != nil {
+		return "", fmt.Errorf("failed to resolve ref %q: %w", ref, err)
 	}
-	return b, nil
+	return commit.SHA, nil
+}
+
+func (c RawGitHubClient) listTagsPage(ctx context.Context, repo string, page int) ([]string, error) {
+	tagsURL := fmt.Sprintf("https://api.github.com/repos/%s/tags?per_page=%d&page=%d", repo, githubTagsPageSize, page)
+	var found []struct {
+		Name string `json:"name"`
+	}
+	if err := getJSON(ctx, c.Client, tagsURL, &found); err != nil {
+		return nil, fmt.Errorf("failed to fetch tags: %w", err)
+	}
+	tags := []string{}
+	for _, t := range found {
+		tags = append(tags, t.Name)
+	}
+	return tags, nil
+}
+
+func newDefaultClientRegistry() *ClientRegistry {
+	r := NewClientRegistry(DefaultHTTPClient, DefaultCredentials())
+	r.Fallback = NewGitCloneClientFactory(GitCloneOptions{Credentials: DefaultCredentials()})
+	return r
 }
 
 // RawGitHubClientFactory is a very simple client that only supports fetching
 // via github.com (and only unauthenticated requests).
-func RawGitHubClientFactory(repoURL string) (Client, error) {
+//
+// The DefaultClientRegistry supports authentication and other git hosts.
+func RawGitHubClientFactory(ctx context.Context, repoURL string) (Client, error) {
 	parsed, err := url.Parse(repoURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse repo URL %q: %w", repoURL, err)
@@ -79,5 +151,5 @@ func RawGitHubClientFactory(repoURL string) (Client, error) {
 	if parsed.Host != "github.com" {
 		return nil, errors.New("unsupported git provider, only github.com is currently supported")
 	}
-	return NewRawGitHubClient(http.DefaultClient), nil
+	return NewRawGitHubClient(DefaultHTTPClient), nil
 }
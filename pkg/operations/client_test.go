@@ -2,13 +2,18 @@ package operations
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"gopkg.in/h2non/gock.v1"
 )
 
 var _ Client = (*RawGitHubClient)(nil)
+var _ TagLister = (*RawGitHubClient)(nil)
+var _ RefResolver = (*RawGitHubClient)(nil)
 
 func TestRawGitHubClient(t *testing.T) {
 	body := "testing"
@@ -32,6 +37,68 @@ func TestRawGitHubClient(t *testing.T) {
 	}
 }
 
+func TestRawGitHubClient_ListTags(t *testing.T) {
+	defer gock.Off()
+	gock.New("https://api.github.com").
+		Get("repos/test/repo/tags").
+		MatchParam("page", "1").
+		Reply(200).
+		JSON([]map[string]string{{"name": "v0.2.0"}, {"name": "v0.1.0"}})
+
+	client := &http.Client{Transport: &http.Transport{}}
+	gock.InterceptClient(client)
+	c := NewRawGitHubClient(client)
+
+	tags, err := c.ListTags(context.TODO(), "test/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]string{"v0.2.0", "v0.1.0"}, tags); diff != "" {
+		t.Fatalf("failed to list tags:\n%s", diff)
+	}
+}
+
+func TestRawGitHubClient_ResolveRef(t *testing.T) {
+	defer gock.Off()
+	gock.New("https://api.github.com").
+		Get("repos/test/repo/commits/main").
+		Reply(200).
+		JSON(map[string]string{"sha": "0e8da0c7d1e1ec1f4fd0a8ab53bda0a4c6d3b4a5"})
+
+	client := &http.Client{Transport: &http.Transport{}}
+	gock.InterceptClient(client)
+	c := NewRawGitHubClient(client)
+
+	sha, err := c.ResolveRef(context.TODO(), "test/repo", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sha != "0e8da0c7d1e1ec1f4fd0a8ab53bda0a4c6d3b4a5" {
+		t.Fatalf("got %s, want %s", sha, "0e8da0c7d1e1ec1f4fd0a8ab53bda0a4c6d3b4a5")
+	}
+}
+
+func TestIsNotFoundOrUnauthorised(t *testing.T) {
+	errorTests := []struct {
+		err  error
+		want bool
+	}{
+		{NewClientError(http.StatusNotFound, "not found"), true},
+		{NewClientError(http.StatusUnauthorized, "unauthorized"), true},
+		{fmt.Errorf("failed to fetch file: %w", NewClientError(http.StatusForbidden, "forbidden")), true},
+		{NewClientError(http.StatusInternalServerError, "server error"), false},
+		{errors.New("failed"), false},
+	}
+
+	for _, tt := range errorTests {
+		if v := IsNotFoundOrUnauthorised(tt.err); v != tt.want {
+			t.Errorf("IsNotFoundOrUnauthorised(%v) got %v, want %v", tt.err, v, tt.want)
+		}
+	}
+}
+
 func TestRawGitHubClientFactory(t *testing.T) {
 	t.Skip()
 }
@@ -0,0 +1,245 @@
+package operations
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// GitTokenEnvVar is the environment variable that a token for
+	// authenticating requests to git hosts is read from.
+	GitTokenEnvVar = "ASKJA_GIT_TOKEN"
+	// GitUsernameEnvVar is the environment variable that an optional username
+	// to use with the token is read from.
+	GitUsernameEnvVar = "ASKJA_GIT_USERNAME"
+	// GitHostEnvVar is the environment variable that the host (with optional
+	// port) that the token is for is read from, the token is not used for any
+	// other host.
+	GitHostEnvVar = "ASKJA_GIT_HOST"
+
+	defaultGitHost = "github.com"
+)
+
+// Credentials are used to authenticate requests to git hosts.
+//
+// The Password can be a password or an access token.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// CredentialsResolver implementations return the credentials to use for a
+// host, or nil if they have no credentials for the host.
+type CredentialsResolver interface {
+	Credentials(ctx context.Context, host string) (*Credentials, error)
+}
+
+// DefaultCredentials returns a CredentialsResolver that tries the environment,
+// then ~/.netrc and then the git credential helpers.
+func DefaultCredentials() CredentialsResolver {
+	return ChainCredentials{
+		NewEnvCredentials(),
+		NewNetrcCredentials(""),
+		NewGitCredentialHelper(),
+	}
+}
+
+// ChainCredentials returns the first credentials found by the resolvers.
+type ChainCredentials []CredentialsResolver
+
+// Credentials implements the CredentialsResolver interface.
+func (c ChainCredentials) Credentials(ctx context.Context, host string) (*Credentials, error) {
+	for _, r := range c {
+		creds, err := r.Credentials(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		if creds != nil {
+			return creds, nil
+		}
+	}
+	return nil, nil
+}
+
+// EnvCredentials returns credentials from the environment for the host in
+// GitHostEnvVar, or github.com if that's not set.
+type EnvCredentials struct {
+	lookup func(string) (string, bool)
+}
+
+// NewEnvCredentials creates and returns an EnvCredentials that reads the token
+// from GitTokenEnvVar.
+func NewEnvCredentials() *EnvCredentials {
+	return &EnvCredentials{lookup: os.LookupEnv}
+}
+
+// Credentials implements the CredentialsResolver interface.
+func (e EnvCredentials) Credentials(ctx context.Context, host string) (*Credentials, error) {
+	token, ok := e.lookup(GitTokenEnvVar)
+	if !ok || token == "" {
+		return nil, nil
+	}
+	if !matchesHost(e.host(), host) {
+		return nil, nil
+	}
+	username, _ := e.lookup(GitUsernameEnvVar)
+	return &Credentials{Username: username, Password: token}, nil
+}
+
+func (e EnvCredentials) host() string {
+	if h, ok := e.lookup(GitHostEnvVar); ok && h != "" {
+		return h
+	}
+	return defaultGitHost
+}
+
+// matchesHost returns true if the host matches the configured host, the port
+// is only compared if the configured host has a port.
+func matchesHost(configured, host string) bool {
+	configured, host = strings.ToLower(configured), strings.ToLower(host)
+	if _, _, err := net.SplitHostPort(configured); err == nil {
+		return configured == host
+	}
+	return configured == hostname(host)
+}
+
+// NetrcCredentials returns credentials from a netrc file.
+type NetrcCredentials struct {
+	Path string
+}
+
+// NewNetrcCredentials creates and returns a NetrcCredentials that reads from
+// the provided path, if the path is empty, $NETRC or ~/.netrc is used.
+func NewNetrcCredentials(path string) *NetrcCredentials {
+	if path == "" {
+		path = os.Getenv("NETRC")
+	}
+	if path == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, ".netrc")
+		}
+	}
+	return &NetrcCredentials{Path: path}
+}
+
+// Credentials implements the CredentialsResolver interface.
+//
+// The port is ignored when matching the host against machines in the file.
+func (n NetrcCredentials) Credentials(ctx context.Context, host string) (*Credentials, error) {
+	if n.Path == "" {
+		return nil, nil
+	}
+	b, err := os.ReadFile(n.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read netrc file %q: %w", n.Path, err)
+	}
+	return parseNetrc(b, hostname(host)), nil
+}
+
+func parseNetrc(b []byte, host string) *Credentials {
+	var found, fallback *Credentials
+	var current *Credentials
+	fields := strings.Fields(string(b))
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "machine":
+			current = nil
+			if i+1 < len(fields) {
+				i++
+				if fields[i] == host && found == nil {
+					found = &Credentials{}
+					current = found
+				}
+			}
+		case "default":
+			current = nil
+			if fallback == nil {
+				fallback = &Credentials{}
+				current = fallback
+			}
+		case "login":
+			if i+1 < len(fields) {
+				i++
+				if current != nil {
+					current.Username = fields[i]
+				}
+			}
+		case "password":
+			if i+1 < len(fields) {
+				i++
+				if current != nil {
+					current.Password = fields[i]
+				}
+			}
+		}
+	}
+	if found != nil {
+		return found
+	}
+	return fallback
+}
+
+// GitCredentialHelper returns credentials from the configured git credential
+// helpers with "git credential fill".
+//
+// Terminal prompting is disabled, so hosts without stored credentials are
+// treated as having no credentials.
+type GitCredentialHelper struct {
+	run func(ctx context.Context, input []byte) ([]byte, error)
+}
+
+// NewGitCredentialHelper creates and returns a GitCredentialHelper that
+// executes git.
+func NewGitCredentialHelper() *GitCredentialHelper {
+	return &GitCredentialHelper{run: runGitCredentialFill}
+}
+
+// Credentials implements the CredentialsResolver interface.
+func (g GitCredentialHelper) Credentials(ctx context.Context, host string) (*Credentials, error) {
+	out, err := g.run(ctx, []byte(fmt.Sprintf("protocol=https\nhost=%s\n\n", host)))
+	if err != nil {
+		return nil, nil
+	}
+	creds := &Credentials{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		switch parts[0] {
+		case "username":
+			creds.Username = parts[1]
+		case "password":
+			creds.Password = parts[1]
+		}
+	}
+	if creds.Password == "" {
+		return nil, nil
+	}
+	return creds, nil
+}
+
+func runGitCredentialFill(ctx context.Context, input []byte) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", "credential", "fill")
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=", "SSH_ASKPASS=")
+	return cmd.Output()
+}
+
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
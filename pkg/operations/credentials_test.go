@@ -0,0 +1,155 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bigkevmcd/askja/test"
+)
+
+var _ CredentialsResolver = (*EnvCredentials)(nil)
+var _ CredentialsResolver = (*NetrcCredentials)(nil)
+var _ CredentialsResolver = (*GitCredentialHelper)(nil)
+var _ CredentialsResolver = ChainCredentials{}
+
+func TestEnvCredentials(t *testing.T) {
+	envTests := []struct {
+		name string
+		env  map[string]string
+		host string
+		want *Credentials
+	}{
+		{"no token", map[string]string{}, "github.com", nil},
+		{"token", map[string]string{GitTokenEnvVar: "test-token"}, "github.com", &Credentials{Password: "test-token"}},
+		{"token and username", map[string]string{GitTokenEnvVar: "test-token", GitUsernameEnvVar: "user"}, "github.com", &Credentials{Username: "user", Password: "test-token"}},
+		{"token for another host", map[string]string{GitTokenEnvVar: "test-token"}, "example.com", nil},
+		{"configured host", map[string]string{GitTokenEnvVar: "test-token", GitHostEnvVar: "GitLab.example.com"}, "gitlab.example.com:8443", &Credentials{Password: "test-token"}},
+		{"configured host is not github.com", map[string]string{GitTokenEnvVar: "test-token", GitHostEnvVar: "gitlab.example.com"}, "github.com", nil},
+		{"configured host and port", map[string]string{GitTokenEnvVar: "test-token", GitHostEnvVar: "gitlab.example.com:8443"}, "gitlab.example.com:8443", &Credentials{Password: "test-token"}},
+		{"configured port differs", map[string]string{GitTokenEnvVar: "test-token", GitHostEnvVar: "gitlab.example.com:8443"}, "gitlab.example.com", nil},
+	}
+
+	for _, tt := range envTests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &EnvCredentials{lookup: func(s string) (string, bool) {
+				v, ok := tt.env[s]
+				return v, ok
+			}}
+
+			creds, err := e.Credentials(context.TODO(), tt.host)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tt.want, creds); diff != "" {
+				t.Fatalf("failed to get credentials:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNetrcCredentials(t *testing.T) {
+	netrc := filepath.Join(test.MakeTempDir(t), ".netrc")
+	if err := os.WriteFile(netrc, []byte(`
+machine github.com
+  login github-user
+  password github-token
+
+machine gitlab.example.com login gitlab-user password gitlab-token
+default login default-user password default-token
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	netrcTests := []struct {
+		host string
+		want *Credentials
+	}{
+		{"github.com", &Credentials{Username: "github-user", Password: "github-token"}},
+		{"gitlab.example.com:8443", &Credentials{Username: "gitlab-user", Password: "gitlab-token"}},
+		{"gitea.example.com", &Credentials{Username: "default-user", Password: "default-token"}},
+	}
+
+	for _, tt := range netrcTests {
+		t.Run(tt.host, func(t *testing.T) {
+			creds, err := NewNetrcCredentials(netrc).Credentials(context.TODO(), tt.host)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tt.want, creds); diff != "" {
+				t.Fatalf("failed to get credentials:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNetrcCredentials_missing_file(t *testing.T) {
+	netrc := filepath.Join(test.MakeTempDir(t), ".netrc")
+
+	creds, err := NewNetrcCredentials(netrc).Credentials(context.TODO(), "github.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if creds != nil {
+		t.Fatalf("got credentials %#v, want nil", creds)
+	}
+}
+
+func TestGitCredentialHelper(t *testing.T) {
+	var input string
+	g := &GitCredentialHelper{run: func(ctx context.Context, b []byte) ([]byte, error) {
+		input = string(b)
+		return []byte("protocol=https\nhost=github.com\nusername=user\npassword=test-token\n"), nil
+	}}
+
+	creds, err := g.Credentials(context.TODO(), "github.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(&Credentials{Username: "user", Password: "test-token"}, creds); diff != "" {
+		t.Fatalf("failed to get credentials:\n%s", diff)
+	}
+	if want := "protocol=https\nhost=github.com\n\n"; input != want {
+		t.Fatalf("got input %q, want %q", input, want)
+	}
+}
+
+func TestGitCredentialHelper_no_credentials(t *testing.T) {
+	g := &GitCredentialHelper{run: func(ctx context.Context, b []byte) ([]byte, error) {
+		return nil, errors.New("fatal: could not read Username: terminal prompts disabled")
+	}}
+
+	creds, err := g.Credentials(context.TODO(), "github.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if creds != nil {
+		t.Fatalf("got credentials %#v, want nil", creds)
+	}
+}
+
+func TestChainCredentials(t *testing.T) {
+	c := ChainCredentials{
+		stubCredentials{},
+		stubCredentials{"github.com": &Credentials{Password: "second"}},
+		stubCredentials{"github.com": &Credentials{Password: "third"}},
+	}
+
+	creds, err := c.Credentials(context.TODO(), "github.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(&Credentials{Password: "second"}, creds); diff != "" {
+		t.Fatalf("failed to get credentials:\n%s", diff)
+	}
+}
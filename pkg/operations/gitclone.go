@@ -0,0 +1,273 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+const defaultGitUser = "git"
+
+// commitSHA matches refs that could be a commit, branches and tags can also
+// look like this e.g. 20210101, so these are only treated as commits if there
+// is no branch or tag with the name.
+var commitSHA = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+// PathChecker implementations can check whether a path exists in a git
+// repository.
+type PathChecker interface {
+	PathExists(ctx context.Context, repo, path, ref string) (bool, error)
+}
+
+// GitCloneOptions configures how a GitCloneClient authenticates with the git
+// remote.
+type GitCloneOptions struct {
+	// Credentials are used for HTTPS remotes.
+	Credentials CredentialsResolver
+	// SSHKeyFile is a private key for SSH remotes, if this is empty, the SSH
+	// agent is used.
+	SSHKeyFile string
+	// SSHKeyPassword is the password for the SSHKeyFile.
+	SSHKeyPassword string
+}
+
+// GitCloneClient fetches files by cloning the repository into memory, it works
+// with any git remote that go-git supports, including SSH and file:// URLs.
+//
+// Each ref is only cloned once, branches and tags are shallow cloned, commits
+// require the full history of the repository to be cloned.
+type GitCloneClient struct {
+	repoURL string
+	opts    GitCloneOptions
+
+	mu     sync.Mutex
+	clones map[string]*object.Commit
+}
+
+// NewGitCloneClient creates and returns a client that clones from repoURL.
+func NewGitCloneClient(repoURL string, opts GitCloneOptions) *GitCloneClient {
+	return &GitCloneClient{
+		repoURL: repoURL,
+		opts:    opts,
+		clones:  map[string]*object.Commit{},
+	}
+}
+
+// NewGitCloneClientFactory returns a ClientFactory that creates
+// GitCloneClients.
+func NewGitCloneClientFactory(opts GitCloneOptions) ClientFactory {
+	return func(ctx context.Context, repoURL string) (Client, error) {
+		if _, err := transport.NewEndpoint(repoURL); err != nil {
+			return nil, fmt.Errorf("failed to parse repo URL %q: %w", repoURL, err)
+		}
+		return NewGitCloneClient(repoURL, opts), nil
+	}
+}
+
+// FileContents implements the Client interface.
+//
+// The repo is ignored, files are always read from the URL that the client was
+// created with.
+func (c *GitCloneClient) FileContents(ctx context.Context, repo, path, ref string) ([]byte, error) {
+	commit, err := c.checkout(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	f, err := commit.File(path)
+	if errors.Is(err, object.ErrFileNotFound) {
+		return nil, NewClientError(http.StatusNotFound, fmt.Sprintf("file %q not found in %s at %s", path, c.repoURL, ref))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file %q from %s at %s: %w", path, c.repoURL, ref, err)
+	}
+	s, err := f.Contents()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %q from %s at %s: %w", path, c.repoURL, ref, err)
+	}
+	return []byte(s), nil
+}
+
+// PathExists implements the PathChecker interface.
+//
+// The path can be a file or a directory.
+func (c *GitCloneClient) PathExists(ctx context.Context, repo, path, ref string) (bool, error) {
+	commit, err := c.checkout(ctx, ref)
+	if err != nil {
+		return false, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return false, fmt.Errorf("failed to get the tree for %s at %s: %w", c.repoURL, ref, err)
+	}
+	_, err = tree.FindEntry(strings.Trim(path, "/"))
+	if errors.Is(err, object.ErrEntryNotFound) || errors.Is(err, object.ErrDirectoryNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to find %q in %s at %s: %w", path, c.repoURL, ref, err)
+	}
+	return true, nil
+}
+
+// ResolveRef implements the RefResolver interface.
+func (c *GitCloneClient) ResolveRef(ctx context.Context, repo, ref string) (string, error) {
+	commit, err := c.checkout(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+	return commit.Hash.String(), nil
+}
+
+// ListTags implements the TagLister interface.
+func (c *GitCloneClient) ListTags(ctx context.Context, repo string) ([]string, error) {
+	auth, err := c.auth(ctx)
+	if err != nil {
+		return nil, err
+	}
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: git.DefaultRemoteName,
+		URLs: []string{c.repoURL},
+	})
+	refs, err := remote.List(&git.ListOptions{Auth: auth})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list references in %s: %w", c.repoURL, err)
+	}
+	tags := []string{}
+	for _, r := range refs {
+		if r.Name().IsTag() {
+			tags = append(tags, r.Name().Short())
+		}
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+func (c *GitCloneClient) checkout(ctx context.Context, ref string) (*object.Commit, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if commit, ok := c.clones[ref]; ok {
+		return commit, nil
+	}
+	auth, err := c.auth(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := c.cloneRef(ctx, ref, auth)
+	if err != nil && commitSHA.MatchString(ref) && IsNotFoundOrUnauthorised(err) {
+		commit, err = c.cloneCommit(ctx, ref, auth)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.clones[ref] = commit
+	c.clones[commit.Hash.String()] = commit
+	return commit, nil
+}
+
+func (c *GitCloneClient) cloneRef(ctx context.Context, ref string, auth transport.AuthMethod) (*object.Commit, error) {
+	for _, name := range []plumbing.ReferenceName{plumbing.NewBranchReferenceName(ref), plumbing.NewTagReferenceName(ref)} {
+		r, err := git.CloneContext(ctx, memory.NewStorage(), nil, &git.CloneOptions{
+			URL:           c.repoURL,
+			Auth:          auth,
+			ReferenceName: name,
+			SingleBranch:  true,
+			Depth:         1,
+			Tags:          git.NoTags,
+		})
+		if err != nil {
+			if isReferenceNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to clone %s at %s: %w", c.repoURL, ref, err)
+		}
+		head, err := r.Head()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the HEAD of %s at %s: %w", c.repoURL, ref, err)
+		}
+		return r.CommitObject(head.Hash())
+	}
+	return nil, NewClientError(http.StatusNotFound, fmt.Sprintf("reference %q not found in %s", ref, c.repoURL))
+}
+
+func (c *GitCloneClient) cloneCommit(ctx context.Context, sha string, auth transport.AuthMethod) (*object.Commit, error) {
+	r, err := git.CloneContext(ctx, memory.NewStorage(), nil, &git.CloneOptions{
+		URL:  c.repoURL,
+		Auth: auth,
+		Tags: git.NoTags,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to clone %s: %w", c.repoURL, err)
+	}
+	h, err := r.ResolveRevision(plumbing.Revision(sha))
+	if err != nil {
+		return nil, NewClientError(http.StatusNotFound, fmt.Sprintf("commit %q not found in %s", sha, c.repoURL))
+	}
+	return r.CommitObject(*h)
+}
+
+func (c *GitCloneClient) auth(ctx context.Context) (transport.AuthMethod, error) {
+	ep, err := transport.NewEndpoint(c.repoURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse repo URL %q: %w", c.repoURL, err)
+	}
+	switch ep.Protocol {
+	case "ssh":
+		user := ep.User
+		if user == "" {
+			user = defaultGitUser
+		}
+		if c.opts.SSHKeyFile != "" {
+			keys, err := ssh.NewPublicKeysFromFile(user, c.opts.SSHKeyFile, c.opts.SSHKeyPassword)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load SSH key from %q: %w", c.opts.SSHKeyFile, err)
+			}
+			return keys, nil
+		}
+		agent, err := ssh.NewSSHAgentAuth(user)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to the SSH agent: %w", err)
+		}
+		return agent, nil
+	case "http", "https":
+		if c.opts.Credentials == nil {
+			return nil, nil
+		}
+		host := ep.Host
+		if ep.Port != 0 {
+			host = fmt.Sprintf("%s:%d", ep.Host, ep.Port)
+		}
+		creds, err := c.opts.Credentials.Credentials(ctx, host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get credentials for %q: %w", host, err)
+		}
+		if creds == nil {
+			return nil, nil
+		}
+		user := creds.Username
+		if user == "" {
+			user = defaultGitUser
+		}
+		return &githttp.BasicAuth{Username: user, Password: creds.Password}, nil
+	}
+	return nil, nil
+}
+
+func isReferenceNotFound(err error) bool {
+	return errors.Is(err, plumbing.ErrReferenceNotFound) ||
+		strings.Contains(err.Error(), "couldn't find remote ref")
+}
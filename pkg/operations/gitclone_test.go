@@ -0,0 +1,141 @@
+package operations
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bigkevmcd/askja/test"
+)
+
+var _ Client = (*GitCloneClient)(nil)
+var _ TagLister = (*GitCloneClient)(nil)
+var _ PathChecker = (*GitCloneClient)(nil)
+var _ RefResolver = (*GitCloneClient)(nil)
+
+const testProfile = `apiVersion: profiles.fluxcd.io/v1alpha1
+kind: Profile
+metadata:
+  name: nginx
+spec:
+  description: Profile for deploying nginx
+  artifacts:
+    - name: nginx-server
+      path: nginx/chart
+`
+
+func TestGitCloneClient(t *testing.T) {
+	dir, sha := test.MakeBareRepository(t, map[string]string{
+		"profile.yaml":           testProfile,
+		"nginx/chart/Chart.yaml": "name: nginx\n",
+	}, "v0.1.0")
+	c := NewGitCloneClient("file://"+dir, GitCloneOptions{})
+
+	for _, ref := range []string{"main", "v0.1.0", sha, sha[:7]} {
+		t.Run(ref, func(t *testing.T) {
+			b, err := c.FileContents(context.TODO(), "", "profile.yaml", ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(testProfile, string(b)); diff != "" {
+				t.Fatalf("failed to read file:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGitCloneClient_hex_ref_names(t *testing.T) {
+	dir, sha := test.MakeBareRepository(t, map[string]string{"profile.yaml": testProfile}, "20210101", "deadbeef")
+	c := NewGitCloneClient("file://"+dir, GitCloneOptions{})
+
+	for _, ref := range []string{"20210101", "deadbeef"} {
+		t.Run(ref, func(t *testing.T) {
+			got, err := c.ResolveRef(context.TODO(), "", ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != sha {
+				t.Fatalf("ResolveRef() got %q, want %q", got, sha)
+			}
+		})
+	}
+}
+
+func TestGitCloneClient_not_found(t *testing.T) {
+	dir, _ := test.MakeBareRepository(t, map[string]string{"profile.yaml": testProfile})
+	c := NewGitCloneClient("file://"+dir, GitCloneOptions{})
+
+	_, err := c.FileContents(context.TODO(), "", "missing.yaml", "main")
+	if !IsNotFoundOrUnauthorised(err) {
+		t.Fatalf("got error %v, want not found", err)
+	}
+
+	_, err = c.FileContents(context.TODO(), "", "profile.yaml", "unknown-branch")
+	if !IsNotFoundOrUnauthorised(err) {
+		t.Fatalf("got error %v, want not found", err)
+	}
+}
+
+func TestGitCloneClient_PathExists(t *testing.T) {
+	dir, _ := test.MakeBareRepository(t, map[string]string{
+		"profile.yaml":           testProfile,
+		"nginx/chart/Chart.yaml": "name: nginx\n",
+	})
+	c := NewGitCloneClient("file://"+dir, GitCloneOptions{})
+
+	pathTests := []struct {
+		path string
+		want bool
+	}{
+		{"nginx/chart", true},
+		{"nginx/chart/", true},
+		{"nginx/chart/Chart.yaml", true},
+		{"redis/chart", false},
+		{"nginx/missing", false},
+	}
+
+	for _, tt := range pathTests {
+		t.Run(tt.path, func(t *testing.T) {
+			exists, err := c.PathExists(context.TODO(), "", tt.path, "main")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if exists != tt.want {
+				t.Fatalf("PathExists(%q) got %v, want %v", tt.path, exists, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitCloneClient_ResolveRef(t *testing.T) {
+	dir, sha := test.MakeBareRepository(t, map[string]string{"profile.yaml": testProfile}, "v0.1.0")
+	c := NewGitCloneClient("file://"+dir, GitCloneOptions{})
+
+	for _, ref := range []string{"main", "v0.1.0", sha[:7]} {
+		t.Run(ref, func(t *testing.T) {
+			resolved, err := c.ResolveRef(context.TODO(), "", ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resolved != sha {
+				t.Fatalf("ResolveRef(%q) got %q, want %q", ref, resolved, sha)
+			}
+		})
+	}
+}
+
+func TestGitCloneClient_ListTags(t *testing.T) {
+	dir, _ := test.MakeBareRepository(t, map[string]string{"profile.yaml": testProfile}, "v0.1.0", "v0.2.0")
+	c := NewGitCloneClient("file://"+dir, GitCloneOptions{})
+
+	tags, err := c.ListTags(context.TODO(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]string{"v0.1.0", "v0.2.0"}, tags); diff != "" {
+		t.Fatalf("failed to list tags:\n%s", diff)
+	}
+}
@@ -0,0 +1,71 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const giteaTagsPageSize = 50
+
+// GiteaClient fetches files via the Gitea repository API.
+type GiteaClient struct {
+	*http.Client
+	BaseURL string
+}
+
+// NewGiteaClient returns an implementation of the client that can fetch files
+// from the Gitea server at baseURL e.g. https://gitea.com.
+func NewGiteaClient(baseURL string, c *http.Client) *GiteaClient {
+	return &GiteaClient{Client: c, BaseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// FileContents implements the Client interface.
+func (c GiteaClient) FileContents(ctx context.Context, repo, path, ref string) ([]byte, error) {
+	fileURL := fmt.Sprintf("%s/api/v1/repos/%s/raw/%s?ref=%s",
+		c.BaseURL, repo, strings.TrimPrefix(path, "/"), url.QueryEscape(ref))
+	b, err := getBody(ctx, c.Client, fileURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch file: %w", err)
+	}
+	return b, nil
+}
+
+// ResolveRef implements the RefResolver interface.
+func (c GiteaClient) ResolveRef(ctx context.Context, repo, ref string) (string, error) {
+	commitsURL := fmt.Sprintf("%s/api/v1/repos/%s/commits?sha=%s&limit=1",
+		c.BaseURL, repo, url.QueryEscape(ref))
+	var commits []struct {
+		SHA string `json:"sha"`
+	}
+	if err := getJSON(ctx, c.Client, commitsURL, &commits); err != nil {
+		return "", fmt.Errorf("failed to resolve ref %q: %w", ref, err)
+	}
+	if len(commits) == 0 {
+		return "", NewClientError(http.StatusNotFound, fmt.Sprintf("ref %q not found in %s", ref, repo))
+	}
+	return commits[0].SHA, nil
+}
+
+// ListTags implements the TagLister interface.
+func (c GiteaClient) ListTags(ctx context.Context, repo string) ([]string, error) {
+	tags := []string{}
+	for page := 1; ; page++ {
+		tagsURL := fmt.Sprintf("%s/api/v1/repos/%s/tags?limit=%d&page=%d",
+			c.BaseURL, repo, giteaTagsPageSize, page)
+		var found []struct {
+			Name string `json:"name"`
+		}
+		if err := getJSON(ctx, c.Client, tagsURL, &found); err != nil {
+			return nil, fmt.Errorf("failed to fetch tags: %w", err)
+		}
+		for _, t := range found {
+			tags = append(tags, t.Name)
+		}
+		if len(found) < giteaTagsPageSize {
+			return tags, nil
+		}
+	}
+}
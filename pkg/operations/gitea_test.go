@@ -0,0 +1,57 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ Client = (*GiteaClient)(nil)
+var _ TagLister = (*GiteaClient)(nil)
+var _ RefResolver = (*GiteaClient)(nil)
+
+func TestGiteaClient(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/test/repo/raw/profile.yaml" || r.URL.Query().Get("ref") != "v0.1.0" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "testing")
+	}))
+	t.Cleanup(ts.Close)
+	c := NewGiteaClient(ts.URL, ts.Client())
+
+	b, err := c.FileContents(context.TODO(), "test/repo", "profile.yaml", "v0.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "testing" {
+		t.Fatalf("got %s, want %s", b, "testing")
+	}
+}
+
+func TestGiteaClient_ListTags(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/test/repo/tags" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `[{"name": "v0.2.0"}, {"name": "v0.1.0"}]`)
+	}))
+	t.Cleanup(ts.Close)
+	c := NewGiteaClient(ts.URL, ts.Client())
+
+	tags, err := c.ListTags(context.TODO(), "test/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]string{"v0.2.0", "v0.1.0"}, tags); diff != "" {
+		t.Fatalf("failed to list tags:\n%s", diff)
+	}
+}
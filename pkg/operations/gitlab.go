@@ -0,0 +1,68 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const gitlabTagsPageSize = 100
+
+// GitLabClient fetches files via the GitLab repository files API.
+type GitLabClient struct {
+	*http.Client
+	BaseURL string
+}
+
+// NewGitLabClient returns an implementation of the client that can fetch files
+// from the GitLab server at baseURL e.g. https://gitlab.com.
+func NewGitLabClient(baseURL string, c *http.Client) *GitLabClient {
+	return &GitLabClient{Client: c, BaseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// FileContents implements the Client interface.
+func (c GitLabClient) FileContents(ctx context.Context, repo, path, ref string) ([]byte, error) {
+	fileURL := fmt.Sprintf("%s/api/v4/projects/%s/repository/files/%s/raw?ref=%s",
+		c.BaseURL, url.PathEscape(repo), url.PathEscape(path), url.QueryEscape(ref))
+	b, err := getBody(ctx, c.Client, fileURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch file: %w", err)
+	}
+	return b, nil
+}
+
+// ResolveRef implements the RefResolver interface.
+func (c GitLabClient) ResolveRef(ctx context.Context, repo, ref string) (string, error) {
+	commitURL := fmt.Sprintf("%s/api/v4/projects/%s/repository/commits/%s",
+		c.BaseURL, url.PathEscape(repo), url.PathEscape(ref))
+	var commit struct {
+		ID string `json:"id"`
+	}
+	if err := getJSON(ctx, c.Client, commitURL, &commit); err != nil {
+		return "", fmt.Errorf("failed to resolve ref %q: %w", ref, err)
+	}
+	return commit.ID, nil
+}
+
+// ListTags implements the TagLister interface.
+func (c GitLabClient) ListTags(ctx context.Context, repo string) ([]string, error) {
+	tags := []string{}
+	for page := 1; ; page++ {
+		tagsURL := fmt.Sprintf("%s/api/v4/projects/%s/repository/tags?per_page=%d&page=%d",
+			c.BaseURL, url.PathEscape(repo), gitlabTagsPageSize, page)
+		var found []struct {
+			Name string `json:"name"`
+		}
+		if err := getJSON(ctx, c.Client, tagsURL, &found); err != nil {
+			return nil, fmt.Errorf("failed to fetch tags: %w", err)
+		}
+		for _, t := range found {
+			tags = append(tags, t.Name)
+		}
+		if len(found) < gitlabTagsPageSize {
+			return tags, nil
+		}
+	}
+}
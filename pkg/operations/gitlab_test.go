@@ -0,0 +1,77 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ Client = (*GitLabClient)(nil)
+var _ TagLister = (*GitLabClient)(nil)
+var _ RefResolver = (*GitLabClient)(nil)
+
+func TestGitLabClient(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/api/v4/projects/test%2Fgroup%2Frepo/repository/files/profile.yaml/raw" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Query().Get("ref") != "main" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "testing")
+	}))
+	t.Cleanup(ts.Close)
+	c := NewGitLabClient(ts.URL, ts.Client())
+
+	b, err := c.FileContents(context.TODO(), "test/group/repo", "profile.yaml", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "testing" {
+		t.Fatalf("got %s, want %s", b, "testing")
+	}
+}
+
+func TestGitLabClient_not_found(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(ts.Close)
+	c := NewGitLabClient(ts.URL, ts.Client())
+
+	_, err := c.FileContents(context.TODO(), "test/repo", "profile.yaml", "main")
+
+	want := fmt.Sprintf("failed to fetch file: client error: code 404, message: failed to fetch %s/api/v4/projects/test%%2Frepo/repository/files/profile.yaml/raw?ref=main", ts.URL)
+	if err == nil || err.Error() != want {
+		t.Fatalf("got error %v, want %q", err, want)
+	}
+	if !IsNotFoundOrUnauthorised(err) {
+		t.Fatalf("IsNotFoundOrUnauthorised(%v) got false, want true", err)
+	}
+}
+
+func TestGitLabClient_ListTags(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/api/v4/projects/test%2Frepo/repository/tags" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `[{"name": "v0.2.0"}, {"name": "v0.1.0"}]`)
+	}))
+	t.Cleanup(ts.Close)
+	c := NewGitLabClient(ts.URL, ts.Client())
+
+	tags, err := c.ListTags(context.TODO(), "test/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]string{"v0.2.0", "v0.1.0"}, tags); diff != "" {
+		t.Fatalf("failed to list tags:\n%s", diff)
+	}
+}
@@ -0,0 +1,116 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"sigs.k8s.io/yaml"
+)
+
+// indexFile is the subset of a Helm chart repository index.yaml that is
+// needed to find the versions of a chart.
+type indexFile struct {
+	Entries map[string][]chartVersion `json:"entries"`
+}
+
+type chartVersion struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// fetchIndex downloads and parses the index.yaml from a Helm chart
+// repository.
+func fetchIndex(ctx context.Context, c *http.Client, repoURL string) (*indexFile, error) {
+	indexURL := strings.TrimSuffix(repoURL, "/") + "/index.yaml"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, indexURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request for %s: %w", indexURL, err)
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", indexURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: status code %d", indexURL, resp.StatusCode)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", indexURL, err)
+	}
+	idx := &indexFile{}
+	if err := yaml.Unmarshal(b, idx); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", indexURL, err)
+	}
+	return idx, nil
+}
+
+// versions returns the versions of the chart in the index.
+func (idx *indexFile) versions(chart string) ([]string, error) {
+	entries, ok := idx.Entries[chart]
+	if !ok || len(entries) == 0 {
+		return nil, fmt.Errorf("chart %q not found in the repository", chart)
+	}
+	versions := []string{}
+	for _, e := range entries {
+		versions = append(versions, e.Version)
+	}
+	return versions, nil
+}
+
+// resolveVersion returns the version of the chart from the available versions
+// that matches the requested version.
+//
+// The version can be an exact version, or a semver constraint, in which case
+// the highest matching version is returned.
+func resolveVersion(chart, version string, versions []string) (string, error) {
+	available := sortedVersions(versions)
+
+	if want, err := semver.NewVersion(version); err == nil {
+		for _, v := range available {
+			if v.Equal(want) {
+				return v.Original(), nil
+			}
+		}
+		return "", versionNotFoundError(chart, version, available)
+	}
+
+	c, err := semver.NewConstraint(version)
+	if err != nil {
+		return "", fmt.Errorf("invalid version %q for chart %q: %w", version, chart, err)
+	}
+	for _, v := range available {
+		if c.Check(v) {
+			return v.Original(), nil
+		}
+	}
+	return "", versionNotFoundError(chart, version, available)
+}
+
+// sortedVersions returns the valid semantic versions in the entries, highest
+// first.
+func sortedVersions(versions []string) []*semver.Version {
+	sorted := []*semver.Version{}
+	for _, s := range versions {
+		v, err := semver.NewVersion(s)
+		if err != nil {
+			continue
+		}
+		sorted = append(sorted, v)
+	}
+	sort.Sort(sort.Reverse(semver.Collection(sorted)))
+	return sorted
+}
+
+func versionNotFoundError(chart, version string, available []*semver.Version) error {
+	s := []string{}
+	for _, v := range available {
+		s = append(s, v.Original())
+	}
+	return fmt.Errorf("version %q of chart %q not found, available versions: %s", version, chart, strings.Join(s, ", "))
+}
@@ -2,13 +2,31 @@ package helm
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 
-	sourcev1beta1 "github.com/fluxcd/source-controller/api/v1beta1"
 	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+
+	"github.com/bigkevmcd/askja/pkg/git"
+	"github.com/bigkevmcd/askja/pkg/operations"
+	"github.com/bigkevmcd/askja/pkg/profiles"
+)
+
+const (
+	defaultFileMode os.FileMode = 0644
+	profileFilename             = "profile.yaml"
+	profileKind                 = "Profile"
 )
 
 type HelmChart struct {
@@ -21,39 +39,316 @@ type HelmChart struct {
 }
 
 type InstallOptions struct {
-	Chart     HelmChart
-	Profile   string
+	Chart   HelmChart
+	Profile string
+	// Namespace is the namespace for the HelmRelease.
 	Namespace string
+	// TargetNamespace is the namespace that the chart is deployed to, this is
+	// recorded as the namespace of the artifact in the profile.
+	TargetNamespace string
+	// SourceNamespace is the namespace for the HelmRepository, if this is
+	// empty, the HelmRepository is in the Namespace.
+	SourceNamespace string
+	// CreateNamespace adds Namespace resources for the Namespace and the
+	// TargetNamespace.
+	CreateNamespace bool
+	// NamePrefix is the prefix for the names of the generated resources, if
+	// this is empty, profiles.DefaultNamePrefix is used.
+	NamePrefix    string
+	NewBranchName string
+	// Values are the Helm values for the chart, keyed by artifact name as in
+	// profiles.ProfileOptions.
+	Values map[string]*profiles.ReleaseValues
+	// HTTPClient is used to fetch the chart repository index, if this is nil,
+	// operations.DefaultHTTPClient is used.
+	HTTPClient *http.Client
+	// OutputDir is the directory in the repository to write the generated
+	// resources, and the kustomization.yaml, to, if this is empty, the
+	// profile directory is used.
+	OutputDir string
+	// Layout is how the generated resources are laid out in the OutputDir, if
+	// this is empty, operations.FlatLayout is used, the single file layout is
+	// not supported because the resources are added to an existing profile.
+	Layout operations.Layout
+}
+
+// Install adds the Helm chart to a profile in the git repository at path,
+// and commits the changes to a new branch.
+//
+// The chart is resolved, and the resources are generated, before the branch
+// is created, so that a failure leaves the repository unchanged.
+func Install(ctx context.Context, path string, opts *InstallOptions) error {
+	g, err := git.New(path)
+	if err != nil {
+		return err
+	}
+	install, err := prepareHelmChart(ctx, g.Filesystem(), opts)
+	if err != nil {
+		return err
+	}
+	if err := g.CreateAndSwitchBranch(opts.NewBranchName); err != nil {
+		return err
+	}
+	if err := install.write(g.Filesystem()); err != nil {
+		return err
+	}
+	if err := g.Add(profilePath(opts.Profile)); err != nil {
+		return err
+	}
+	if err := g.Add(install.kustomizationFilename); err != nil {
+		return err
+	}
+	for name := range install.files {
+		if err := g.Add(name); err != nil {
+			return err
+		}
+	}
+	_, err = g.Commit(fmt.Sprintf("Add Helm chart %s to profile %s", opts.Chart.Name, opts.Profile), &git.CommitOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to commit changes to local-repo: %w", err)
+	}
+	return nil
 }
 
+// InstallHelmChart generates the HelmRepository and HelmRelease for the chart,
+// and adds the chart as an artifact to the profile.yaml in the profile
+// directory, creating the profile if necessary, the generated resources are
+// added to the kustomization.yaml in the profile directory.
+//
+// The chart and version are checked against the chart repository's index, or
+// the registry's tags for OCI repositories, before anything is written, if the
+// version is a semver constraint, it's resolved to the highest matching
+// version.
+//
+// The generated resources are written to the filesystem and returned by
+// filename.
 func InstallHelmChart(ctx context.Context, fs billy.Filesystem, opts *InstallOptions) (map[string]runtime.Object, error) {
-	files := map[string]runtime.Object{
-		filepath.Join("profiles", opts.Profile, makeFilename(opts, "helm-release")): makeHelmRepository(opts),
+	install, err := prepareHelmChart(ctx, fs, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := install.write(fs); err != nil {
+		return nil, err
 	}
+	return install.files, nil
+}
 
-	return files, nil
+// chartInstall is the changes to a profile directory for installing a chart.
+type chartInstall struct {
+	profileName           string
+	profile               *profiles.Profile
+	files                 map[string]runtime.Object
+	kustomizationFilename string
+	kustomization         []byte
 }
 
-func makeFilename(opts *InstallOptions, suffix string) string {
-	return strings.Join([]string{opts.Chart.Name, "chart", suffix}, "-") + ".yaml"
+// prepareHelmChart resolves the chart and generates the changes to the
+// profile, without writing anything.
+func prepareHelmChart(ctx context.Context, fs billy.Filesystem, opts *InstallOptions) (*chartInstall, error) {
+	if err := validateProfileName(opts.Profile); err != nil {
+		return nil, err
+	}
+	if opts.Layout == operations.SingleFileLayout {
+		return nil, fmt.Errorf("the %s layout is not supported when adding a chart to a profile", opts.Layout)
+	}
+	outputDir := opts.OutputDir
+	if outputDir == "" {
+		outputDir = path.Join("profiles", opts.Profile)
+	}
+	version, err := resolveChartVersion(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	p, err := readProfile(fs, opts.Profile)
+	if err != nil {
+		return nil, err
+	}
+	artifact := makeArtifact(opts, version)
+	for _, a := range p.Spec.Artifacts {
+		if a.Name == artifact.Name {
+			return nil, fmt.Errorf("artifact %q already exists in profile %q", artifact.Name, opts.Profile)
+		}
+	}
+	p.Spec.Artifacts = append(p.Spec.Artifacts, artifact)
+
+	objects, err := makeObjects(opts, artifact)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string]runtime.Object{}
+	resources := []string{}
+	for _, o := range objects {
+		name, err := operations.ManifestFilename(outputDir, opts.Layout, opts.Profile, o)
+		if err != nil {
+			return nil, err
+		}
+		resource, err := filepath.Rel(outputDir, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the path of %q in %q: %w", name, outputDir, err)
+		}
+		files[name] = o
+		resources = append(resources, filepath.ToSlash(resource))
+	}
+	sort.Strings(resources)
+	name, b, err := operations.UpdateKustomization(fs, outputDir, resources, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &chartInstall{
+		profileName:           opts.Profile,
+		profile:               p,
+		files:                 files,
+		kustomizationFilename: name,
+		kustomization:         b,
+	}, nil
 }
 
-func flatten(s string) string {
-	return strings.ReplaceAll(strings.ToLower(s), "/", "-")
+func (c *chartInstall) write(fs billy.Filesystem) error {
+	if err := writeProfile(fs, c.profileName, c.profile); err != nil {
+		return err
+	}
+	for name, o := range c.files {
+		if err := writeYAML(fs, name, o); err != nil {
+			return err
+		}
+	}
+	if err := util.WriteFile(fs, c.kustomizationFilename, c.kustomization, defaultFileMode); err != nil {
+		return fmt.Errorf("failed to write to file %q: %w", c.kustomizationFilename, err)
+	}
+	return nil
 }
 
-func makeHelmRepository(o *InstallOptions) *sourcev1beta1.HelmRepository {
-	return &sourcev1beta1.HelmRepository{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "",
-			Namespace: o.Namespace,
-		},
-		TypeMeta: metav1.TypeMeta{
-			Kind:       sourcev1beta1.HelmRepositoryKind,
-			APIVersion: sourcev1beta1.GroupVersion.String(),
-		},
-		Spec: sourcev1beta1.HelmRepositorySpec{
-			URL: o.Chart.URL,
+// Generate returns the HelmRepository and HelmRelease that InstallHelmChart
+// would write for the chart, without reading or changing the profile.
+//
+// The chart and version are checked in the same way as InstallHelmChart.
+func Generate(ctx context.Context, opts *InstallOptions) ([]runtime.Object, error) {
+	if err := validateProfileName(opts.Profile); err != nil {
+		return nil, err
+	}
+	version, err := resolveChartVersion(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return makeObjects(opts, makeArtifact(opts, version))
+}
+
+func makeObjects(opts *InstallOptions, artifact profiles.Artifact) ([]runtime.Object, error) {
+	objects, err := profiles.MakeArtifacts(&profiles.Profile{
+		Spec: profiles.ProfileSpec{Artifacts: []profiles.Artifact{artifact}},
+	}, &profiles.ProfileOptions{
+		Values:          opts.Values,
+		Namespace:       opts.Namespace,
+		SourceNamespace: opts.SourceNamespace,
+		CreateNamespace: opts.CreateNamespace,
+		NamePrefix:      opts.NamePrefix,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to make artifacts for chart %q: %w", opts.Chart.Name, err)
+	}
+	return objects, nil
+}
+
+// validateProfileName returns an error if the profile name is not a valid
+// DNS-1123 label, the name is used as the profile directory, so this also
+// prevents writing outside the profiles directory.
+func validateProfileName(name string) error {
+	if msgs := validation.IsDNS1123Label(name); len(msgs) > 0 {
+		return fmt.Errorf("invalid profile name %q: %s", name, strings.Join(msgs, ", "))
+	}
+	return nil
+}
+
+func profilePath(name string) string {
+	return filepath.Join("profiles", name, profileFilename)
+}
+
+func writeYAML(fs billy.Filesystem, name string, v interface{}) error {
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal: %w", err)
+	}
+	if err := util.WriteFile(fs, name, b, defaultFileMode); err != nil {
+		return fmt.Errorf("failed to write to file %q: %w", name, err)
+	}
+	return nil
+}
+
+// writeProfile writes the profile in the apiVersion that it was read in, so
+// that existing profiles are not converted.
+func writeProfile(fs billy.Filesystem, name string, p *profiles.Profile) error {
+	b, err := profiles.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if err := util.WriteFile(fs, profilePath(name), b, defaultFileMode); err != nil {
+		return fmt.Errorf("failed to write to file %q: %w", profilePath(name), err)
+	}
+	return nil
+}
+
+func readProfile(fs billy.Filesystem, name string) (*profiles.Profile, error) {
+	f, err := fs.Open(profilePath(name))
+	if os.IsNotExist(err) {
+		return &profiles.Profile{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       profileKind,
+				APIVersion: profiles.LatestAPIVersion,
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+			},
+		}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the profile %q: %w", name, err)
+	}
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the profile %q: %w", name, err)
+	}
+	return profiles.ParseBytes(b)
+}
+
+func resolveChartVersion(ctx context.Context, opts *InstallOptions) (string, error) {
+	client := opts.HTTPClient
+	if client == nil {
+		client = operations.DefaultHTTPClient
+	}
+	if profiles.IsOCIRepository(opts.Chart.URL) {
+		tags, err := fetchOCITags(ctx, client, opts.Chart.URL, chartName(opts))
+		if err != nil {
+			return "", fmt.Errorf("failed to list the chart tags in the registry: %w", err)
+		}
+		return resolveVersion(chartName(opts), opts.Chart.Version, tags)
+	}
+	idx, err := fetchIndex(ctx, client, opts.Chart.URL)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch the chart repository index: %w", err)
+	}
+	versions, err := idx.versions(chartName(opts))
+	if err != nil {
+		return "", err
+	}
+	return resolveVersion(chartName(opts), opts.Chart.Version, versions)
+}
+
+func makeArtifact(opts *InstallOptions, version string) profiles.Artifact {
+	return profiles.Artifact{
+		Name:      chartName(opts),
+		Namespace: opts.TargetNamespace,
+		Chart: &profiles.HelmChartSpec{
+			Chart:      chartName(opts),
+			Repository: opts.Chart.URL,
+			Version:    version,
 		},
 	}
 }
+
+// chartName returns the name of the chart without the repository alias
+// e.g. bitnami/redis is redis.
+func chartName(opts *InstallOptions) string {
+	return path.Base(opts.Chart.Name)
+}
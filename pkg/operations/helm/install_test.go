@@ -2,44 +2,683 @@ package helm
 
 import (
 	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
 	"testing"
+	"time"
 
-	"github.com/bigkevmcd/askja/test"
+	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/google/go-cmp/cmp"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 
+	helmv2beta1 "github.com/fluxcd/helm-controller/api/v2beta1"
 	sourcev1beta1 "github.com/fluxcd/source-controller/api/v1beta1"
+
+	"github.com/bigkevmcd/askja/pkg/operations"
+	"github.com/bigkevmcd/askja/pkg/profiles"
+	"github.com/bigkevmcd/askja/test"
+)
+
+const (
+	testRepositoryName = "subscription-helm-repository-charts-bitnami-com-bitnami"
+	testOCIRepoURL     = "oci://registry.example.com/charts"
 )
 
+var testInterval = metav1.Duration{Duration: 5 * time.Minute}
+
 func TestInstallHelm(t *testing.T) {
 	fs := osfs.New(test.MakeTempDir(t))
 	files, err := InstallHelmChart(context.TODO(), fs, &InstallOptions{
 		Chart: HelmChart{
 			URL:     "https://charts.bitnami.com/bitnami",
 			Name:    "bitnami/redis",
-			Version: "6.2.1",
+			Version: "12.10.0",
 		},
-		Profile:   "test-profile",
-		Namespace: "test-namespace",
+		Profile:    "test-profile",
+		Namespace:  "test-namespace",
+		HTTPClient: newTestIndexClient(t),
 	})
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	want := map[string]runtime.Object{
-		"profiles/test-profile/bitnami/redis-chart-helm-release.yaml": &sourcev1beta1.HelmRepository{
+		"profiles/test-profile/helmrepository_" + testRepositoryName + ".yaml": &sourcev1beta1.HelmRepository{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       sourcev1beta1.HelmRepositoryKind,
+				APIVersion: sourcev1beta1.GroupVersion.String(),
+			},
+			ObjectMeta: metav1.ObjectMeta{Name: testRepositoryName, Namespace: "test-namespace"},
+			Spec:       sourcev1beta1.HelmRepositorySpec{URL: "https://charts.bitnami.com/bitnami", Interval: testInterval},
+		},
+		"profiles/test-profile/helmrelease_subscription-helm-release-redis.yaml": &helmv2beta1.HelmRelease{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       helmv2beta1.HelmReleaseKind,
+				APIVersion: helmv2beta1.GroupVersion.String(),
+			},
+			ObjectMeta: metav1.ObjectMeta{Name: "subscription-helm-release-redis", Namespace: "test-namespace"},
+			Spec: helmv2beta1.HelmReleaseSpec{
+				Interval: testInterval,
+				Chart: helmv2beta1.HelmChartTemplate{
+					Spec: helmv2beta1.HelmChartTemplateSpec{
+						Chart:   "redis",
+						Version: "12.10.0",
+						SourceRef: helmv2beta1.CrossNamespaceObjectReference{
+							Kind: sourcev1beta1.HelmRepositoryKind,
+							Name: testRepositoryName,
+						},
+					},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, files); diff != "" {
+		t.Fatalf("failed to generate installation resources:\n%s", diff)
+	}
+
+	wantProfile := &profiles.Profile{
+		TypeMeta:   metav1.TypeMeta{Kind: "Profile", APIVersion: "profiles.fluxcd.io/v1alpha2"},
+		ObjectMeta: metav1.ObjectMeta{Name: "test-profile"},
+		Spec: profiles.ProfileSpec{
+			Artifacts: []profiles.Artifact{
+				{
+					Name:  "redis",
+					Chart: &profiles.HelmChartSpec{Chart: "redis", Repository: "https://charts.bitnami.com/bitnami", Version: "12.10.0"},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(wantProfile, readTestProfile(t, fs, "test-profile")); diff != "" {
+		t.Fatalf("failed to update the profile:\n%s", diff)
+	}
+
+	b := readTestFile(t, fs, "profiles/test-profile/kustomization.yaml")
+	wantKustomization := "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n  - helmrelease_subscription-helm-release-redis.yaml\n  - helmrepository_" + testRepositoryName + ".yaml\n"
+	if diff := cmp.Diff(wantKustomization, string(b)); diff != "" {
+		t.Fatalf("failed to update the kustomization:\n%s", diff)
+	}
+}
+
+func TestInstallHelm_namespaces(t *testing.T) {
+	fs := osfs.New(test.MakeTempDir(t))
+	files, err := InstallHelmChart(context.TODO(), fs, &InstallOptions{
+		Chart: HelmChart{
+			URL:     "https://charts.bitnami.com/bitnami",
+			Name:    "bitnami/redis",
+			Version: "12.10.0",
+		},
+		Profile:         "test-profile",
+		Namespace:       "apps",
+		TargetNamespace: "redis",
+		SourceNamespace: "flux-system",
+		CreateNamespace: true,
+		HTTPClient:      newTestIndexClient(t),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"profiles/test-profile/helmrelease_subscription-helm-release-redis.yaml",
+		"profiles/test-profile/helmrepository_" + testRepositoryName + ".yaml",
+		"profiles/test-profile/namespace_apps.yaml",
+		"profiles/test-profile/namespace_redis.yaml",
+	}
+	if diff := cmp.Diff(want, filenames(files)); diff != "" {
+		t.Fatalf("failed to generate installation resources:\n%s", diff)
+	}
+	hr := files["profiles/test-profile/helmrelease_subscription-helm-release-redis.yaml"].(*helmv2beta1.HelmRelease)
+	if hr.Namespace != "apps" || hr.Spec.TargetNamespace != "redis" || hr.Spec.Chart.Spec.SourceRef.Namespace != "flux-system" {
+		t.Fatalf("got namespace %q, target namespace %q and source namespace %q", hr.Namespace, hr.Spec.TargetNamespace, hr.Spec.Chart.Spec.SourceRef.Namespace)
+	}
+	if ns := readTestProfile(t, fs, "test-profile").Spec.Artifacts[0].Namespace; ns != "redis" {
+		t.Fatalf("got artifact namespace %q, want redis", ns)
+	}
+}
+
+func filenames(files map[string]runtime.Object) []string {
+	names := []string{}
+	for k := range files {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestInstallHelm_existing_profile(t *testing.T) {
+	fs := osfs.New(test.MakeTempDir(t))
+	client := newTestIndexClient(t)
+	chart := func(name string) *InstallOptions {
+		return &InstallOptions{
+			Chart: HelmChart{
+				URL:     "https://charts.bitnami.com/bitnami",
+				Name:    "bitnami/" + name,
+				Version: "*",
+			},
+			Profile:    "test-profile",
+			HTTPClient: client,
+		}
+	}
+	if _, err := InstallHelmChart(context.TODO(), fs, chart("redis")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := InstallHelmChart(context.TODO(), fs, chart("nginx")); err != nil {
+		t.Fatal(err)
+	}
+
+	p := readTestProfile(t, fs, "test-profile")
+	names := []string{}
+	for _, a := range p.Spec.Artifacts {
+		names = append(names, a.Name)
+	}
+	if diff := cmp.Diff([]string{"redis", "nginx"}, names); diff != "" {
+		t.Fatalf("failed to update the profile:\n%s", diff)
+	}
+
+	_, err := InstallHelmChart(context.TODO(), fs, chart("redis"))
+	want := `artifact "redis" already exists in profile "test-profile"`
+	if err == nil || err.Error() != want {
+		t.Fatalf("got error %v, want %q", err, want)
+	}
+}
+
+func TestInstallHelm_keeps_profile_version(t *testing.T) {
+	fs := osfs.New(test.MakeTempDir(t))
+	profile := `apiVersion: profiles.fluxcd.io/v1alpha1
+kind: Profile
+metadata:
+  name: test-profile
+spec:
+  artifacts:
+    - name: nginx-server
+      path: nginx/chart
+`
+	if err := util.WriteFile(fs, profilePath("test-profile"), []byte(profile), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := InstallHelmChart(context.TODO(), fs, &InstallOptions{
+		Chart: HelmChart{
+			URL:     "https://charts.bitnami.com/bitnami",
+			Name:    "bitnami/redis",
+			Version: "12.10.0",
+		},
+		Profile:    "test-profile",
+		HTTPClient: newTestIndexClient(t),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := readTestProfile(t, fs, "test-profile")
+	if p.APIVersion != "profiles.fluxcd.io/v1alpha1" {
+		t.Fatalf("got apiVersion %q, want profiles.fluxcd.io/v1alpha1", p.APIVersion)
+	}
+	if l := len(p.Spec.Artifacts); l != 2 {
+		t.Fatalf("got %d artifacts, want 2", l)
+	}
+}
+
+func TestInstall(t *testing.T) {
+	dir, _ := test.MakeTempGitRepo(t)
+
+	err := Install(context.TODO(), dir, &InstallOptions{
+		Chart: HelmChart{
+			URL:     "https://charts.bitnami.com/bitnami",
+			Name:    "bitnami/redis",
+			Version: "12.10.0",
+		},
+		Profile:       "test-profile",
+		NewBranchName: "test-branch",
+		HTTPClient:    newTestIndexClient(t),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := r.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Name() != plumbing.NewBranchReferenceName("test-branch") {
+		t.Fatalf("got HEAD %s, want test-branch", head.Name())
+	}
+	co, err := r.CommitObject(head.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	committed := []string{}
+	for k := range test.GetFilesInCommit(t, co, dir) {
+		committed = append(committed, k)
+	}
+	sort.Strings(committed)
+	want := []string{
+		"profiles/test-profile/helmrelease_subscription-helm-release-redis.yaml",
+		"profiles/test-profile/helmrepository_" + testRepositoryName + ".yaml",
+		"profiles/test-profile/kustomization.yaml",
+		"profiles/test-profile/profile.yaml",
+	}
+	if diff := cmp.Diff(want, committed); diff != "" {
+		t.Fatalf("written files don't match:\n%s", diff)
+	}
+}
+
+func TestInstall_invalid_chart(t *testing.T) {
+	dir, _ := test.MakeTempGitRepo(t)
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err := r.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Install(context.TODO(), dir, &InstallOptions{
+		Chart: HelmChart{
+			URL:     "https://charts.bitnami.com/bitnami",
+			Name:    "bitnami/redis",
+			Version: "9.9.9",
+		},
+		Profile:       "test-profile",
+		NewBranchName: "test-branch",
+		HTTPClient:    newTestIndexClient(t),
+	})
+	if err == nil {
+		t.Fatal("expected an error installing an invalid chart version")
+	}
+
+	head, err := r.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Name() != before.Name() {
+		t.Fatalf("got HEAD %s, want %s", head.Name(), before.Name())
+	}
+	if _, err := r.Reference(plumbing.NewBranchReferenceName("test-branch"), false); err == nil {
+		t.Fatal("branch was created for an invalid chart")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	objs, err := Generate(context.TODO(), &InstallOptions{
+		Chart: HelmChart{
+			URL:     "https://charts.bitnami.com/bitnami",
+			Name:    "bitnami/redis",
+			Version: "~12.10",
+		},
+		Profile:    "test-profile",
+		Namespace:  "test-namespace",
+		HTTPClient: newTestIndexClient(t),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []runtime.Object{
+		&sourcev1beta1.HelmRepository{
 			TypeMeta: metav1.TypeMeta{
 				Kind:       sourcev1beta1.HelmRepositoryKind,
 				APIVersion: sourcev1beta1.GroupVersion.String(),
 			},
-			ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace"},
-			Spec:       sourcev1beta1.HelmRepositorySpec{URL: "https://charts.bitnami.com/bitnami"},
+			ObjectMeta: metav1.ObjectMeta{Name: testRepositoryName, Namespace: "test-namespace"},
+			Spec:       sourcev1beta1.HelmRepositorySpec{URL: "https://charts.bitnami.com/bitnami", Interval: testInterval},
+		},
+		&helmv2beta1.HelmRelease{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       helmv2beta1.HelmReleaseKind,
+				APIVersion: helmv2beta1.GroupVersion.String(),
+			},
+			ObjectMeta: metav1.ObjectMeta{Name: "subscription-helm-release-redis", Namespace: "test-namespace"},
+			Spec: helmv2beta1.HelmReleaseSpec{
+				Interval: testInterval,
+				Chart: helmv2beta1.HelmChartTemplate{
+					Spec: helmv2beta1.HelmChartTemplateSpec{
+						Chart:   "redis",
+						Version: "12.10.0",
+						SourceRef: helmv2beta1.CrossNamespaceObjectReference{
+							Kind: sourcev1beta1.HelmRepositoryKind,
+							Name: testRepositoryName,
+						},
+					},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, objs); diff != "" {
+		t.Fatalf("failed to generate the resources:\n%s", diff)
+	}
+}
+
+func TestInstallHelm_versions(t *testing.T) {
+	versionTests := []struct {
+		chart   string
+		version string
+		want    string
+	}{
+		{"bitnami/redis", "12.9.2", "12.9.2"},
+		{"bitnami/redis", "v12.9.2", "12.9.2"},
+		{"bitnami/redis", "~12.8", "12.8.3"},
+		{"bitnami/redis", ">=12.0.0 <13.0.0", "12.10.0"},
+		{"bitnami/redis", "*", "12.10.0"},
+		{"nginx", "8.7.1", "8.7.1"},
+	}
+
+	client := newTestIndexClient(t)
+	for _, tt := range versionTests {
+		t.Run(tt.chart+"@"+tt.version, func(t *testing.T) {
+			fs := osfs.New(test.MakeTempDir(t))
+			_, err := InstallHelmChart(context.TODO(), fs, &InstallOptions{
+				Chart: HelmChart{
+					URL:     "https://charts.bitnami.com/bitnami",
+					Name:    tt.chart,
+					Version: tt.version,
+				},
+				Profile:    "test-profile",
+				HTTPClient: client,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := readTestProfile(t, fs, "test-profile")
+			if v := p.Spec.Artifacts[0].Chart.Version; v != tt.want {
+				t.Fatalf("got version %q, want %q", v, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstallHelm_errors(t *testing.T) {
+	errorTests := []struct {
+		url     string
+		chart   string
+		version string
+		wantErr string
+	}{
+		{
+			"https://charts.bitnami.com/bitnami", "bitnami/redis", "9.9.9",
+			`version "9.9.9" of chart "redis" not found, available versions: 13.0.0-rc.1, 12.10.0, 12.9.2, 12.8.3`,
+		},
+		{
+			"https://charts.bitnami.com/bitnami", "bitnami/nginx", "^9.0.0",
+			`version "^9.0.0" of chart "nginx" not found, available versions: 8.8.0, 8.7.1`,
+		},
+		{
+			"https://charts.bitnami.com/bitnami", "bitnami/unknown", "1.0.0",
+			`chart "unknown" not found in the repository`,
+		},
+		{
+			"https://charts.bitnami.com/missing", "bitnami/redis", "1.0.0",
+			`failed to fetch the chart repository index: failed to fetch https://charts.bitnami.com/missing/index.yaml: status code 404`,
+		},
+	}
+
+	client := newTestIndexClient(t)
+	for _, tt := range errorTests {
+		t.Run(tt.chart+"@"+tt.version, func(t *testing.T) {
+			fs := osfs.New(test.MakeTempDir(t))
+			_, err := InstallHelmChart(context.TODO(), fs, &InstallOptions{
+				Chart: HelmChart{
+					URL:     tt.url,
+					Name:    tt.chart,
+					Version: tt.version,
+				},
+				Profile:    "test-profile",
+				HTTPClient: client,
+			})
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+			if _, err := fs.Stat(profilePath("test-profile")); err == nil {
+				t.Fatal("profile was written for an invalid chart")
+			}
+		})
+	}
+}
+
+func TestInstallHelm_invalid_profile_name(t *testing.T) {
+	nameTests := []struct {
+		profile string
+		wantErr string
+	}{
+		{"../../x", `invalid profile name "../../x": a lowercase RFC 1123 label must consist of lower case alphanumeric characters or '-', and must start and end with an alphanumeric character (e.g. 'my-name',  or '123-abc', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?')`},
+		{"", `invalid profile name "": a lowercase RFC 1123 label must consist of lower case alphanumeric characters or '-', and must start and end with an alphanumeric character (e.g. 'my-name',  or '123-abc', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?')`},
+	}
+
+	for _, tt := range nameTests {
+		t.Run(tt.profile, func(t *testing.T) {
+			dir := test.MakeTempDir(t)
+			fs := osfs.New(dir)
+			_, err := InstallHelmChart(context.TODO(), fs, &InstallOptions{
+				Chart: HelmChart{
+					URL:     "https://charts.bitnami.com/bitnami",
+					Name:    "bitnami/redis",
+					Version: "12.10.0",
+				},
+				Profile:    tt.profile,
+				HTTPClient: newTestIndexClient(t),
+			})
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+			if entries, err := fs.ReadDir("/"); err != nil || len(entries) != 0 {
+				t.Fatalf("files were written for an invalid profile name: %v", entries)
+			}
+		})
+	}
+}
+
+func TestInstallHelm_layout(t *testing.T) {
+	fs := osfs.New(test.MakeTempDir(t))
+	files, err := InstallHelmChart(context.TODO(), fs, &InstallOptions{
+		Chart: HelmChart{
+			URL:     "https://charts.bitnami.com/bitnami",
+			Name:    "bitnami/redis",
+			Version: "12.10.0",
+		},
+		Profile:    "test-profile",
+		OutputDir:  "deploy",
+		Layout:     operations.KindLayout,
+		HTTPClient: newTestIndexClient(t),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	want := []string{
+		"deploy/helmrelease/subscription-helm-release-redis.yaml",
+		"deploy/helmrepository/" + testRepositoryName + ".yaml",
+	}
+	if diff := cmp.Diff(want, names); diff != "" {
+		t.Fatalf("failed to lay out the resources:\n%s", diff)
+	}
+	b := readTestFile(t, fs, "deploy/kustomization.yaml")
+	wantKustomization := "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n  - helmrelease/subscription-helm-release-redis.yaml\n  - helmrepository/" + testRepositoryName + ".yaml\n"
+	if diff := cmp.Diff(wantKustomization, string(b)); diff != "" {
+		t.Fatalf("failed to update the kustomization:\n%s", diff)
+	}
+	if _, err := fs.Stat(profilePath("test-profile")); err != nil {
+		t.Fatalf("the profile was not written to the profile directory: %s", err)
+	}
+}
+
+func TestInstallHelm_oci(t *testing.T) {
+	fs := osfs.New(test.MakeTempDir(t))
+	files, err := InstallHelmChart(context.TODO(), fs, &InstallOptions{
+		Chart: HelmChart{
+			URL:     testOCIRepoURL,
+			Name:    "podinfo",
+			Version: "~6.0",
 		},
+		Profile:    "test-profile",
+		HTTPClient: newTestIndexClient(t),
+	})
+	if err != nil {
+		t.Fatal(err)
 	}
 
+	repoName := "subscription-helm-repository-registry-example-com-charts"
+	want := map[string]runtime.Object{
+		"profiles/test-profile/helmrepository_" + repoName + ".yaml": &profiles.OCIHelmRepository{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       sourcev1beta1.HelmRepositoryKind,
+				APIVersion: "source.toolkit.fluxcd.io/v1beta2",
+			},
+			ObjectMeta: metav1.ObjectMeta{Name: repoName},
+			Spec:       profiles.OCIHelmRepositorySpec{URL: testOCIRepoURL, Type: "oci", Interval: testInterval},
+		},
+		"profiles/test-profile/helmrelease_subscription-helm-release-podinfo.yaml": &helmv2beta1.HelmRelease{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       helmv2beta1.HelmReleaseKind,
+				APIVersion: helmv2beta1.GroupVersion.String(),
+			},
+			ObjectMeta: metav1.ObjectMeta{Name: "subscription-helm-release-podinfo"},
+			Spec: helmv2beta1.HelmReleaseSpec{
+				Interval: testInterval,
+				Chart: helmv2beta1.HelmChartTemplate{
+					Spec: helmv2beta1.HelmChartTemplateSpec{
+						Chart:   "podinfo",
+						Version: "6.0.3+build.1",
+						SourceRef: helmv2beta1.CrossNamespaceObjectReference{
+							APIVersion: "source.toolkit.fluxcd.io/v1beta2",
+							Kind:       sourcev1beta1.HelmRepositoryKind,
+							Name:       repoName,
+						},
+					},
+				},
+			},
+		},
+	}
 	if diff := cmp.Diff(want, files); diff != "" {
 		t.Fatalf("failed to generate installation resources:\n%s", diff)
 	}
 }
+
+func TestInstallHelm_oci_errors(t *testing.T) {
+	errorTests := []struct {
+		chart   string
+		version string
+		wantErr string
+	}{
+		{
+			"podinfo", "5.0.0",
+			`version "5.0.0" of chart "podinfo" not found, available versions: 6.1.0, 6.0.3+build.1, 6.0.0`,
+		},
+		{
+			"unknown", "1.0.0",
+			`failed to list the chart tags in the registry: chart "unknown" not found in the registry`,
+		},
+	}
+
+	client := newTestIndexClient(t)
+	for _, tt := range errorTests {
+		t.Run(tt.chart+"@"+tt.version, func(t *testing.T) {
+			fs := osfs.New(test.MakeTempDir(t))
+			_, err := InstallHelmChart(context.TODO(), fs, &InstallOptions{
+				Chart: HelmChart{
+					URL:     testOCIRepoURL,
+					Name:    tt.chart,
+					Version: tt.version,
+				},
+				Profile:    "test-profile",
+				HTTPClient: client,
+			})
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// newTestIndexClient returns a client that sends all requests to a server
+// that serves testdata/index.yaml at /bitnami/index.yaml, regardless of the
+// host in the request.
+//
+// The server is also a stand-in for an OCI registry that requires an
+// anonymous token, with a paged list of tags for the podinfo chart in
+// registry.example.com/charts.
+func newTestIndexClient(t *testing.T) *http.Client {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/bitnami/index.yaml", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "testdata/index.yaml")
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("scope") != "repository:charts/podinfo:pull" {
+			http.Error(w, "invalid scope", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"token":"test-token"}`))
+	})
+	mux.HandleFunc("/v2/charts/podinfo/tags/list", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="https://registry.example.com/token",service="registry.example.com",scope="repository:charts/podinfo:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("last") == "" {
+			w.Header().Set("Link", `</v2/charts/podinfo/tags/list?last=6.0.3_build.1&n=2>; rel="next"`)
+			w.Write([]byte(`{"name":"charts/podinfo","tags":["6.0.0","6.0.3_build.1"]}`))
+			return
+		}
+		w.Write([]byte(`{"name":"charts/podinfo","tags":["6.1.0","latest"]}`))
+	})
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return &http.Client{Transport: redirectTransport{target: ts.URL}}
+}
+
+type redirectTransport struct {
+	target string
+}
+
+func (r redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	u, err := url.Parse(r.target)
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.URL.Scheme = u.Scheme
+	req.URL.Host = u.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func readTestProfile(t *testing.T, fs billy.Filesystem, name string) *profiles.Profile {
+	t.Helper()
+	p, err := profiles.ParseBytes(readTestFile(t, fs, profilePath(name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func readTestFile(t *testing.T, fs billy.Filesystem, name string) []byte {
+	t.Helper()
+	f, err := fs.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
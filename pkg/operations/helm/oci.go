@@ -0,0 +1,174 @@
+package helm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	challengeParamRE = regexp.MustCompile(`(\w+)="([^"]*)"`)
+	nextLinkRE       = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+)
+
+type ociTagList struct {
+	Tags []string `json:"tags"`
+}
+
+type ociToken struct {
+	Token       string `json:"token"`
+	AccessToken string `json:"access_token"`
+}
+
+// fetchOCITags lists the tags for a chart in an OCI registry using the OCI
+// distribution API.
+//
+// Registries that require a token for anonymous access are supported, by
+// fetching a token from the realm in the registry's challenge.
+//
+// Helm stores "+" in chart versions as "_" because "+" is not allowed in OCI
+// tags, so these are converted back.
+func fetchOCITags(ctx context.Context, c *http.Client, repoURL, chart string) ([]string, error) {
+	parsed, err := url.Parse(repoURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse repository URL %q: %w", repoURL, err)
+	}
+	if parsed.Host == "" {
+		return nil, fmt.Errorf("repository URL %q has no host", repoURL)
+	}
+	name := strings.TrimPrefix(path.Join(parsed.Path, chart), "/")
+	next := (&url.URL{Scheme: "https", Host: parsed.Host, Path: "/v2/" + name + "/tags/list"}).String()
+
+	token := ""
+	tags := []string{}
+	for next != "" {
+		resp, err := getOCI(ctx, c, next, token)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusUnauthorized && token == "" {
+			challenge := resp.Header.Get("WWW-Authenticate")
+			resp.Body.Close()
+			token, err = fetchOCIToken(ctx, c, challenge, name)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		page := ociTagList{}
+		err = decodeOCIResponse(resp, next, chart, &page)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range page.Tags {
+			tags = append(tags, strings.ReplaceAll(t, "_", "+"))
+		}
+		next, err = nextPage(next, resp.Header.Get("Link"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tags, nil
+}
+
+func getOCI(ctx context.Context, c *http.Client, u, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request for %s: %w", u, err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", u, err)
+	}
+	return resp, nil
+}
+
+func decodeOCIResponse(resp *http.Response, u, chart string, v interface{}) error {
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return fmt.Errorf("chart %q not found in the registry", chart)
+	default:
+		return fmt.Errorf("failed to fetch %s: status code %d", u, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", u, err)
+	}
+	return nil
+}
+
+// fetchOCIToken requests an anonymous pull token from the realm in a Bearer
+// challenge e.g.
+//
+//	Bearer realm="https://ghcr.io/token",service="ghcr.io",scope="repository:org/chart:pull"
+func fetchOCIToken(ctx context.Context, c *http.Client, challenge, name string) (string, error) {
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		return "", errors.New("registry requires authentication and does not support anonymous tokens")
+	}
+	params := map[string]string{}
+	for _, m := range challengeParamRE.FindAllStringSubmatch(challenge, -1) {
+		params[strings.ToLower(m[1])] = m[2]
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Host == "" {
+		return "", fmt.Errorf("invalid realm in registry challenge %q", challenge)
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + name + ":pull"
+	}
+	q := realm.Query()
+	if params["service"] != "" {
+		q.Set("service", params["service"])
+	}
+	q.Set("scope", scope)
+	realm.RawQuery = q.Encode()
+
+	resp, err := getOCI(ctx, c, realm.String(), "")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch a registry token from %s: status code %d", realm, resp.StatusCode)
+	}
+	t := ociToken{}
+	if err := json.NewDecoder(resp.Body).Decode(&t); err != nil {
+		return "", fmt.Errorf("failed to parse the registry token: %w", err)
+	}
+	if t.Token != "" {
+		return t.Token, nil
+	}
+	if t.AccessToken != "" {
+		return t.AccessToken, nil
+	}
+	return "", errors.New("registry did not return a token")
+}
+
+// nextPage returns the URL of the next page of tags from the Link header, or
+// an empty string if this is the last page.
+func nextPage(current, link string) (string, error) {
+	m := nextLinkRE.FindStringSubmatch(link)
+	if m == nil {
+		return "", nil
+	}
+	base, err := url.Parse(current)
+	if err != nil {
+		return "", err
+	}
+	next, err := base.Parse(m[1])
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the next page link %q: %w", m[1], err)
+	}
+	return next.String(), nil
+}
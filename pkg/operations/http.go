@@ -0,0 +1,43 @@
+package operations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// getBody fetches the URL and returns the body of the response, responses
+// other than 200 OK are returned as ClientErrors.
+func getBody(ctx context.Context, c *http.Client, u string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request for %s: %w", u, err)
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, NewClientError(resp.StatusCode, fmt.Sprintf("failed to fetch %s", u))
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response from %s: %w", u, err)
+	}
+	return b, nil
+}
+
+// getJSON fetches the URL and decodes the JSON body into v.
+func getJSON(ctx context.Context, c *http.Client, u string, v interface{}) error {
+	b, err := getBody(ctx, c, u)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("failed to decode response from %s: %w", u, err)
+	}
+	return nil
+}
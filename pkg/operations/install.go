@@ -3,10 +3,12 @@ package operations
 import (
 	"context"
 	"fmt"
-	"net/url"
 	"os"
 	"strings"
 
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"k8s.io/apimachinery/pkg/runtime"
+
 	"github.com/bigkevmcd/askja/pkg/git"
 	"github.com/bigkevmcd/askja/pkg/profiles"
 	"sigs.k8s.io/yaml"
@@ -21,45 +23,93 @@ const (
 type InstallOptions struct {
 	*profiles.ProfileOptions
 	NewBranchName string
+	// OutputDir is the directory in the repository to write the manifests
+	// to, if this is empty, the directory that the profile was last installed
+	// to is used, or the root of the repository.
+	OutputDir string
+	// Layout is how the manifests are laid out in the OutputDir, if this is
+	// empty, the layout that the profile was last installed with is used, or
+	// FlatLayout.
+	Layout Layout
 }
 
 // InstallProfile will generate the HelmRelease for a profile.
 //
 // TODO: could this take a git.Repository?
 func InstallProfile(ctx context.Context, path string, options *InstallOptions) error {
-	client, err := DefaultClientFactory(options.ProfileOptions.ProfileURL)
+	p, result, err := makeProfileArtifacts(ctx, options.ProfileOptions)
 	if err != nil {
-		return fmt.Errorf("failed to create a client for %q: %w",
-			options.ProfileOptions.ProfileURL, err)
+		return err
 	}
 
-	repo, err := extractRepo(options.ProfileOptions.ProfileURL)
+	g, err := git.New(path)
 	if err != nil {
+		return fmt.Errorf("failed to open the git repository in %q: %w", path, err)
+	}
+	if err := g.CreateAndSwitchBranch(options.NewBranchName); err != nil {
 		return err
 	}
-	b, err := client.FileContents(ctx, repo, "profile.yaml", "main")
+	records, err := readInstallRecords(g.Filesystem())
 	if err != nil {
 		return err
 	}
-	p, err := profiles.ParseBytes(b)
+	installOwner := newOwner(options.ProfileURL, options.NamePrefix)
+	install := records.resolve(installOwner, options.OutputDir, options.Layout)
+	files, err := layoutFiles(g.Filesystem(), install, p.Name, result)
 	if err != nil {
 		return err
 	}
-	result := profiles.MakeArtifacts(p, options.ProfileOptions)
-
-	g, err := git.New(path)
-	g.CreateAndSwitchBranch(options.NewBranchName)
-	for _, v := range result {
-		b, err := yaml.Marshal(v)
+	resources := []string{}
+	for _, f := range files {
+		resources = append(resources, relativePath(install.OutputDir, f.name))
+	}
+	// Files from the previous install that are not generated now e.g. after
+	// changing the layout or output directory, are removed, unless they're
+	// shared with other installs.
+	stale := []string{}
+	removed := []string{}
+	if recorded, ok := records.find(installOwner); ok {
+		owned, err := staleFiles(g.Filesystem(), recorded.OutputDir, installOwner, files)
 		if err != nil {
-			return fmt.Errorf("failed to marshal: %w", err)
+			return err
 		}
-		output, err := filenameFrom("", v)
+		var shared []manifestFile
+		stale, shared, err = disownFiles(g.Filesystem(), owned, installOwner)
 		if err != nil {
 			return err
 		}
-		if err := g.WriteFile(output, b, defaultFileMode); err != nil {
-			return fmt.Errorf("failed to write to file %q in %q: %w", output, path, err)
+		files = append(files, shared...)
+		for _, name := range stale {
+			removed = append(removed, relativePath(recorded.OutputDir, name))
+		}
+		if !sameDir(recorded.OutputDir, install.OutputDir) && len(removed) > 0 {
+			name, b, err := UpdateKustomization(g.Filesystem(), recorded.OutputDir, nil, removed)
+			if err != nil {
+				return err
+			}
+			files = append(files, manifestFile{name: name, data: b})
+			removed = nil
+		}
+	}
+	name, b, err := UpdateKustomization(g.Filesystem(), install.OutputDir, resources, removed)
+	if err != nil {
+		return err
+	}
+	files = append(files, manifestFile{name: name, data: b})
+	records.set(install)
+	b, err = yaml.Marshal(records)
+	if err != nil {
+		return fmt.Errorf("failed to marshal: %w", err)
+	}
+	files = append(files, manifestFile{name: installsFilename, data: b})
+	for _, name := range stale {
+		if err := g.RemoveFile(name); err != nil {
+			return err
+		}
+	}
+	for _, f := range files {
+		if err := g.WriteFile(f.name, f.data, defaultFileMode); err != nil {
+			return fmt.Errorf("failed to write to file %q in %q: %w", f.name, path, err)
 		}
 	}
 	_, err = g.Commit("Add Profile files", &git.CommitOptions{})
@@ -69,11 +119,215 @@ func InstallProfile(ctx context.Context, path string, options *InstallOptions) e
 	return nil
 }
 
-// TODO: This is extremely naive.
+// GenerateProfile fetches the profile and returns the resources that
+// InstallProfile would write for it, without opening or changing a git
+// repository.
+func GenerateProfile(ctx context.Context, opts *profiles.ProfileOptions) ([]runtime.Object, error) {
+	_, result, err := makeProfileArtifacts(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range result {
+		if err := setOwner(o, newOwner(opts.ProfileURL, opts.NamePrefix)); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// makeProfileArtifacts fetches, parses and resolves the profile, and makes the
+// resources for its artifacts.
+func makeProfileArtifacts(ctx context.Context, opts *profiles.ProfileOptions) (*profiles.Profile, []runtime.Object, error) {
+	fetched, err := fetchProfile(ctx, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	p, err := profiles.ParseBytes(fetched.body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := verifyArtifactPaths(ctx, fetched.client, fetched.repo, fetched.ref, p); err != nil {
+		return nil, nil, err
+	}
+	resolved, err := profiles.ResolveProfiles(ctx, p, opts, fetchNestedProfile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to resolve the nested profiles for profile %q: %w", p.Name, err)
+	}
+	result, err := resolved.MakeArtifacts(opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to make artifacts for profile %q: %w", p.Name, err)
+	}
+	return p, result, nil
+}
+
+// UninstallOptions are passed to the uninstall operation.
+type UninstallOptions struct {
+	// ProfileURL is the URL of the installed profile.
+	ProfileURL string
+	// NamePrefix is the name prefix that the profile was installed with, if
+	// this is empty, profiles.DefaultNamePrefix is used.
+	NamePrefix    string
+	NewBranchName string
+}
+
+// UninstallProfile removes the files that were generated for the profile from
+// the directory it was installed to, and removes them from the
+// kustomization.yaml, and commits the changes to a new branch.
+func UninstallProfile(path string, options *UninstallOptions) error {
+	g, err := git.New(path)
+	if err != nil {
+		return fmt.Errorf("failed to open the git repository in %q: %w", path, err)
+	}
+	if err := g.CreateAndSwitchBranch(options.NewBranchName); err != nil {
+		return err
+	}
+	records, err := readInstallRecords(g.Filesystem())
+	if err != nil {
+		return err
+	}
+	installOwner := newOwner(options.ProfileURL, options.NamePrefix)
+	install, ok := records.remove(installOwner)
+	if !ok {
+		return fmt.Errorf("profile %s is not installed with the name prefix %q", installOwner.url, installOwner.namePrefix)
+	}
+	owned, err := ownedFiles(g.Filesystem(), install.OutputDir, install.owner())
+	if err != nil {
+		return err
+	}
+	// Files that are shared with other installs are kept for them.
+	removed, shared, err := disownFiles(g.Filesystem(), owned, install.owner())
+	if err != nil {
+		return err
+	}
+	resources := []string{}
+	for _, name := range removed {
+		if err := g.RemoveFile(name); err != nil {
+			return err
+		}
+		resources = append(resources, relativePath(install.OutputDir, name))
+	}
+	for _, f := range shared {
+		if err := g.WriteFile(f.name, f.data, defaultFileMode); err != nil {
+			return fmt.Errorf("failed to write to file %q in %q: %w", f.name, path, err)
+		}
+	}
+	name, b, err := UpdateKustomization(g.Filesystem(), install.OutputDir, nil, resources)
+	if err != nil {
+		return err
+	}
+	if err := g.WriteFile(name, b, defaultFileMode); err != nil {
+		return fmt.Errorf("failed to write to file %q in %q: %w", name, path, err)
+	}
+	b, err = yaml.Marshal(records)
+	if err != nil {
+		return fmt.Errorf("failed to marshal: %w", err)
+	}
+	if err := g.WriteFile(installsFilename, b, defaultFileMode); err != nil {
+		return fmt.Errorf("failed to write to file %q in %q: %w", installsFilename, path, err)
+	}
+	_, err = g.Commit("Remove Profile files", &git.CommitOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to commit changes to local-repo: %w", err)
+	}
+	return nil
+}
+
+// FetchProfile fetches the profile.yaml from the profile repo at the ref in
+// the options, resolving a semver range to a tag.
+func FetchProfile(ctx context.Context, opts *profiles.ProfileOptions) ([]byte, error) {
+	fetched, err := fetchProfile(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return fetched.body, nil
+}
+
+// fetchNestedProfile is a profiles.ProfileFetcher that fetches and parses the
+// Profile referenced by a profile artifact, and verifies its artifact paths.
+func fetchNestedProfile(ctx context.Context, ref *profiles.ProfileRef) (*profiles.Profile, error) {
+	fetched, err := fetchProfile(ctx, ref.Options())
+	if err != nil {
+		return nil, err
+	}
+	p, err := profiles.ParseBytes(fetched.body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the profile from %q: %w", ref.URL, err)
+	}
+	if err := verifyArtifactPaths(ctx, fetched.client, fetched.repo, fetched.ref, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// fetchedProfile is a profile.yaml and where it was fetched from.
+type fetchedProfile struct {
+	client Client
+	repo   string
+	ref    string
+	body   []byte
+}
+
+func fetchProfile(ctx context.Context, opts *profiles.ProfileOptions) (*fetchedProfile, error) {
+	client, err := DefaultClientFactory(ctx, opts.ProfileURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a client for %q: %w", opts.ProfileURL, err)
+	}
+
+	repo, err := extractRepo(opts.ProfileURL)
+	if err != nil {
+		return nil, err
+	}
+	ref := opts.Ref()
+	if opts.SemVer != "" && opts.Commit == "" {
+		ref, err = resolveSemVerTag(ctx, client, repo, opts.SemVer)
+		if err != nil {
+			return nil, err
+		}
+	}
+	fetchCtx := ctx
+	if opts.Commit != "" || opts.Tag != "" || opts.SemVer != "" {
+		fetchCtx = withFixedRef(ctx)
+	}
+	b, err := client.FileContents(fetchCtx, repo, "profile.yaml", ref)
+	if err != nil {
+		return nil, err
+	}
+	return &fetchedProfile{client: client, repo: repo, ref: ref, body: b}, nil
+}
+
+// verifyArtifactPaths checks that the paths referenced by the artifacts in the
+// profile exist in the profile repo, this is only possible if the client
+// implements PathChecker.
+func verifyArtifactPaths(ctx context.Context, client Client, repo, ref string, p *profiles.Profile) error {
+	checker, ok := client.(PathChecker)
+	if !ok {
+		return nil
+	}
+	for _, a := range p.Spec.Artifacts {
+		path := a.Path
+		if a.Kustomize != nil {
+			path = a.Kustomize.Path
+		}
+		if path == "" {
+			continue
+		}
+		exists, err := checker.PathExists(ctx, repo, path, ref)
+		if err != nil {
+			return fmt.Errorf("failed to check the path for artifact %q: %w", a.Name, err)
+		}
+		if !exists {
+			return fmt.Errorf("artifact %q path %q does not exist in the profile repo at %s", a.Name, path, ref)
+		}
+	}
+	return nil
+}
+
+// extractRepo returns the path to the repository from the profileURL, this
+// supports the URL forms that git supports, including scp-like SSH URLs.
 func extractRepo(profileURL string) (string, error) {
-	parsed, err := url.Parse(profileURL)
+	ep, err := transport.NewEndpoint(profileURL)
 	if err != nil {
 		return "", fmt.Errorf("failed to parse profileURL %q: %w", profileURL, err)
 	}
-	return strings.TrimPrefix(strings.TrimSuffix(parsed.Path, ".git"), "/"), nil
+	return strings.TrimPrefix(strings.TrimSuffix(ep.Path, ".git"), "/"), nil
 }
@@ -2,12 +2,20 @@ package operations
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/yaml"
+
+	sourcev1beta1 "github.com/fluxcd/source-controller/api/v1beta1"
 
 	"github.com/bigkevmcd/askja/pkg/profiles"
 	"github.com/bigkevmcd/askja/test"
@@ -16,7 +24,7 @@ import (
 func TestInstallProfile(t *testing.T) {
 	client := newMockClient()
 	dir, _ := test.MakeTempGitRepo(t)
-	DefaultClientFactory = func(s string) (Client, error) {
+	DefaultClientFactory = func(ctx context.Context, s string) (Client, error) {
 		if s == "https://github.com/weaveworks/nginx-profile.git" {
 			return client, nil
 		}
@@ -33,6 +41,9 @@ spec:
   artifacts:
     - name: nginx-server
       path: nginx/chart
+    - name: nginx-policies
+      kustomize:
+        path: nginx/policies
 `))
 
 	if err := InstallProfile(context.TODO(), dir,
@@ -47,23 +58,553 @@ spec:
 	}
 	committed := readFilesFromHead(t, dir)
 	want := []string{
+		".askja.yaml",
 		"gitrepository_subscription-nginx-profile-main.yaml",
 		"helmrelease_subscription-helm-release-nginx-server.yaml",
+		"kustomization.yaml",
+		"kustomization_subscription-kustomization-nginx-policies.yaml",
+	}
+	if diff := cmp.Diff(want, filenamesFrom(committed)); diff != "" {
+		t.Fatalf("written files don't match:\n%s", diff)
+	}
+}
+
+func TestInstallProfile_tag(t *testing.T) {
+	client := newMockClient()
+	dir, _ := test.MakeTempGitRepo(t)
+	DefaultClientFactory = func(ctx context.Context, s string) (Client, error) {
+		return client, nil
+	}
+	client.add("weaveworks/nginx-profile", "profile.yaml", "v0.1.0", []byte(`
+apiVersion: profiles.fluxcd.io/v1alpha1
+kind: Profile
+metadata:
+  name: nginx
+spec:
+  description: Profile for deploying nginx
+  artifacts:
+    - name: nginx-server
+      path: nginx/chart
+`))
+
+	if err := InstallProfile(context.TODO(), dir,
+		&InstallOptions{
+			ProfileOptions: &profiles.ProfileOptions{
+				ProfileURL: "https://github.com/weaveworks/nginx-profile.git",
+				Branch:     "main",
+				Tag:        "v0.1.0",
+			},
+			NewBranchName: "test-branch",
+		}); err != nil {
+		t.Fatal(err)
+	}
+	committed := readFilesFromHead(t, dir)
+	want := []string{
+		".askja.yaml",
+		"gitrepository_subscription-nginx-profile-v0.1.0.yaml",
+		"helmrelease_subscription-helm-release-nginx-server.yaml",
+		"kustomization.yaml",
 	}
 	if diff := cmp.Diff(want, filenamesFrom(committed)); diff != "" {
 		t.Fatalf("written files don't match:\n%s", diff)
 	}
 }
 
+func TestInstallProfile_semver(t *testing.T) {
+	client := newMockClient()
+	dir, _ := test.MakeTempGitRepo(t)
+	DefaultClientFactory = func(ctx context.Context, s string) (Client, error) {
+		return client, nil
+	}
+	client.addTags("weaveworks/nginx-profile", "v1.1.0", "v1.2.0", "v1.3.1", "v2.0.0")
+	client.add("weaveworks/nginx-profile", "profile.yaml", "v1.3.1", []byte(`
+apiVersion: profiles.fluxcd.io/v1alpha1
+kind: Profile
+metadata:
+  name: nginx
+spec:
+  description: Profile for deploying nginx
+  artifacts:
+    - name: nginx-server
+      path: nginx/chart
+`))
+
+	if err := InstallProfile(context.TODO(), dir,
+		&InstallOptions{
+			ProfileOptions: &profiles.ProfileOptions{
+				ProfileURL: "https://github.com/weaveworks/nginx-profile.git",
+				Branch:     "main",
+				SemVer:     ">=1.2.0 <2.0.0",
+			},
+			NewBranchName: "test-branch",
+		}); err != nil {
+		t.Fatal(err)
+	}
+	committed := readFilesFromHead(t, dir)
+	want := []string{
+		".askja.yaml",
+		"gitrepository_subscription-nginx-profile-semver-1.2.0-2.0.0-4f9d93e4.yaml",
+		"helmrelease_subscription-helm-release-nginx-server.yaml",
+		"kustomization.yaml",
+	}
+	if diff := cmp.Diff(want, filenamesFrom(committed)); diff != "" {
+		t.Fatalf("written files don't match:\n%s", diff)
+	}
+
+	var repo sourcev1beta1.GitRepository
+	if err := yaml.Unmarshal(committed[want[1]], &repo); err != nil {
+		t.Fatal(err)
+	}
+	if repo.Spec.Reference.SemVer != ">=1.2.0 <2.0.0" {
+		t.Fatalf("got semver %q, want the requested range", repo.Spec.Reference.SemVer)
+	}
+	wantFetched := []string{key("weaveworks/nginx-profile", "profile.yaml", "v1.3.1")}
+	if diff := cmp.Diff(wantFetched, client.fetched); diff != "" {
+		t.Fatalf("profile not fetched at the resolved tag:\n%s", diff)
+	}
+}
+
+func TestInstallProfile_git_clone(t *testing.T) {
+	remote, _ := test.MakeBareRepository(t, map[string]string{
+		"profile.yaml":           testProfile,
+		"nginx/chart/Chart.yaml": "name: nginx\n",
+	})
+	dir, _ := test.MakeTempGitRepo(t)
+	DefaultClientFactory = NewGitCloneClientFactory(GitCloneOptions{})
+
+	if err := InstallProfile(context.TODO(), dir,
+		&InstallOptions{
+			ProfileOptions: &profiles.ProfileOptions{
+				ProfileURL: "file://" + remote,
+				Branch:     "main",
+			},
+			NewBranchName: "test-branch",
+		}); err != nil {
+		t.Fatal(err)
+	}
+	committed := readFilesFromHead(t, dir)
+	want := []string{
+		".askja.yaml",
+		"gitrepository_subscription-" + filepath.Base(remote) + "-main.yaml",
+		"helmrelease_subscription-helm-release-nginx-server.yaml",
+		"kustomization.yaml",
+	}
+	if diff := cmp.Diff(want, filenamesFrom(committed)); diff != "" {
+		t.Fatalf("written files don't match:\n%s", diff)
+	}
+}
+
+func TestInstallProfile_nested_profile(t *testing.T) {
+	client := newMockClient()
+	dir, _ := test.MakeTempGitRepo(t)
+	DefaultClientFactory = func(ctx context.Context, s string) (Client, error) {
+		return client, nil
+	}
+	client.add("weaveworks/nginx-profile", "profile.yaml", "main", []byte(`
+apiVersion: profiles.fluxcd.io/v1alpha2
+kind: Profile
+metadata:
+  name: nginx
+spec:
+  artifacts:
+    - name: nginx-server
+      helm:
+        path: nginx/chart
+    - name: logging
+      profile:
+        url: https://github.com/weaveworks/logging-profile.git
+        tag: v0.1.0
+`))
+	client.add("weaveworks/logging-profile", "profile.yaml", "v0.1.0", []byte(`
+apiVersion: profiles.fluxcd.io/v1alpha2
+kind: Profile
+metadata:
+  name: logging
+spec:
+  artifacts:
+    - name: fluentd
+      helm:
+        path: fluentd/chart
+`))
+
+	if err := InstallProfile(context.TODO(), dir,
+		&InstallOptions{
+			ProfileOptions: &profiles.ProfileOptions{
+				ProfileURL: "https://github.com/weaveworks/nginx-profile.git",
+				Branch:     "main",
+			},
+			NewBranchName: "test-branch",
+		}); err != nil {
+		t.Fatal(err)
+	}
+	committed := readFilesFromHead(t, dir)
+	want := []string{
+		".askja.yaml",
+		"gitrepository_subscription-logging-profile-v0.1.0.yaml",
+		"gitrepository_subscription-nginx-profile-main.yaml",
+		"helmrelease_subscription-helm-release-logging-fluentd.yaml",
+		"helmrelease_subscription-helm-release-nginx-server.yaml",
+		"kustomization.yaml",
+	}
+	if diff := cmp.Diff(want, filenamesFrom(committed)); diff != "" {
+		t.Fatalf("written files don't match:\n%s", diff)
+	}
+}
+
+func TestInstallProfile_name_collision(t *testing.T) {
+	client := newMockClient()
+	dir, _ := test.MakeTempGitRepo(t)
+	DefaultClientFactory = func(ctx context.Context, s string) (Client, error) {
+		return client, nil
+	}
+	profile := func(version string) []byte {
+		return []byte(`
+apiVersion: profiles.fluxcd.io/v1alpha1
+kind: Profile
+metadata:
+  name: nginx
+spec:
+  artifacts:
+    - name: nginx-server
+      helm:
+        chart: nginx
+        repository: https://charts.bitnami.com/bitnami
+        version: ` + version + `
+`)
+	}
+	client.add("weaveworks/nginx-profile", "profile.yaml", "main", profile("8.9.1"))
+	client.add("example/nginx-profile", "profile.yaml", "main", profile("9.0.0"))
+	installs := 0
+	install := func(url, prefix string) error {
+		installs++
+		return InstallProfile(context.TODO(), dir,
+			&InstallOptions{
+				ProfileOptions: &profiles.ProfileOptions{
+					ProfileURL: url,
+					Branch:     "main",
+					NamePrefix: prefix,
+				},
+				NewBranchName: fmt.Sprintf("test-branch-%d", installs),
+			})
+	}
+	if err := install("https://github.com/weaveworks/nginx-profile.git", ""); err != nil {
+		t.Fatal(err)
+	}
+	// Reinstalling the same profile replaces its resources.
+	if err := install("https://github.com/weaveworks/nginx-profile.git", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	err := install("https://github.com/example/nginx-profile.git", "")
+	want := `"helmrelease_subscription-helm-release-nginx-server.yaml" was generated for the profile https://github.com/weaveworks/nginx-profile.git, use a different name prefix to install the profile https://github.com/example/nginx-profile.git alongside it`
+	if err == nil || err.Error() != want {
+		t.Fatalf("got error %v, want %q", err, want)
+	}
+
+	if err := install("https://github.com/example/nginx-profile.git", "example"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUninstallProfile(t *testing.T) {
+	client := newMockClient()
+	dir, _ := test.MakeTempGitRepo(t)
+	DefaultClientFactory = func(ctx context.Context, s string) (Client, error) {
+		return client, nil
+	}
+	profile := func(name string) []byte {
+		return []byte(`
+apiVersion: profiles.fluxcd.io/v1alpha1
+kind: Profile
+metadata:
+  name: ` + name + `
+spec:
+  artifacts:
+    - name: ` + name + `-server
+      path: ` + name + `/chart
+`)
+	}
+	client.add("weaveworks/nginx-profile", "profile.yaml", "main", profile("nginx"))
+	client.add("weaveworks/redis-profile", "profile.yaml", "main", profile("redis"))
+	for i, install := range []struct{ url, prefix string }{
+		{"https://github.com/weaveworks/nginx-profile.git", ""},
+		{"https://github.com/weaveworks/redis-profile.git", ""},
+		{"https://github.com/weaveworks/nginx-profile.git", "staging"},
+	} {
+		if err := InstallProfile(context.TODO(), dir, &InstallOptions{
+			ProfileOptions: &profiles.ProfileOptions{ProfileURL: install.url, Branch: "main", NamePrefix: install.prefix},
+			NewBranchName:  fmt.Sprintf("test-branch-%d", i),
+			OutputDir:      "deploy",
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := UninstallProfile(dir, &UninstallOptions{
+		ProfileURL:    "https://github.com/weaveworks/nginx-profile.git",
+		NewBranchName: "uninstall-nginx",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "deploy", "*.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range files {
+		files[i] = filepath.Base(files[i])
+	}
+	want := []string{
+		"gitrepository_staging-nginx-profile-main.yaml",
+		"gitrepository_subscription-redis-profile-main.yaml",
+		"helmrelease_staging-helm-release-nginx-server.yaml",
+		"helmrelease_subscription-helm-release-redis-server.yaml",
+		"kustomization.yaml",
+	}
+	if diff := cmp.Diff(want, files); diff != "" {
+		t.Fatalf("files after uninstalling don't match:\n%s", diff)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "deploy", "kustomization.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantKustomization := "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n  - gitrepository_subscription-redis-profile-main.yaml\n  - helmrelease_subscription-helm-release-redis-server.yaml\n  - gitrepository_staging-nginx-profile-main.yaml\n  - helmrelease_staging-helm-release-nginx-server.yaml\n"
+	if diff := cmp.Diff(wantKustomization, string(b)); diff != "" {
+		t.Fatalf("kustomization after uninstalling doesn't match:\n%s", diff)
+	}
+
+	err = UninstallProfile(dir, &UninstallOptions{
+		ProfileURL:    "https://github.com/weaveworks/nginx-profile.git",
+		NewBranchName: "uninstall-nginx-again",
+	})
+	wantErr := `profile https://github.com/weaveworks/nginx-profile.git is not installed with the name prefix "subscription"`
+	if err == nil || err.Error() != wantErr {
+		t.Fatalf("got error %v, want %q", err, wantErr)
+	}
+}
+
+func TestUninstallProfile_shared_source(t *testing.T) {
+	client := newMockClient()
+	dir, _ := test.MakeTempGitRepo(t)
+	DefaultClientFactory = func(ctx context.Context, s string) (Client, error) {
+		return client, nil
+	}
+	profile := func(name, version string) []byte {
+		return []byte(`
+apiVersion: profiles.fluxcd.io/v1alpha1
+kind: Profile
+metadata:
+  name: ` + name + `
+spec:
+  artifacts:
+    - name: ` + name + `-server
+      helm:
+        chart: ` + name + `
+        repository: https://charts.bitnami.com/bitnami
+        version: ` + version + `
+`)
+	}
+	client.add("weaveworks/nginx-profile", "profile.yaml", "main", profile("nginx", "8.9.1"))
+	client.add("weaveworks/redis-profile", "profile.yaml", "main", profile("redis", "12.10.0"))
+	for i, url := range []string{"https://github.com/weaveworks/nginx-profile.git", "https://github.com/weaveworks/redis-profile.git"} {
+		if err := InstallProfile(context.TODO(), dir, &InstallOptions{
+			ProfileOptions: &profiles.ProfileOptions{ProfileURL: url, Branch: "main"},
+			NewBranchName:  fmt.Sprintf("test-branch-%d", i),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repository := "helmrepository_subscription-helm-repository-charts-bitnami-com-bitnami.yaml"
+	annotations := func() map[string]string {
+		var current struct {
+			Metadata struct {
+				Annotations map[string]string `json:"annotations"`
+			} `json:"metadata"`
+		}
+		if err := yaml.Unmarshal([]byte(readRepoFile(t, dir, repository)), &current); err != nil {
+			t.Fatal(err)
+		}
+		return current.Metadata.Annotations
+	}
+	want := map[string]string{
+		profileAnnotation:    "https://github.com/weaveworks/nginx-profile.git",
+		namePrefixAnnotation: "subscription",
+		sharedWithAnnotation: "subscription=https://github.com/weaveworks/redis-profile.git",
+	}
+	if diff := cmp.Diff(want, annotations()); diff != "" {
+		t.Fatalf("shared source annotations don't match:\n%s", diff)
+	}
+
+	if err := UninstallProfile(dir, &UninstallOptions{
+		ProfileURL:    "https://github.com/weaveworks/nginx-profile.git",
+		NewBranchName: "uninstall-nginx",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	want = map[string]string{
+		profileAnnotation:    "https://github.com/weaveworks/redis-profile.git",
+		namePrefixAnnotation: "subscription",
+	}
+	if diff := cmp.Diff(want, annotations()); diff != "" {
+		t.Fatalf("shared source annotations after uninstalling don't match:\n%s", diff)
+	}
+	wantFiles := []string{
+		".askja.yaml",
+		"helmrelease_subscription-helm-release-redis-server.yaml",
+		repository,
+		"kustomization.yaml",
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range files {
+		files[i] = filepath.Base(files[i])
+	}
+	if diff := cmp.Diff(wantFiles, files); diff != "" {
+		t.Fatalf("files after uninstalling don't match:\n%s", diff)
+	}
+	wantKustomization := "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n  - " + repository + "\n  - helmrelease_subscription-helm-release-redis-server.yaml\n"
+	if diff := cmp.Diff(wantKustomization, readRepoFile(t, dir, "kustomization.yaml")); diff != "" {
+		t.Fatalf("kustomization after uninstalling doesn't match:\n%s", diff)
+	}
+
+	if err := UninstallProfile(dir, &UninstallOptions{
+		ProfileURL:    "https://github.com/weaveworks/redis-profile.git",
+		NewBranchName: "uninstall-redis",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, repository)); !os.IsNotExist(err) {
+		t.Fatalf("got error %v, want the shared source to be removed with its last owner", err)
+	}
+}
+
+func TestGenerateProfile(t *testing.T) {
+	client := newMockClient()
+	DefaultClientFactory = func(ctx context.Context, s string) (Client, error) {
+		return client, nil
+	}
+	client.add("weaveworks/nginx-profile", "profile.yaml", "main", []byte(testProfile))
+
+	objs, err := GenerateProfile(context.TODO(), &profiles.ProfileOptions{
+		ProfileURL: "https://github.com/weaveworks/nginx-profile.git",
+		Branch:     "main",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []string{}
+	for _, o := range objs {
+		kind, name, err := kindAndName(o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		oa, err := meta.Accessor(o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, kind+"/"+name+" "+oa.GetAnnotations()[profileAnnotation])
+	}
+	want := []string{
+		"gitrepository/subscription-nginx-profile-main https://github.com/weaveworks/nginx-profile.git",
+		"helmrelease/subscription-helm-release-nginx-server https://github.com/weaveworks/nginx-profile.git",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("generated resources don't match:\n%s", diff)
+	}
+}
+
+func TestInstallProfile_missing_artifact_path(t *testing.T) {
+	remote, _ := test.MakeBareRepository(t, map[string]string{
+		"profile.yaml": testProfile,
+	})
+	dir, _ := test.MakeTempGitRepo(t)
+	DefaultClientFactory = NewGitCloneClientFactory(GitCloneOptions{})
+
+	err := InstallProfile(context.TODO(), dir,
+		&InstallOptions{
+			ProfileOptions: &profiles.ProfileOptions{
+				ProfileURL: "file://" + remote,
+				Branch:     "main",
+			},
+			NewBranchName: "test-branch",
+		})
+
+	want := `artifact "nginx-server" path "nginx/chart" does not exist in the profile repo at main`
+	if err == nil || err.Error() != want {
+		t.Fatalf("got error %v, want %q", err, want)
+	}
+}
+
+func TestExtractRepo(t *testing.T) {
+	repoTests := []struct {
+		profileURL string
+		want       string
+	}{
+		{"https://github.com/weaveworks/nginx-profile.git", "weaveworks/nginx-profile"},
+		{"https://github.com/weaveworks/nginx-profile", "weaveworks/nginx-profile"},
+		{"ssh://git@gitlab.example.com/platform/profiles/nginx.git", "platform/profiles/nginx"},
+		{"git@github.com:weaveworks/nginx-profile.git", "weaveworks/nginx-profile"},
+	}
+
+	for _, tt := range repoTests {
+		t.Run(tt.profileURL, func(t *testing.T) {
+			repo, err := extractRepo(tt.profileURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if repo != tt.want {
+				t.Fatalf("extractRepo() got %q, want %q", repo, tt.want)
+			}
+		})
+	}
+}
+
 func newMockClient() *mockClient {
-	return &mockClient{contents: make(map[string][]byte)}
+	return &mockClient{
+		contents: make(map[string][]byte),
+		tags:     make(map[string][]string),
+		refs:     make(map[string]string),
+	}
 }
 
 type mockClient struct {
 	contents map[string][]byte
+	tags     map[string][]string
+	refs     map[string]string
+	fetches  int
+	fetched  []string
+	resolves int
+}
+
+func (m *mockClient) ResolveRef(ctx context.Context, repo, ref string) (string, error) {
+	m.resolves++
+	sha, ok := m.refs[key(repo, ref)]
+	if !ok {
+		return "", NewClientError(http.StatusNotFound, "ref not found")
+	}
+	return sha, nil
+}
+
+func (m *mockClient) addRef(repo, ref, sha string) {
+	m.refs[key(repo, ref)] = sha
+}
+
+func (m *mockClient) ListTags(ctx context.Context, repo string) ([]string, error) {
+	return m.tags[repo], nil
+}
+
+func (m *mockClient) addTags(repo string, tags ...string) {
+	m.tags[repo] = append(m.tags[repo], tags...)
 }
 
 func (m *mockClient) FileContents(ctx context.Context, repo, path, ref string) ([]byte, error) {
+	m.fetches++
+	m.fetched = append(m.fetched, key(repo, path, ref))
 	b, ok := m.contents[key(repo, path, ref)]
 	if !ok {
 		return nil, NewClientError(http.StatusNotFound, "file not found")
@@ -101,5 +642,6 @@ func filenamesFrom(m map[string][]byte) []string {
 	for k := range m {
 		f = append(f, k)
 	}
+	sort.Strings(f)
 	return f
 }
@@ -0,0 +1,124 @@
+package operations
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/go-git/go-billy/v5"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	kustomizationFilename   = "kustomization.yaml"
+	kustomizationAPIVersion = "kustomize.config.k8s.io/v1beta1"
+	kustomizationKind       = "Kustomization"
+)
+
+// UpdateKustomization adds and removes resources in the kustomization.yaml in
+// the directory, creating it if necessary, and returns the updated file.
+//
+// The resources are paths relative to the directory, resources that are
+// already listed are not added again, and the existing entries, and any
+// comments, are preserved.
+func UpdateKustomization(fs billy.Filesystem, dir string, add, remove []string) (string, []byte, error) {
+	filename := path.Join(dir, kustomizationFilename)
+	existing, err := readFile(fs, filename)
+	if err != nil && !os.IsNotExist(err) {
+		return "", nil, err
+	}
+	b, err := updateKustomization(existing, add, remove)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to update %q: %w", filename, err)
+	}
+	return filename, b, nil
+}
+
+func updateKustomization(existing []byte, add, remove []string) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(existing, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{
+			Kind: yaml.DocumentNode,
+			Content: []*yaml.Node{{
+				Kind: yaml.MappingNode,
+				Content: []*yaml.Node{
+					scalarNode("apiVersion"), scalarNode(kustomizationAPIVersion),
+					scalarNode("kind"), scalarNode(kustomizationKind),
+				},
+			}},
+		}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("expected a mapping, got %s", nodeKind(root))
+	}
+	resources := mappingValue(root, "resources")
+	if resources == nil {
+		resources = &yaml.Node{Kind: yaml.SequenceNode}
+		root.Content = append(root.Content, scalarNode("resources"), resources)
+	}
+	if resources.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("expected resources to be a sequence, got %s", nodeKind(resources))
+	}
+
+	removed := map[string]bool{}
+	for _, r := range remove {
+		removed[r] = true
+	}
+	listed := map[string]bool{}
+	kept := []*yaml.Node{}
+	for _, n := range resources.Content {
+		if removed[n.Value] {
+			continue
+		}
+		listed[n.Value] = true
+		kept = append(kept, n)
+	}
+	for _, r := range add {
+		if !listed[r] {
+			listed[r] = true
+			kept = append(kept, scalarNode(r))
+		}
+	}
+	resources.Content = kept
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func scalarNode(v string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v}
+}
+
+// mappingValue returns the value for the key in the mapping node, or nil if
+// the key is not in the mapping.
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func nodeKind(n *yaml.Node) string {
+	switch n.Kind {
+	case yaml.SequenceNode:
+		return "a sequence"
+	case yaml.MappingNode:
+		return "a mapping"
+	}
+	return "a scalar"
+}
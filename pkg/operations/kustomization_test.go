@@ -0,0 +1,68 @@
+package operations
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestUpdateKustomization(t *testing.T) {
+	updateTests := []struct {
+		name     string
+		existing string
+		add      []string
+		remove   []string
+		want     string
+	}{
+		{
+			name: "new kustomization",
+			add:  []string{"gitrepository_nginx.yaml", "helmrelease_nginx.yaml"},
+			want: "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n  - gitrepository_nginx.yaml\n  - helmrelease_nginx.yaml\n",
+		},
+		{
+			name:     "existing resources are not added again",
+			existing: "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n  - helmrelease_nginx.yaml\n",
+			add:      []string{"gitrepository_nginx.yaml", "helmrelease_nginx.yaml"},
+			want:     "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n  - helmrelease_nginx.yaml\n  - gitrepository_nginx.yaml\n",
+		},
+		{
+			name:     "comments and other fields are preserved",
+			existing: "# Cluster apps\napiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nnamespace: apps\nresources:\n  # Managed by hand\n  - ingress.yaml\n",
+			add:      []string{"helmrelease_nginx.yaml"},
+			want:     "# Cluster apps\napiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nnamespace: apps\nresources:\n  # Managed by hand\n  - ingress.yaml\n  - helmrelease_nginx.yaml\n",
+		},
+		{
+			name:     "no resources",
+			existing: "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\n",
+			add:      []string{"helmrelease_nginx.yaml"},
+			want:     "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n  - helmrelease_nginx.yaml\n",
+		},
+		{
+			name:     "removing resources",
+			existing: "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n  - ingress.yaml\n  - helmrelease_nginx.yaml\n",
+			remove:   []string{"helmrelease_nginx.yaml", "missing.yaml"},
+			want:     "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n  - ingress.yaml\n",
+		},
+	}
+
+	for _, tt := range updateTests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := updateKustomization([]byte(tt.existing), tt.add, tt.remove)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, string(b)); diff != "" {
+				t.Fatalf("failed to update the kustomization:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUpdateKustomization_errors(t *testing.T) {
+	_, err := updateKustomization([]byte("resources: helmrelease_nginx.yaml\n"), []string{"gitrepository_nginx.yaml"}, nil)
+
+	want := "expected resources to be a sequence, got a scalar"
+	if err == nil || err.Error() != want {
+		t.Fatalf("got error %v, want %q", err, want)
+	}
+}
@@ -0,0 +1,354 @@
+package operations
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+// Layout is how the generated manifests are laid out in the output directory.
+type Layout string
+
+const (
+	// FlatLayout writes a file per resource in the output directory.
+	FlatLayout Layout = "flat"
+	// ProfileLayout writes a file per resource in a profiles/<name> directory
+	// for each profile.
+	ProfileLayout Layout = "per-profile"
+	// KindLayout writes a file per resource in a directory for each kind of
+	// resource e.g. helmrelease/<name>.yaml.
+	KindLayout Layout = "per-kind"
+	// SingleFileLayout writes all the resources for a profile to a single
+	// multi-document <name>.yaml file.
+	SingleFileLayout Layout = "single"
+)
+
+// installsFilename is the file in the root of the repository that records
+// where each profile was installed, so that upgrades write to the same place.
+const installsFilename = ".askja.yaml"
+
+// Layouts are the supported layouts.
+var Layouts = []Layout{FlatLayout, ProfileLayout, KindLayout, SingleFileLayout}
+
+// ParseLayout returns the Layout with the name, an empty name is not an
+// error, it means the recorded layout, or FlatLayout, is used.
+func ParseLayout(s string) (Layout, error) {
+	if s == "" {
+		return "", nil
+	}
+	for _, l := range Layouts {
+		if string(l) == s {
+			return l, nil
+		}
+	}
+	names := []string{}
+	for _, l := range Layouts {
+		names = append(names, string(l))
+	}
+	return "", fmt.Errorf("unknown layout %q, must be one of %s", s, strings.Join(names, ", "))
+}
+
+// installRecord records where a profile was installed.
+type installRecord struct {
+	URL        string `json:"url"`
+	NamePrefix string `json:"namePrefix,omitempty"`
+	OutputDir  string `json:"outputDir,omitempty"`
+	Layout     Layout `json:"layout"`
+}
+
+// owner returns the owner of the resources generated for the install.
+func (r installRecord) owner() owner {
+	return newOwner(r.URL, r.NamePrefix)
+}
+
+type installRecords struct {
+	Profiles []installRecord `json:"profiles"`
+}
+
+func readInstallRecords(fs billy.Filesystem) (*installRecords, error) {
+	b, err := readFile(fs, installsFilename)
+	if os.IsNotExist(err) {
+		return &installRecords{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	records := &installRecords{}
+	if err := yaml.Unmarshal(b, records); err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %w", installsFilename, err)
+	}
+	return records, nil
+}
+
+// resolve returns where the profile should be installed, the output dir and
+// layout in the options take precedence over those recorded for the install
+// of the profile with the same name prefix.
+func (r *installRecords) resolve(o owner, outputDir string, layout Layout) installRecord {
+	resolved := installRecord{URL: o.url, NamePrefix: o.namePrefix, OutputDir: outputDir, Layout: layout}
+	if p, ok := r.find(o); ok {
+		if resolved.OutputDir == "" {
+			resolved.OutputDir = p.OutputDir
+		}
+		if resolved.Layout == "" {
+			resolved.Layout = p.Layout
+		}
+	}
+	if resolved.Layout == "" {
+		resolved.Layout = FlatLayout
+	}
+	return resolved
+}
+
+// find returns the record for the install of the profile.
+func (r *installRecords) find(o owner) (installRecord, bool) {
+	for _, p := range r.Profiles {
+		if p.owner() == o {
+			return p, true
+		}
+	}
+	return installRecord{}, false
+}
+
+// set records where the profile was installed, replacing any existing record
+// for the install.
+func (r *installRecords) set(record installRecord) {
+	for i, p := range r.Profiles {
+		if p.owner() == record.owner() {
+			r.Profiles[i] = record
+			return
+		}
+	}
+	r.Profiles = append(r.Profiles, record)
+}
+
+// remove removes the record for the install of the profile, and returns it.
+func (r *installRecords) remove(o owner) (installRecord, bool) {
+	for i, p := range r.Profiles {
+		if p.owner() == o {
+			r.Profiles = append(r.Profiles[:i], r.Profiles[i+1:]...)
+			return p, true
+		}
+	}
+	return installRecord{}, false
+}
+
+// ownedFiles returns the YAML files in the directory, and its subdirectories,
+// where every resource was generated for the install of the profile.
+func ownedFiles(fs billy.Filesystem, dir string, owner owner) ([]string, error) {
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := fs.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the directory %q: %w", dir, err)
+	}
+	owned := []string{}
+	for _, e := range entries {
+		name := path.Join(dir, e.Name())
+		if e.IsDir() {
+			if e.Name() == ".git" {
+				continue
+			}
+			files, err := ownedFiles(fs, name, owner)
+			if err != nil {
+				return nil, err
+			}
+			owned = append(owned, files...)
+			continue
+		}
+		if path.Ext(name) != ".yaml" || e.Name() == kustomizationFilename || e.Name() == installsFilename {
+			continue
+		}
+		b, err := readFile(fs, name)
+		if err != nil {
+			return nil, err
+		}
+		if isOwnedBy(b, owner) {
+			owned = append(owned, name)
+		}
+	}
+	return owned, nil
+}
+
+// staleFiles returns the files in the directory that were generated for the
+// install of the profile, and are not in the files generated for it now.
+func staleFiles(fs billy.Filesystem, dir string, owner owner, files []manifestFile) ([]string, error) {
+	owned, err := ownedFiles(fs, dir, owner)
+	if err != nil {
+		return nil, err
+	}
+	current := map[string]bool{}
+	for _, f := range files {
+		current[path.Clean(f.name)] = true
+	}
+	stale := []string{}
+	for _, name := range owned {
+		if !current[path.Clean(name)] {
+			stale = append(stale, name)
+		}
+	}
+	return stale, nil
+}
+
+// isOwnedBy returns true if every document in the YAML was generated for the
+// install of the profile, including documents that are shared with other
+// installs.
+func isOwnedBy(b []byte, owner owner) bool {
+	docs := splitDocuments(b)
+	for _, doc := range docs {
+		var current struct {
+			Metadata metav1.ObjectMeta `json:"metadata"`
+		}
+		if err := yaml.Unmarshal(doc, &current); err != nil {
+			return false
+		}
+		if !hasOwner(ownersOf(current.Metadata), owner) {
+			return false
+		}
+	}
+	return len(docs) > 0
+}
+
+// disownFiles removes the owner from the files that were generated for it,
+// and returns the files that have no other owners, which should be removed,
+// and the files that are shared with other installs, without the owner.
+func disownFiles(fs billy.Filesystem, names []string, owner owner) ([]string, []manifestFile, error) {
+	removed := []string{}
+	shared := []manifestFile{}
+	for _, name := range names {
+		b, err := readFile(fs, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		b, err = removeOwner(b, owner)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %q: %w", name, err)
+		}
+		if len(b) == 0 {
+			removed = append(removed, name)
+			continue
+		}
+		shared = append(shared, manifestFile{name: name, data: b})
+	}
+	return removed, shared, nil
+}
+
+// relativePath returns the path of the file relative to the directory.
+func relativePath(dir, name string) string {
+	if dir = path.Clean(dir); dir == "." {
+		return name
+	}
+	return strings.TrimPrefix(name, dir+"/")
+}
+
+// sameDir returns true if the paths are the same directory.
+func sameDir(a, b string) bool {
+	return path.Clean(a) == path.Clean(b)
+}
+
+// manifestFile is a file of generated manifests.
+type manifestFile struct {
+	name string
+	data []byte
+}
+
+// layoutFiles returns the files for the resources generated for the profile in
+// the layout, resources that collide with resources generated for other
+// profiles are rejected.
+func layoutFiles(fs billy.Filesystem, install installRecord, profileName string, objs []runtime.Object) ([]manifestFile, error) {
+	if err := checkOutputDir(install.OutputDir); err != nil {
+		return nil, err
+	}
+	if install.Layout == SingleFileLayout {
+		return singleFile(fs, install, profileName, objs)
+	}
+	files := []manifestFile{}
+	for _, o := range objs {
+		name, err := layoutFilename(install.Layout, install.OutputDir, profileName, o)
+		if err != nil {
+			return nil, err
+		}
+		owners, err := checkCollision(fs, name, o, install.owner())
+		if err != nil {
+			return nil, err
+		}
+		b, err := marshalWithOwners(o, owners)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, manifestFile{name: name, data: b})
+	}
+	return files, nil
+}
+
+// ManifestFilename returns the file that the resource generated for the
+// profile is written to in the output directory with the layout, an empty
+// layout is FlatLayout.
+func ManifestFilename(outputDir string, layout Layout, profileName string, o runtime.Object) (string, error) {
+	if err := checkOutputDir(outputDir); err != nil {
+		return "", err
+	}
+	if layout == "" {
+		layout = FlatLayout
+	}
+	return layoutFilename(layout, outputDir, profileName, o)
+}
+
+// checkOutputDir returns an error if the output directory is not inside the
+// repository.
+func checkOutputDir(dir string) error {
+	if clean := path.Clean(dir); path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return fmt.Errorf("output directory %q must be inside the repository", dir)
+	}
+	return nil
+}
+
+func singleFile(fs billy.Filesystem, install installRecord, profileName string, objs []runtime.Object) ([]manifestFile, error) {
+	name := path.Join(install.OutputDir, profileName+".yaml")
+	existing, err := readFile(fs, name)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, doc := range splitDocuments(existing) {
+		var current struct {
+			Metadata metav1.ObjectMeta `json:"metadata"`
+		}
+		if err := yaml.Unmarshal(doc, &current); err != nil {
+			return nil, fmt.Errorf("failed to parse %q: %w", name, err)
+		}
+		if owner := ownerOf(current.Metadata); owner.url != "" && owner != install.owner() {
+			return nil, fmt.Errorf("%q was generated for the profile %s, use a different output directory to install the profile %s alongside it", name, owner.url, install.URL)
+		}
+	}
+	docs := [][]byte{}
+	for _, o := range objs {
+		b, err := marshalWithOwner(o, install.owner())
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, b)
+	}
+	return []manifestFile{{name: name, data: bytes.Join(docs, []byte("---\n"))}}, nil
+}
+
+// splitDocuments splits a multi-document YAML file into its documents.
+func splitDocuments(b []byte) [][]byte {
+	docs := [][]byte{}
+	for _, doc := range bytes.Split(b, []byte("\n---\n")) {
+		doc = bytes.TrimPrefix(doc, []byte("---\n"))
+		if len(bytes.TrimSpace(doc)) > 0 {
+			docs = append(docs, doc)
+		}
+	}
+	return docs
+}
@@ -0,0 +1,289 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bigkevmcd/askja/pkg/profiles"
+	"github.com/bigkevmcd/askja/test"
+)
+
+const testLayoutProfile = `
+apiVersion: profiles.fluxcd.io/v1alpha1
+kind: Profile
+metadata:
+  name: nginx
+spec:
+  artifacts:
+    - name: nginx-server
+      path: nginx/chart
+`
+
+func TestInstallProfile_layouts(t *testing.T) {
+	layoutTests := []struct {
+		layout Layout
+		want   []string
+	}{
+		{
+			layout: FlatLayout,
+			want: []string{
+				".askja.yaml",
+				"deploy/gitrepository_subscription-nginx-profile-main.yaml",
+				"deploy/helmrelease_subscription-helm-release-nginx-server.yaml",
+				"deploy/kustomization.yaml",
+			},
+		},
+		{
+			layout: ProfileLayout,
+			want: []string{
+				".askja.yaml",
+				"deploy/kustomization.yaml",
+				"deploy/profiles/nginx/gitrepository_subscription-nginx-profile-main.yaml",
+				"deploy/profiles/nginx/helmrelease_subscription-helm-release-nginx-server.yaml",
+			},
+		},
+		{
+			layout: KindLayout,
+			want: []string{
+				".askja.yaml",
+				"deploy/gitrepository/subscription-nginx-profile-main.yaml",
+				"deploy/helmrelease/subscription-helm-release-nginx-server.yaml",
+				"deploy/kustomization.yaml",
+			},
+		},
+		{
+			layout: SingleFileLayout,
+			want: []string{
+				".askja.yaml",
+				"deploy/kustomization.yaml",
+				"deploy/nginx.yaml",
+			},
+		},
+	}
+
+	for _, tt := range layoutTests {
+		t.Run(string(tt.layout), func(t *testing.T) {
+			client := newMockClient()
+			dir, _ := test.MakeTempGitRepo(t)
+			DefaultClientFactory = func(ctx context.Context, s string) (Client, error) {
+				return client, nil
+			}
+			client.add("weaveworks/nginx-profile", "profile.yaml", "main", []byte(testLayoutProfile))
+
+			if err := InstallProfile(context.TODO(), dir, &InstallOptions{
+				ProfileOptions: &profiles.ProfileOptions{
+					ProfileURL: "https://github.com/weaveworks/nginx-profile.git",
+					Branch:     "main",
+				},
+				NewBranchName: "test-branch",
+				OutputDir:     "deploy",
+				Layout:        tt.layout,
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			committed := readFilesFromHead(t, dir)
+			if diff := cmp.Diff(tt.want, filenamesFrom(committed)); diff != "" {
+				t.Fatalf("written files don't match:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestInstallProfile_single_file(t *testing.T) {
+	client := newMockClient()
+	dir, _ := test.MakeTempGitRepo(t)
+	DefaultClientFactory = func(ctx context.Context, s string) (Client, error) {
+		return client, nil
+	}
+	client.add("weaveworks/nginx-profile", "profile.yaml", "main", []byte(testLayoutProfile))
+
+	if err := InstallProfile(context.TODO(), dir, &InstallOptions{
+		ProfileOptions: &profiles.ProfileOptions{
+			ProfileURL: "https://github.com/weaveworks/nginx-profile.git",
+			Branch:     "main",
+		},
+		NewBranchName: "test-branch",
+		Layout:        SingleFileLayout,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	committed := readFilesFromHead(t, dir)
+	docs := splitDocuments(committed["nginx.yaml"])
+	if len(docs) != 2 {
+		t.Fatalf("got %d documents, want 2:\n%s", len(docs), committed["nginx.yaml"])
+	}
+}
+
+func TestInstallProfile_recorded_layout(t *testing.T) {
+	client := newMockClient()
+	dir, _ := test.MakeTempGitRepo(t)
+	DefaultClientFactory = func(ctx context.Context, s string) (Client, error) {
+		return client, nil
+	}
+	client.add("weaveworks/nginx-profile", "profile.yaml", "main", []byte(testLayoutProfile))
+	client.add("weaveworks/nginx-profile", "profile.yaml", "v0.2.0", []byte(testLayoutProfile))
+	install := func(n int, opts *InstallOptions) {
+		t.Helper()
+		opts.NewBranchName = fmt.Sprintf("test-branch-%d", n)
+		if err := InstallProfile(context.TODO(), dir, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	install(1, &InstallOptions{
+		ProfileOptions: &profiles.ProfileOptions{
+			ProfileURL: "https://github.com/weaveworks/nginx-profile.git",
+			Branch:     "main",
+		},
+		OutputDir: "deploy",
+		Layout:    KindLayout,
+	})
+
+	install(2, &InstallOptions{
+		ProfileOptions: &profiles.ProfileOptions{
+			ProfileURL: "https://github.com/weaveworks/nginx-profile.git",
+			Tag:        "v0.2.0",
+		},
+	})
+
+	// The GitRepository for the previous ref is removed, and the HelmRelease
+	// is modified in place.
+	want := []string{
+		"deploy/gitrepository/subscription-nginx-profile-v0.2.0.yaml",
+		"deploy/helmrelease/subscription-helm-release-nginx-server.yaml",
+		"deploy/kustomization.yaml",
+	}
+	if diff := cmp.Diff(want, filesInDir(t, dir, "deploy")); diff != "" {
+		t.Fatalf("written files don't match:\n%s", diff)
+	}
+	wantKustomization := "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n  - helmrelease/subscription-helm-release-nginx-server.yaml\n  - gitrepository/subscription-nginx-profile-v0.2.0.yaml\n"
+	if diff := cmp.Diff(wantKustomization, readRepoFile(t, dir, "deploy/kustomization.yaml")); diff != "" {
+		t.Fatalf("kustomization doesn't match:\n%s", diff)
+	}
+}
+
+func TestInstallProfile_output_dir_outside_repository(t *testing.T) {
+	client := newMockClient()
+	dir, _ := test.MakeTempGitRepo(t)
+	DefaultClientFactory = func(ctx context.Context, s string) (Client, error) {
+		return client, nil
+	}
+	client.add("weaveworks/nginx-profile", "profile.yaml", "main", []byte(testLayoutProfile))
+
+	err := InstallProfile(context.TODO(), dir, &InstallOptions{
+		ProfileOptions: &profiles.ProfileOptions{
+			ProfileURL: "https://github.com/weaveworks/nginx-profile.git",
+			Branch:     "main",
+		},
+		NewBranchName: "test-branch",
+		OutputDir:     "../deploy",
+	})
+
+	want := `output directory "../deploy" must be inside the repository`
+	if err == nil || err.Error() != want {
+		t.Fatalf("got error %v, want %q", err, want)
+	}
+}
+
+func TestInstallProfile_removes_stale_files(t *testing.T) {
+	client := newMockClient()
+	dir, _ := test.MakeTempGitRepo(t)
+	DefaultClientFactory = func(ctx context.Context, s string) (Client, error) {
+		return client, nil
+	}
+	client.add("weaveworks/nginx-profile", "profile.yaml", "main", []byte(testLayoutProfile))
+	install := func(n int, outputDir string, layout Layout) {
+		t.Helper()
+		if err := InstallProfile(context.TODO(), dir, &InstallOptions{
+			ProfileOptions: &profiles.ProfileOptions{
+				ProfileURL: "https://github.com/weaveworks/nginx-profile.git",
+				Branch:     "main",
+			},
+			NewBranchName: fmt.Sprintf("test-branch-%d", n),
+			OutputDir:     outputDir,
+			Layout:        layout,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	install(1, "deploy", FlatLayout)
+	install(2, "deploy", SingleFileLayout)
+
+	want := []string{"deploy/kustomization.yaml", "deploy/nginx.yaml"}
+	if diff := cmp.Diff(want, filesInDir(t, dir, "deploy")); diff != "" {
+		t.Fatalf("files after changing the layout don't match:\n%s", diff)
+	}
+	wantKustomization := "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n  - nginx.yaml\n"
+	if diff := cmp.Diff(wantKustomization, readRepoFile(t, dir, "deploy/kustomization.yaml")); diff != "" {
+		t.Fatalf("kustomization after changing the layout doesn't match:\n%s", diff)
+	}
+
+	install(3, "clusters/dev", "")
+
+	want = []string{"clusters/dev/kustomization.yaml", "clusters/dev/nginx.yaml", "deploy/kustomization.yaml"}
+	if diff := cmp.Diff(want, append(filesInDir(t, dir, "clusters"), filesInDir(t, dir, "deploy")...)); diff != "" {
+		t.Fatalf("files after changing the output directory don't match:\n%s", diff)
+	}
+	wantKustomization = "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources: []\n"
+	if diff := cmp.Diff(wantKustomization, readRepoFile(t, dir, "deploy/kustomization.yaml")); diff != "" {
+		t.Fatalf("kustomization after changing the output directory doesn't match:\n%s", diff)
+	}
+}
+
+func TestParseLayout(t *testing.T) {
+	for _, l := range Layouts {
+		got, err := ParseLayout(string(l))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != l {
+			t.Fatalf("ParseLayout(%q) got %q", l, got)
+		}
+	}
+
+	_, err := ParseLayout("nested")
+	want := `unknown layout "nested", must be one of flat, per-profile, per-kind, single`
+	if err == nil || err.Error() != want {
+		t.Fatalf("got error %v, want %q", err, want)
+	}
+}
+
+// filesInDir returns the paths of the files in the directory in the
+// repository, relative to the root of the repository.
+func filesInDir(t *testing.T, dir, name string) []string {
+	t.Helper()
+	files := []string{}
+	err := filepath.Walk(filepath.Join(dir, name), func(p string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func readRepoFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
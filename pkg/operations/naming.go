@@ -1,23 +1,271 @@
 package operations
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"os"
 	"path"
 	"strings"
 
+	"github.com/go-git/go-billy/v5"
 	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+
+	"github.com/bigkevmcd/askja/pkg/profiles"
+)
+
+const (
+	// profileAnnotation is set on the generated resources to the URL of the
+	// profile that they were generated for, so that resources with the same
+	// name from different profiles are detected.
+	profileAnnotation = "askja.io/profile-url"
+	// namePrefixAnnotation is set on the generated resources to the name
+	// prefix that the profile was installed with, so that installs of the
+	// same profile with different prefixes own different resources.
+	namePrefixAnnotation = "askja.io/name-prefix"
+	// sharedWithAnnotation is set on generated resources that are shared
+	// between installs e.g. a HelmRepository for the same chart repository,
+	// to the other owners of the resource as space separated prefix=url
+	// pairs, so that the resource is only removed with its last owner.
+	sharedWithAnnotation = "askja.io/shared-with"
 )
 
+// owner identifies an install of a profile, the same profile can be installed
+// more than once with different name prefixes.
+type owner struct {
+	url        string
+	namePrefix string
+}
+
+// newOwner returns the owner for the profile URL and name prefix, an empty
+// prefix is the default prefix, which resources generated before the prefix
+// was recorded have.
+func newOwner(url, namePrefix string) owner {
+	if namePrefix == "" {
+		namePrefix = profiles.DefaultNamePrefix
+	}
+	return owner{url: url, namePrefix: namePrefix}
+}
+
+// ownerOf returns the owner recorded in the annotations of a resource, the url
+// is empty if the resource was not generated for a profile.
+func ownerOf(m metav1.ObjectMeta) owner {
+	return newOwner(m.Annotations[profileAnnotation], m.Annotations[namePrefixAnnotation])
+}
+
+// ownersOf returns the owners recorded in the annotations of a resource, the
+// owner in the profile annotation first, and nil if the resource was not
+// generated for a profile.
+func ownersOf(m metav1.ObjectMeta) []owner {
+	first := ownerOf(m)
+	if first.url == "" {
+		return nil
+	}
+	owners := []owner{first}
+	for _, s := range strings.Fields(m.Annotations[sharedWithAnnotation]) {
+		if parts := strings.SplitN(s, "=", 2); len(parts) == 2 {
+			owners = append(owners, newOwner(parts[1], parts[0]))
+		}
+	}
+	return owners
+}
+
+// hasOwner returns true if the owner is one of the owners.
+func hasOwner(owners []owner, o owner) bool {
+	for _, v := range owners {
+		if v == o {
+			return true
+		}
+	}
+	return false
+}
+
+// setOwnerAnnotations sets the profile and name prefix annotations to the
+// first owner, and the shared with annotation to the other owners.
+func setOwnerAnnotations(annotations map[string]string, owners []owner) {
+	annotations[profileAnnotation] = owners[0].url
+	annotations[namePrefixAnnotation] = owners[0].namePrefix
+	shared := []string{}
+	for _, o := range owners[1:] {
+		shared = append(shared, o.namePrefix+"="+o.url)
+	}
+	delete(annotations, sharedWithAnnotation)
+	if len(shared) > 0 {
+		annotations[sharedWithAnnotation] = strings.Join(shared, " ")
+	}
+}
+
 func filenameFrom(base string, o runtime.Object) (string, error) {
+	kind, name, err := kindAndName(o)
+	if err != nil {
+		return "", err
+	}
+	filename := strings.Join([]string{kind, name}, "_") + ".yaml"
+	return path.Join(base, filename), nil
+}
+
+// layoutFilename returns the filename for the resource in the layout, for
+// SingleFileLayout, all the resources are in the file for the profile.
+func layoutFilename(layout Layout, dir, profileName string, o runtime.Object) (string, error) {
+	switch layout {
+	case ProfileLayout:
+		return filenameFrom(path.Join(dir, "profiles", profileName), o)
+	case KindLayout:
+		kind, name, err := kindAndName(o)
+		if err != nil {
+			return "", err
+		}
+		return path.Join(dir, kind, name+".yaml"), nil
+	case SingleFileLayout:
+		return path.Join(dir, profileName+".yaml"), nil
+	}
+	return filenameFrom(dir, o)
+}
+
+// kindAndName returns the lowercased kind and the name of the resource.
+func kindAndName(o runtime.Object) (string, string, error) {
 	oa, err := meta.Accessor(o)
 	if err != nil {
-		return "", fmt.Errorf("failed to get the object meta for object %#v: %w", o, err)
+		return "", "", fmt.Errorf("failed to get the object meta for object %#v: %w", o, err)
 	}
 	ta, err := meta.TypeAccessor(o)
 	if err != nil {
-		return "", fmt.Errorf("failed to get the type meta for object %#v: %w", o, err)
+		return "", "", fmt.Errorf("failed to get the type meta for object %#v: %w", o, err)
 	}
-	filename := strings.Join([]string{strings.ToLower(ta.GetKind()), oa.GetName()}, "_") + ".yaml"
-	return path.Join(base, filename), nil
+	return strings.ToLower(ta.GetKind()), oa.GetName(), nil
+}
+
+// marshalWithOwner returns the resource as YAML, with the profile and name
+// prefix annotations set to the owner.
+func marshalWithOwner(o runtime.Object, installOwner owner) ([]byte, error) {
+	return marshalWithOwners(o, []owner{installOwner})
+}
+
+// marshalWithOwners returns the resource as YAML, with the owner annotations
+// set to the owners.
+func marshalWithOwners(o runtime.Object, owners []owner) ([]byte, error) {
+	if err := setOwners(o, owners); err != nil {
+		return nil, err
+	}
+	b, err := yaml.Marshal(o)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal: %w", err)
+	}
+	return b, nil
+}
+
+// setOwner sets the profile and name prefix annotations on the resource to
+// the owner.
+func setOwner(o runtime.Object, installOwner owner) error {
+	return setOwners(o, []owner{installOwner})
+}
+
+// setOwners sets the owner annotations on the resource to the owners.
+func setOwners(o runtime.Object, owners []owner) error {
+	oa, err := meta.Accessor(o)
+	if err != nil {
+		return fmt.Errorf("failed to get the object meta for object %#v: %w", o, err)
+	}
+	annotations := oa.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	setOwnerAnnotations(annotations, owners)
+	oa.SetAnnotations(annotations)
+	return nil
+}
+
+// checkCollision returns the owners to write the resource with, and an error
+// if the file contains a resource that was generated for a different profile,
+// unless the resource is the same e.g. a HelmRepository that is used by both
+// profiles, which is then shared with the owner.
+func checkCollision(fs billy.Filesystem, filename string, o runtime.Object, installOwner owner) ([]owner, error) {
+	existing, err := readFile(fs, filename)
+	if os.IsNotExist(err) {
+		return []owner{installOwner}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var current struct {
+		Metadata metav1.ObjectMeta `json:"metadata"`
+	}
+	if err := yaml.Unmarshal(existing, &current); err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %w", filename, err)
+	}
+	owners := ownersOf(current.Metadata)
+	if owners == nil {
+		return []owner{installOwner}, nil
+	}
+	if hasOwner(owners, installOwner) {
+		return owners, nil
+	}
+	shared, err := marshalWithOwners(o, owners)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(shared, existing) {
+		return append(owners, installOwner), nil
+	}
+	return nil, fmt.Errorf("%q was generated for the profile %s, use a different name prefix to install the profile %s alongside it", filename, owners[0].url, installOwner.url)
+}
+
+// removeOwner returns the YAML with the owner removed from the owners of each
+// document, documents with no other owners are removed.
+func removeOwner(b []byte, installOwner owner) ([]byte, error) {
+	docs := [][]byte{}
+	for _, doc := range splitDocuments(b) {
+		var current struct {
+			Metadata metav1.ObjectMeta `json:"metadata"`
+		}
+		if err := yaml.Unmarshal(doc, &current); err != nil {
+			return nil, err
+		}
+		owners := []owner{}
+		for _, o := range ownersOf(current.Metadata) {
+			if o != installOwner {
+				owners = append(owners, o)
+			}
+		}
+		if len(owners) == 0 {
+			continue
+		}
+		var resource map[string]interface{}
+		if err := yaml.Unmarshal(doc, &resource); err != nil {
+			return nil, err
+		}
+		metadata, ok := resource["metadata"].(map[string]interface{})
+		if !ok {
+			metadata = map[string]interface{}{}
+			resource["metadata"] = metadata
+		}
+		annotations := map[string]string{}
+		for k, v := range current.Metadata.Annotations {
+			annotations[k] = v
+		}
+		setOwnerAnnotations(annotations, owners)
+		metadata["annotations"] = annotations
+		updated, err := yaml.Marshal(resource)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal: %w", err)
+		}
+		docs = append(docs, updated)
+	}
+	return bytes.Join(docs, []byte("---\n")), nil
+}
+
+func readFile(fs billy.Filesystem, filename string) ([]byte, error) {
+	f, err := fs.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q: %w", filename, err)
+	}
+	return b, nil
 }
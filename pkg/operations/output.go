@@ -0,0 +1,80 @@
+package operations
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+// OutputFormat is the format that generated resources are written in.
+type OutputFormat string
+
+const (
+	// YAMLOutput writes the resources as a multi-document YAML stream.
+	YAMLOutput OutputFormat = "yaml"
+	// JSONOutput writes the resources as the items of a v1 List.
+	JSONOutput OutputFormat = "json"
+)
+
+// OutputFormats are the supported output formats.
+var OutputFormats = []OutputFormat{YAMLOutput, JSONOutput}
+
+// ParseOutputFormat returns the OutputFormat with the name, an empty name is
+// YAMLOutput.
+func ParseOutputFormat(s string) (OutputFormat, error) {
+	if s == "" {
+		return YAMLOutput, nil
+	}
+	for _, f := range OutputFormats {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+	names := []string{}
+	for _, f := range OutputFormats {
+		names = append(names, string(f))
+	}
+	return "", fmt.Errorf("unknown output format %q, must be one of %s", s, strings.Join(names, ", "))
+}
+
+// WriteResources writes the resources to w in the format.
+func WriteResources(w io.Writer, objs []runtime.Object, format OutputFormat) error {
+	var b []byte
+	switch format {
+	case JSONOutput:
+		list := &metav1.List{
+			TypeMeta: metav1.TypeMeta{Kind: "List", APIVersion: "v1"},
+			Items:    []runtime.RawExtension{},
+		}
+		for _, o := range objs {
+			list.Items = append(list.Items, runtime.RawExtension{Object: o})
+		}
+		encoded, err := json.MarshalIndent(list, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal: %w", err)
+		}
+		b = append(encoded, '\n')
+	case YAMLOutput, "":
+		docs := [][]byte{}
+		for _, o := range objs {
+			doc, err := yaml.Marshal(o)
+			if err != nil {
+				return fmt.Errorf("failed to marshal: %w", err)
+			}
+			docs = append(docs, doc)
+		}
+		b = bytes.Join(docs, []byte("---\n"))
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+	if _, err := w.Write(b); err != nil {
+		return fmt.Errorf("failed to write the resources: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package operations
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestWriteResources(t *testing.T) {
+	objs := []runtime.Object{
+		testNamespace("apps"),
+		testNamespace("flux-system"),
+	}
+	outputTests := []struct {
+		format OutputFormat
+		want   string
+	}{
+		{
+			format: YAMLOutput,
+			want: `apiVersion: v1
+kind: Namespace
+metadata:
+  creationTimestamp: null
+  name: apps
+spec: {}
+status: {}
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  creationTimestamp: null
+  name: flux-system
+spec: {}
+status: {}
+`,
+		},
+		{
+			format: JSONOutput,
+			want: `{
+  "kind": "List",
+  "apiVersion": "v1",
+  "metadata": {},
+  "items": [
+    {
+      "kind": "Namespace",
+      "apiVersion": "v1",
+      "metadata": {
+        "name": "apps",
+        "creationTimestamp": null
+      },
+      "spec": {},
+      "status": {}
+    },
+    {
+      "kind": "Namespace",
+      "apiVersion": "v1",
+      "metadata": {
+        "name": "flux-system",
+        "creationTimestamp": null
+      },
+      "spec": {},
+      "status": {}
+    }
+  ]
+}
+`,
+		},
+	}
+
+	for _, tt := range outputTests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteResources(&buf, objs, tt.format); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, buf.String()); diff != "" {
+				t.Fatalf("failed to write the resources:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseOutputFormat(t *testing.T) {
+	for _, f := range OutputFormats {
+		got, err := ParseOutputFormat(string(f))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != f {
+			t.Fatalf("ParseOutputFormat(%q) got %q", f, got)
+		}
+	}
+
+	got, err := ParseOutputFormat("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != YAMLOutput {
+		t.Fatalf("ParseOutputFormat(\"\") got %q, want %q", got, YAMLOutput)
+	}
+
+	_, err = ParseOutputFormat("xml")
+	want := `unknown output format "xml", must be one of yaml, json`
+	if err == nil || err.Error() != want {
+		t.Fatalf("got error %v, want %q", err, want)
+	}
+}
+
+func testNamespace(name string) *corev1.Namespace {
+	return &corev1.Namespace{
+		TypeMeta:   metav1.TypeMeta{Kind: "Namespace", APIVersion: "v1"},
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+	}
+}
@@ -0,0 +1,220 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	// GitHubDriver fetches files from github.com.
+	GitHubDriver = "github"
+	// GitLabDriver fetches files from GitLab servers.
+	GitLabDriver = "gitlab"
+	// BitbucketServerDriver fetches files from Bitbucket Server.
+	BitbucketServerDriver = "bitbucket-server"
+	// GiteaDriver fetches files from Gitea servers.
+	GiteaDriver = "gitea"
+)
+
+// Driver describes how to create a Client for a git host, and how to
+// authenticate the requests that the Client makes.
+type Driver struct {
+	// New should return a Client that accesses the git host at the provided
+	// base URL e.g. https://gitlab.example.com.
+	New func(baseURL string, c *http.Client) Client
+	// Authenticate adds the credentials to a request in the form the git host
+	// expects.
+	Authenticate func(r *http.Request, creds *Credentials)
+	// APIHosts are hosts other than the git host that the Client makes
+	// requests to, which should also be authenticated.
+	APIHosts []string
+}
+
+// ClientRegistry creates Clients for repositories based on the host in the
+// repository URL.
+//
+// Self-hosted git servers can be mapped to the driver that understands the
+// server's API with AddHost.
+type ClientRegistry struct {
+	// Fallback is used to create clients for repositories that are not
+	// accessed over HTTP(S), or that are on hosts without a driver.
+	Fallback ClientFactory
+
+	client      *http.Client
+	credentials CredentialsResolver
+	drivers     map[string]Driver
+	hosts       map[string]string
+}
+
+// NewClientRegistry creates and returns a ClientRegistry with the built-in
+// drivers registered, and the well-known public hosts mapped to them.
+//
+// If a CredentialsResolver is provided, the credentials it returns for a host
+// are used to authenticate the requests to that host.
+func NewClientRegistry(c *http.Client, creds CredentialsResolver) *ClientRegistry {
+	r := &ClientRegistry{
+		client:      c,
+		credentials: creds,
+		drivers:     map[string]Driver{},
+		hosts:       map[string]string{},
+	}
+	r.RegisterDriver(GitHubDriver, Driver{
+		New: func(_ string, c *http.Client) Client {
+			return NewRawGitHubClient(c)
+		},
+		Authenticate: tokenAuth,
+		APIHosts:     []string{"raw.githubusercontent.com", "api.github.com"},
+	})
+	r.RegisterDriver(GitLabDriver, Driver{
+		New: func(baseURL string, c *http.Client) Client {
+			return NewGitLabClient(baseURL, c)
+		},
+		Authenticate: gitLabAuth,
+	})
+	r.RegisterDriver(BitbucketServerDriver, Driver{
+		New: func(baseURL string, c *http.Client) Client {
+			return NewBitbucketServerClient(baseURL, c)
+		},
+		Authenticate: bearerAuth,
+	})
+	r.RegisterDriver(GiteaDriver, Driver{
+		New: func(baseURL string, c *http.Client) Client {
+			return NewGiteaClient(baseURL, c)
+		},
+		Authenticate: tokenAuth,
+	})
+
+	r.hosts["github.com"] = GitHubDriver
+	r.hosts["gitlab.com"] = GitLabDriver
+	r.hosts["gitea.com"] = GiteaDriver
+	r.hosts["codeberg.org"] = GiteaDriver
+	return r
+}
+
+// RegisterDriver adds a named driver to the registry, replacing any existing
+// driver with the same name.
+func (r *ClientRegistry) RegisterDriver(name string, d Driver) {
+	r.drivers[name] = d
+}
+
+// AddHost maps a host (with optional port) to a registered driver.
+func (r *ClientRegistry) AddHost(host, driver string) error {
+	if _, ok := r.drivers[driver]; !ok {
+		return fmt.Errorf("unknown git driver %q for host %q, must be one of %s",
+			driver, host, strings.Join(r.driverNames(), ", "))
+	}
+	r.hosts[strings.ToLower(host)] = driver
+	return nil
+}
+
+// ClientFactory is a ClientFactory implementation that returns a Client for
+// the driver that is mapped to the host in the repoURL.
+//
+// If no driver is mapped to the host, the Fallback is used if configured.
+func (r *ClientRegistry) ClientFactory(ctx context.Context, repoURL string) (Client, error) {
+	parsed, err := url.Parse(repoURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		if r.Fallback != nil {
+			return r.Fallback(ctx, repoURL)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse repo URL %q: %w", repoURL, err)
+		}
+		return nil, fmt.Errorf("unsupported repo URL %q, only http and https are supported", repoURL)
+	}
+	name, ok := r.hosts[strings.ToLower(parsed.Host)]
+	if !ok {
+		if r.Fallback != nil {
+			return r.Fallback(ctx, repoURL)
+		}
+		return nil, fmt.Errorf("unsupported git host %q, no driver is configured for it", parsed.Host)
+	}
+	driver := r.drivers[name]
+	client, err := r.clientFor(ctx, parsed.Host, driver)
+	if err != nil {
+		return nil, err
+	}
+	baseURL := url.URL{Scheme: parsed.Scheme, Host: parsed.Host}
+	return driver.New(baseURL.String(), client), nil
+}
+
+func (r *ClientRegistry) clientFor(ctx context.Context, host string, d Driver) (*http.Client, error) {
+	if r.credentials == nil || d.Authenticate == nil {
+		return r.client, nil
+	}
+	creds, err := r.credentials.Credentials(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credentials for %q: %w", host, err)
+	}
+	if creds == nil {
+		return r.client, nil
+	}
+	hosts := map[string]bool{strings.ToLower(host): true}
+	for _, h := range d.APIHosts {
+		hosts[h] = true
+	}
+	authenticated := *r.client
+	authenticated.Transport = &authTransport{
+		base:         r.client.Transport,
+		creds:        creds,
+		authenticate: d.Authenticate,
+		hosts:        hosts,
+	}
+	return &authenticated, nil
+}
+
+func (r *ClientRegistry) driverNames() []string {
+	names := []string{}
+	for k := range r.drivers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// authTransport adds credentials to requests, only requests to the hosts for
+// the git host are authenticated, so that credentials aren't leaked to other
+// hosts when following redirects.
+type authTransport struct {
+	base         http.RoundTripper
+	creds        *Credentials
+	authenticate func(*http.Request, *Credentials)
+	hosts        map[string]bool
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if !t.hosts[strings.ToLower(req.URL.Host)] {
+		return base.RoundTrip(req)
+	}
+	authenticated := req.Clone(req.Context())
+	t.authenticate(authenticated, t.creds)
+	return base.RoundTrip(authenticated)
+}
+
+func tokenAuth(r *http.Request, creds *Credentials) {
+	if creds.Username != "" {
+		r.SetBasicAuth(creds.Username, creds.Password)
+		return
+	}
+	r.Header.Set("Authorization", "token "+creds.Password)
+}
+
+func bearerAuth(r *http.Request, creds *Credentials) {
+	if creds.Username != "" {
+		r.SetBasicAuth(creds.Username, creds.Password)
+		return
+	}
+	r.Header.Set("Authorization", "Bearer "+creds.Password)
+}
+
+func gitLabAuth(r *http.Request, creds *Credentials) {
+	r.Header.Set("PRIVATE-TOKEN", creds.Password)
+}
@@ -0,0 +1,165 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestClientRegistry(t *testing.T) {
+	r := NewClientRegistry(http.DefaultClient, nil)
+	if err := r.AddHost("gitlab.example.com", GitLabDriver); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.AddHost("git.example.com:8443", GiteaDriver); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.AddHost("bitbucket.example.com", BitbucketServerDriver); err != nil {
+		t.Fatal(err)
+	}
+
+	clientTests := []struct {
+		repoURL string
+		want    Client
+	}{
+		{"https://github.com/weaveworks/nginx-profile.git", NewRawGitHubClient(http.DefaultClient)},
+		{"https://gitlab.com/weaveworks/nginx-profile.git", NewGitLabClient("https://gitlab.com", http.DefaultClient)},
+		{"https://GitLab.example.com/weaveworks/nginx-profile.git", NewGitLabClient("https://GitLab.example.com", http.DefaultClient)},
+		{"https://git.example.com:8443/weaveworks/nginx-profile.git", NewGiteaClient("https://git.example.com:8443", http.DefaultClient)},
+		{"https://bitbucket.example.com/scm/WW/nginx-profile.git", NewBitbucketServerClient("https://bitbucket.example.com", http.DefaultClient)},
+	}
+
+	for _, tt := range clientTests {
+		t.Run(tt.repoURL, func(t *testing.T) {
+			c, err := r.ClientFactory(context.TODO(), tt.repoURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tt.want, c, cmp.Comparer(func(x, y *http.Client) bool { return x == y })); diff != "" {
+				t.Fatalf("failed to create client:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestClientRegistry_credentials(t *testing.T) {
+	authTests := []struct {
+		driver     string
+		creds      *Credentials
+		wantHeader string
+		want       string
+	}{
+		{GiteaDriver, &Credentials{Password: "test-token"}, "Authorization", "token test-token"},
+		{GiteaDriver, &Credentials{Username: "user", Password: "pass"}, "Authorization", "Basic dXNlcjpwYXNz"},
+		{GitLabDriver, &Credentials{Password: "test-token"}, "Private-Token", "test-token"},
+		{BitbucketServerDriver, &Credentials{Password: "test-token"}, "Authorization", "Bearer test-token"},
+	}
+
+	for _, tt := range authTests {
+		t.Run(tt.driver+" "+tt.want, func(t *testing.T) {
+			var got string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header.Get(tt.wantHeader)
+				fmt.Fprint(w, "testing")
+			}))
+			t.Cleanup(ts.Close)
+			tsURL, err := url.Parse(ts.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := NewClientRegistry(ts.Client(), stubCredentials{tsURL.Host: tt.creds})
+			if err := r.AddHost(tsURL.Host, tt.driver); err != nil {
+				t.Fatal(err)
+			}
+
+			c, err := r.ClientFactory(context.TODO(), ts.URL+"/PRJ/repo.git")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := c.FileContents(context.TODO(), "PRJ/repo", "profile.yaml", "main"); err != nil {
+				t.Fatal(err)
+			}
+
+			if got != tt.want {
+				t.Fatalf("got %s header %q, want %q", tt.wantHeader, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientRegistry_credentials_context(t *testing.T) {
+	type ctxKey struct{}
+	var got interface{}
+	helper := &GitCredentialHelper{run: func(ctx context.Context, b []byte) ([]byte, error) {
+		got = ctx.Value(ctxKey{})
+		return nil, ctx.Err()
+	}}
+	r := NewClientRegistry(http.DefaultClient, helper)
+	if err := r.AddHost("git.example.com", GiteaDriver); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.WithValue(context.TODO(), ctxKey{}, "install")
+
+	if _, err := r.ClientFactory(ctx, "https://git.example.com/weaveworks/nginx-profile.git"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got != "install" {
+		t.Fatalf("credentials were looked up with context value %v, want the context passed to the factory", got)
+	}
+}
+
+func TestClientRegistry_unknown_host(t *testing.T) {
+	r := NewClientRegistry(http.DefaultClient, nil)
+
+	_, err := r.ClientFactory(context.TODO(), "https://git.example.com/weaveworks/nginx-profile.git")
+
+	want := `unsupported git host "git.example.com", no driver is configured for it`
+	if err == nil || err.Error() != want {
+		t.Fatalf("got error %v, want %q", err, want)
+	}
+}
+
+func TestClientRegistry_fallback(t *testing.T) {
+	r := NewClientRegistry(http.DefaultClient, nil)
+	r.Fallback = NewGitCloneClientFactory(GitCloneOptions{})
+
+	for _, u := range []string{
+		"https://git.example.com/weaveworks/nginx-profile.git",
+		"ssh://git@github.com/weaveworks/nginx-profile.git",
+		"git@github.com:weaveworks/nginx-profile.git",
+	} {
+		t.Run(u, func(t *testing.T) {
+			c, err := r.ClientFactory(context.TODO(), u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := c.(*GitCloneClient); !ok {
+				t.Fatalf("got client %T, want *GitCloneClient", c)
+			}
+		})
+	}
+}
+
+func TestClientRegistry_AddHost_unknown_driver(t *testing.T) {
+	r := NewClientRegistry(http.DefaultClient, nil)
+
+	err := r.AddHost("git.example.com", "svn")
+
+	want := `unknown git driver "svn" for host "git.example.com", must be one of bitbucket-server, gitea, github, gitlab`
+	if err == nil || err.Error() != want {
+		t.Fatalf("got error %v, want %q", err, want)
+	}
+}
+
+type stubCredentials map[string]*Credentials
+
+func (s stubCredentials) Credentials(ctx context.Context, host string) (*Credentials, error) {
+	return s[host], nil
+}
@@ -0,0 +1,163 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DefaultRequestTimeout is the timeout for each attempt of the requests made
+// by the DefaultHTTPClient.
+const DefaultRequestTimeout = 30 * time.Second
+
+// DefaultRetryOptions are the retry options used by the DefaultHTTPClient.
+var DefaultRetryOptions = RetryOptions{
+	MaxRetries:     4,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     10 * time.Second,
+}
+
+// DefaultHTTPClient is the HTTP client used by the DefaultClientRegistry.
+var DefaultHTTPClient = NewHTTPClient(DefaultRequestTimeout, DefaultRetryOptions)
+
+// RetryOptions configures the retrying of failed requests.
+type RetryOptions struct {
+	// MaxRetries is the number of times a request is retried after the first
+	// attempt.
+	MaxRetries int
+	// InitialBackoff is the delay before the first retry, the delay doubles
+	// for each subsequent retry.
+	InitialBackoff time.Duration
+	// MaxBackoff is the maximum delay between retries, this doesn't apply to
+	// delays requested by the server with a Retry-After header.
+	MaxBackoff time.Duration
+	// AttemptTimeout is the timeout for each attempt, including reading the
+	// response body, if this is zero, attempts are only bounded by the
+	// request's context.
+	AttemptTimeout time.Duration
+}
+
+// NewHTTPClient creates and returns an http.Client that times out each
+// attempt of a request after timeout, and retries requests that fail with
+// transient errors.
+func NewHTTPClient(timeout time.Duration, opts RetryOptions) *http.Client {
+	opts.AttemptTimeout = timeout
+	return &http.Client{
+		Transport: NewRetryTransport(http.DefaultTransport, opts),
+	}
+}
+
+// NewRetryTransport wraps an http.RoundTripper, retrying requests that fail
+// with a network error, a 5xx status or 429 Too Many Requests.
+//
+// Retries are delayed with exponential backoff, unless the response has a
+// Retry-After header, and stop when the request's context is done, if the
+// server asks for a delay that would pass the context's deadline, the
+// response is returned without retrying.
+func NewRetryTransport(base http.RoundTripper, opts RetryOptions) http.RoundTripper {
+	return &retryTransport{base: base, opts: opts}
+}
+
+type retryTransport struct {
+	base http.RoundTripper
+	opts RetryOptions
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	backoff := t.opts.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.Body != nil && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to reset the request body: %w", err)
+			}
+			req.Body = body
+		}
+		resp, err := t.roundTrip(req)
+		if attempt >= t.opts.MaxRetries || !isRetryable(req, resp, err) {
+			return resp, err
+		}
+
+		delay := backoff
+		if delay > t.opts.MaxBackoff {
+			delay = t.opts.MaxBackoff
+		}
+		if resp != nil {
+			if after, ok := retryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				if deadline, ok := req.Context().Deadline(); ok && time.Now().Add(after).After(deadline) {
+					return resp, nil
+				}
+				delay = after
+			}
+			resp.Body.Close()
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// roundTrip makes a single attempt of the request, with the AttemptTimeout,
+// the timeout is cancelled when the response body is closed.
+func (t *retryTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	if t.opts.AttemptTimeout <= 0 {
+		return t.base.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), t.opts.AttemptTimeout)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+func isRetryable(req *http.Request, resp *http.Response, err error) bool {
+	if req.Body != nil && req.GetBody == nil {
+		return false
+	}
+	if err != nil {
+		return req.Context().Err() == nil
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
+
+// retryAfter parses a Retry-After header, which can be a number of seconds or
+// an HTTP date.
+func retryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,184 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testRetryOptions = RetryOptions{
+	MaxRetries:     3,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     10 * time.Millisecond,
+}
+
+func TestRetryTransport(t *testing.T) {
+	retryTests := []struct {
+		name         string
+		failures     []int
+		wantAttempts int
+		wantStatus   int
+	}{
+		{"success", nil, 1, http.StatusOK},
+		{"server errors", []int{http.StatusBadGateway, http.StatusServiceUnavailable}, 3, http.StatusOK},
+		{"too many requests", []int{http.StatusTooManyRequests}, 2, http.StatusOK},
+		{"not found is not retried", []int{http.StatusNotFound}, 1, http.StatusNotFound},
+		{"too many failures", []int{500, 500, 500, 500, 500}, 4, http.StatusInternalServerError},
+	}
+
+	for _, tt := range retryTests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				if attempts <= len(tt.failures) {
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(tt.failures[attempts-1])
+					return
+				}
+				fmt.Fprint(w, "testing")
+			}))
+			t.Cleanup(ts.Close)
+			client := &http.Client{Transport: NewRetryTransport(http.DefaultTransport, testRetryOptions)}
+
+			resp, err := client.Get(ts.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("got %d attempts, want %d", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRetryTransport_context_cancelled(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(ts.Close)
+	client := &http.Client{Transport: NewRetryTransport(http.DefaultTransport, RetryOptions{
+		MaxRetries:     3,
+		InitialBackoff: time.Minute,
+		MaxBackoff:     time.Minute,
+	})}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := getBody(ctx, client, ts.URL)
+
+	if err == nil {
+		t.Fatal("expected an error when the context is cancelled")
+	}
+	if ctx.Err() == nil {
+		t.Fatal("request returned before the context was cancelled")
+	}
+}
+
+func TestRetryTransport_retry_after(t *testing.T) {
+	attempts := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, "testing")
+	}))
+	t.Cleanup(ts.Close)
+	client := &http.Client{Transport: NewRetryTransport(http.DefaultTransport, testRetryOptions)}
+	start := time.Now()
+
+	b, err := getBody(context.Background(), client, ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "testing" {
+		t.Fatalf("got %s, want %s", b, "testing")
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("retried after %v, want the Retry-After delay of 1s", elapsed)
+	}
+}
+
+func TestRetryTransport_retry_after_past_deadline(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(ts.Close)
+	client := &http.Client{Transport: NewRetryTransport(http.DefaultTransport, testRetryOptions)}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err := getBody(ctx, client, ts.URL)
+
+	var ce ClientError
+	if !errors.As(err, &ce) || ce.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("got error %v, want the 503 response", err)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("waited for the context to be cancelled")
+	}
+}
+
+func TestRetryTransport_attempt_timeout(t *testing.T) {
+	attempts := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			time.Sleep(200 * time.Millisecond)
+		}
+		fmt.Fprint(w, "testing")
+	}))
+	t.Cleanup(ts.Close)
+	client := NewHTTPClient(50*time.Millisecond, testRetryOptions)
+
+	b, err := getBody(context.Background(), client, ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "testing" {
+		t.Fatalf("got %s, want %s", b, "testing")
+	}
+	if attempts != 2 {
+		t.Fatalf("got %d attempts, want 2", attempts)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2021, time.April, 1, 12, 0, 0, 0, time.UTC)
+	retryTests := []struct {
+		header string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"", 0, false},
+		{"120", 2 * time.Minute, true},
+		{"-1", 0, false},
+		{"Thu, 01 Apr 2021 12:00:30 GMT", 30 * time.Second, true},
+		{"Thu, 01 Apr 2021 11:00:00 GMT", 0, true},
+		{"soon", 0, false},
+	}
+
+	for _, tt := range retryTests {
+		t.Run(tt.header, func(t *testing.T) {
+			d, ok := retryAfter(tt.header, now)
+			if d != tt.want || ok != tt.wantOK {
+				t.Fatalf("retryAfter(%q) got (%v, %v), want (%v, %v)", tt.header, d, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
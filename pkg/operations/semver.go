@@ -0,0 +1,44 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// resolveSemVerTag returns the tag with the highest version that matches the
+// constraint.
+//
+// Tags that are not valid semantic versions are ignored.
+func resolveSemVerTag(ctx context.Context, client Client, repo, constraint string) (string, error) {
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse semver constraint %q: %w", constraint, err)
+	}
+	lister, ok := client.(TagLister)
+	if !ok {
+		return "", fmt.Errorf("unable to resolve semver constraint %q: the client does not support listing tags", constraint)
+	}
+	tags, err := lister.ListTags(ctx, repo)
+	if err != nil {
+		return "", fmt.Errorf("failed to list tags for %q: %w", repo, err)
+	}
+
+	var latest *semver.Version
+	var latestTag string
+	for _, t := range tags {
+		v, err := semver.NewVersion(t)
+		if err != nil {
+			continue
+		}
+		if c.Check(v) && (latest == nil || v.GreaterThan(latest)) {
+			latest = v
+			latestTag = t
+		}
+	}
+	if latest == nil {
+		return "", fmt.Errorf("no tags in %q match semver constraint %q", repo, constraint)
+	}
+	return latestTag, nil
+}
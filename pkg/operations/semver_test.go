@@ -0,0 +1,55 @@
+package operations
+
+import (
+	"context"
+	"testing"
+)
+
+func TestResolveSemVerTag(t *testing.T) {
+	client := newMockClient()
+	client.addTags("test/repo", "v1.1.0", "v1.2.0", "latest", "v1.10.2", "v2.0.0", "1.9.0")
+
+	semverTests := []struct {
+		constraint string
+		want       string
+	}{
+		{">=1.2.0 <2.0.0", "v1.10.2"},
+		{"~1.2", "v1.2.0"},
+		{"1.9.x", "1.9.0"},
+		{">=2.0.0", "v2.0.0"},
+	}
+
+	for _, tt := range semverTests {
+		t.Run(tt.constraint, func(t *testing.T) {
+			tag, err := resolveSemVerTag(context.TODO(), client, "test/repo", tt.constraint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tag != tt.want {
+				t.Fatalf("resolveSemVerTag() got %q, want %q", tag, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveSemVerTag_errors(t *testing.T) {
+	client := newMockClient()
+	client.addTags("test/repo", "v1.1.0", "v1.2.0")
+
+	errorTests := []struct {
+		constraint string
+		wantErr    string
+	}{
+		{">=3.0.0", `no tags in "test/repo" match semver constraint ">=3.0.0"`},
+		{"not-a-version", `failed to parse semver constraint "not-a-version": improper constraint: not-a-version`},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.constraint, func(t *testing.T) {
+			_, err := resolveSemVerTag(context.TODO(), client, "test/repo", tt.constraint)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("resolveSemVerTag() got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
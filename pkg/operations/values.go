@@ -0,0 +1,168 @@
+package operations
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	helmv2beta1 "github.com/fluxcd/helm-controller/api/v2beta1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/bigkevmcd/askja/pkg/profiles"
+)
+
+const (
+	configMapKind = "ConfigMap"
+	secretKind    = "Secret"
+)
+
+// ValuesOptions are the Helm values to set in the HelmReleases for a Profile.
+//
+// Each option applies to all Helm artifacts, unless it's prefixed with the
+// name of an artifact and "=" e.g. nginx-server=nginx.yaml or
+// nginx-server=replicaCount=2, a Set with more than one "=" always has an
+// artifact prefix, so a value with an "=" for all artifacts has an empty
+// prefix e.g. =podAnnotations.owner=team=web.
+//
+// As with helm, values from Set take precedence over values from Files, even
+// if the Files are for a single artifact.
+type ValuesOptions struct {
+	// Files are YAML values files, which are merged in order.
+	Files []string
+	// Set are key=value pairs, these are merged over the values from all the
+	// Files, the key can be a dotted path e.g. service.port=8080.
+	Set []string
+	// ConfigMaps are the names of ConfigMaps with values, optionally with the
+	// key of the values in the ConfigMap e.g. my-values:values.yaml.
+	ConfigMaps []string
+	// Secrets are the names of Secrets with values, optionally with the key of
+	// the values in the Secret.
+	Secrets []string
+}
+
+// Build reads and parses the values options and returns the values keyed by
+// artifact name, with profiles.AllArtifacts for values that apply to all
+// artifacts.
+func (o ValuesOptions) Build() (map[string]*profiles.ReleaseValues, error) {
+	result := map[string]*profiles.ReleaseValues{}
+	valuesFor := func(artifact string) *profiles.ReleaseValues {
+		v, ok := result[artifact]
+		if !ok {
+			v = &profiles.ReleaseValues{}
+			result[artifact] = v
+		}
+		return v
+	}
+
+	for _, f := range o.Files {
+		artifact, filename := splitArtifact(f)
+		values, err := readValuesFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		rv := valuesFor(artifact)
+		rv.Values = profiles.MergeValues(rv.Values, values)
+	}
+	for _, s := range o.Set {
+		values, artifact, err := parseSet(s)
+		if err != nil {
+			return nil, err
+		}
+		rv := valuesFor(artifact)
+		rv.Set = profiles.MergeValues(rv.Set, values)
+	}
+	for _, kind := range []struct {
+		name string
+		refs []string
+	}{{configMapKind, o.ConfigMaps}, {secretKind, o.Secrets}} {
+		for _, r := range kind.refs {
+			artifact, ref, err := parseValuesReference(kind.name, r)
+			if err != nil {
+				return nil, err
+			}
+			rv := valuesFor(artifact)
+			rv.ValuesFrom = append(rv.ValuesFrom, ref)
+		}
+	}
+	return result, nil
+}
+
+func readValuesFile(filename string) (map[string]interface{}, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read values file: %w", err)
+	}
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(b, &values); err != nil {
+		return nil, fmt.Errorf("failed to parse values file %q: %w", filename, err)
+	}
+	return values, nil
+}
+
+// parseSet parses a [artifact=]key=value and returns the value nested in maps
+// for each part of the key.
+func parseSet(s string) (map[string]interface{}, string, error) {
+	kv := strings.SplitN(s, "=", 3)
+	if len(kv) < 2 {
+		return nil, "", fmt.Errorf("invalid value %q, must be key=value", s)
+	}
+	artifact := profiles.AllArtifacts
+	if len(kv) == 3 {
+		artifact, kv = kv[0], kv[1:]
+	}
+	key := kv[0]
+	parts := strings.Split(key, ".")
+	for _, p := range parts {
+		if p == "" {
+			return nil, "", fmt.Errorf("invalid key in value %q", s)
+		}
+	}
+	var value interface{} = parseScalar(kv[1])
+	for i := len(parts) - 1; i >= 0; i-- {
+		value = map[string]interface{}{parts[i]: value}
+	}
+	return value.(map[string]interface{}), artifact, nil
+}
+
+// parseScalar converts booleans, null and integers to the matching types,
+// other values are strings.
+func parseScalar(s string) interface{} {
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i
+	}
+	return s
+}
+
+// parseValuesReference parses a [artifact=]name[:key] reference to a
+// ConfigMap or Secret.
+func parseValuesReference(kind, s string) (string, helmv2beta1.ValuesReference, error) {
+	artifact, ref := splitArtifact(s)
+	parts := strings.SplitN(ref, ":", 2)
+	if parts[0] == "" {
+		return "", helmv2beta1.ValuesReference{}, fmt.Errorf("invalid %s reference %q, must have a name", kind, s)
+	}
+	vr := helmv2beta1.ValuesReference{Kind: kind, Name: parts[0]}
+	if len(parts) == 2 {
+		vr.ValuesKey = parts[1]
+	}
+	return artifact, vr, nil
+}
+
+// splitArtifact splits an artifact= prefix from the value, if there's no
+// prefix the artifact is profiles.AllArtifacts.
+func splitArtifact(s string) (string, string) {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 {
+		return profiles.AllArtifacts, s
+	}
+	return parts[0], parts[1]
+}
@@ -0,0 +1,118 @@
+package operations
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	helmv2beta1 "github.com/fluxcd/helm-controller/api/v2beta1"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bigkevmcd/askja/pkg/profiles"
+	"github.com/bigkevmcd/askja/test"
+)
+
+func TestValuesOptionsBuild(t *testing.T) {
+	dir := test.MakeTempDir(t)
+	writeValuesFile(t, dir, "base.yaml", "replicaCount: 1\nservice:\n  type: ClusterIP\n  port: 80\n")
+	writeValuesFile(t, dir, "prod.yaml", "replicaCount: 3\n")
+	writeValuesFile(t, dir, "nginx.yaml", "service:\n  port: 8080\n")
+
+	opts := ValuesOptions{
+		Files: []string{
+			filepath.Join(dir, "base.yaml"),
+			filepath.Join(dir, "prod.yaml"),
+			"nginx-server=" + filepath.Join(dir, "nginx.yaml"),
+		},
+		Set:        []string{"service.type=NodePort", "debug=true", "=podAnnotations.owner=team=web", "nginx-server=image.tag=1.19", "nginx-server=replicaCount=2"},
+		ConfigMaps: []string{"shared-values", "nginx-server=nginx-values:nginx.yaml"},
+		Secrets:    []string{"secret-values:values.yaml"},
+	}
+	values, err := opts.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]*profiles.ReleaseValues{
+		profiles.AllArtifacts: {
+			Values: map[string]interface{}{
+				"replicaCount": float64(3),
+				"service":      map[string]interface{}{"type": "ClusterIP", "port": float64(80)},
+			},
+			Set: map[string]interface{}{
+				"service":        map[string]interface{}{"type": "NodePort"},
+				"debug":          true,
+				"podAnnotations": map[string]interface{}{"owner": "team=web"},
+			},
+			ValuesFrom: []helmv2beta1.ValuesReference{
+				{Kind: "ConfigMap", Name: "shared-values"},
+				{Kind: "Secret", Name: "secret-values", ValuesKey: "values.yaml"},
+			},
+		},
+		"nginx-server": {
+			Values: map[string]interface{}{
+				"service": map[string]interface{}{"port": float64(8080)},
+			},
+			Set: map[string]interface{}{
+				"image":        map[string]interface{}{"tag": "1.19"},
+				"replicaCount": int64(2),
+			},
+			ValuesFrom: []helmv2beta1.ValuesReference{
+				{Kind: "ConfigMap", Name: "nginx-values", ValuesKey: "nginx.yaml"},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, values); diff != "" {
+		t.Fatalf("failed to build values:\n%s", diff)
+	}
+}
+
+func TestValuesOptionsBuild_errors(t *testing.T) {
+	errorTests := []struct {
+		name    string
+		opts    ValuesOptions
+		wantErr string
+	}{
+		{
+			name:    "set without a value",
+			opts:    ValuesOptions{Set: []string{"replicaCount"}},
+			wantErr: `invalid value "replicaCount", must be key=value`,
+		},
+		{
+			name:    "targeted set with an empty key",
+			opts:    ValuesOptions{Set: []string{"nginx-server==2"}},
+			wantErr: `invalid key in value "nginx-server==2"`,
+		},
+		{
+			name:    "set with an empty key",
+			opts:    ValuesOptions{Set: []string{"service..port=80"}},
+			wantErr: `invalid key in value "service..port=80"`,
+		},
+		{
+			name:    "configmap without a name",
+			opts:    ValuesOptions{ConfigMaps: []string{"nginx-server=:values.yaml"}},
+			wantErr: `invalid ConfigMap reference "nginx-server=:values.yaml", must have a name`,
+		},
+		{
+			name:    "missing values file",
+			opts:    ValuesOptions{Files: []string{"testdata/missing.yaml"}},
+			wantErr: "failed to read values file: open testdata/missing.yaml: no such file or directory",
+		},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.opts.Build()
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func writeValuesFile(t *testing.T, dir, name, body string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
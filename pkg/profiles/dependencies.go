@@ -0,0 +1,82 @@
+package profiles
+
+import (
+	"fmt"
+
+	"github.com/fluxcd/pkg/runtime/dependency"
+)
+
+// makeDependsOn returns the references to the HelmReleases or Kustomizations
+// for the artifacts that the artifact depends on.
+//
+// Flux only orders HelmReleases after other HelmReleases, and Kustomizations
+// after other Kustomizations, so an artifact can only depend on artifacts
+// that are installed in the same way.
+func makeDependsOn(prefix string, a Artifact, artifacts map[string]Artifact) ([]dependency.CrossNamespaceDependencyReference, error) {
+	var refs []dependency.CrossNamespaceDependencyReference
+	for _, name := range a.DependsOn {
+		dep, ok := artifacts[name]
+		if !ok {
+			return nil, fmt.Errorf("artifact %q depends on unknown artifact %q", a.Name, name)
+		}
+		if (a.Kustomize != nil) != (dep.Kustomize != nil) {
+			return nil, fmt.Errorf("artifact %q can't depend on artifact %q, HelmReleases can only depend on HelmReleases and Kustomizations on Kustomizations", a.Name, name)
+		}
+		refName := makeHelmReleaseName(prefix, dep)
+		if dep.Kustomize != nil {
+			refName = makeKustomizationName(prefix, dep)
+		}
+		refs = append(refs, dependency.CrossNamespaceDependencyReference{Name: refName})
+	}
+	return refs, nil
+}
+
+// dependencyCycle returns the path of the first cycle in the dependencies
+// between the artifacts e.g. [a b a], or nil if there are no cycles.
+//
+// Dependencies on unknown artifacts are ignored.
+func dependencyCycle(artifacts []Artifact) []string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	dependsOn := map[string][]string{}
+	for _, a := range artifacts {
+		dependsOn[a.Name] = a.DependsOn
+	}
+	state := map[string]int{}
+	path := []string{}
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			for i, n := range path {
+				if n == name {
+					return append(append([]string{}, path[i:]...), name)
+				}
+			}
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, d := range dependsOn[name] {
+			if _, ok := dependsOn[d]; !ok {
+				continue
+			}
+			if cycle := visit(d); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		return nil
+	}
+	for _, a := range artifacts {
+		if cycle := visit(a.Name); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
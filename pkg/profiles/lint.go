@@ -0,0 +1,365 @@
+package profiles
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/util/validation"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+const profileKind = "Profile"
+
+var (
+	yamlLineRE      = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+	parameterNameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// Problem is a problem found in a profile.yaml.
+type Problem struct {
+	// Line is the line in the profile.yaml with the problem, this is 0 if the
+	// line is not known.
+	Line int
+	// Field is the path to the field with the problem e.g.
+	// spec.artifacts[0].name.
+	Field   string
+	Message string
+}
+
+func (p Problem) String() string {
+	s := p.Message
+	if p.Field != "" {
+		s = p.Field + ": " + s
+	}
+	if p.Line > 0 {
+		s = "line " + strconv.Itoa(p.Line) + ": " + s
+	}
+	return s
+}
+
+// LintError is returned when parsing a profile.yaml that has problems.
+type LintError struct {
+	Problems []Problem
+}
+
+func (e *LintError) Error() string {
+	s := []string{}
+	for _, p := range e.Problems {
+		s = append(s, p.String())
+	}
+	return "invalid profile:\n  " + strings.Join(s, "\n  ")
+}
+
+// Lint parses the profile.yaml and returns the Profile and every problem that
+// was found, unknown fields are problems, as are invalid names, artifacts that
+// don't have exactly one kind, and invalid versions.
+//
+// The profile.yaml is decoded with the version of the Profile API in its
+// apiVersion, and converted to a Profile.
+//
+// If the YAML can't be parsed, or the apiVersion is not supported, the Profile
+// is nil.
+func Lint(b []byte) (*Profile, []Problem) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, []Problem{yamlProblem(err)}
+	}
+	if len(doc.Content) == 0 {
+		return nil, []Problem{{Message: "profile is empty"}}
+	}
+	root := doc.Content[0]
+	lines := map[string]int{}
+	indexLines(root, "", lines)
+
+	apiVersion := scalarValue(root, "apiVersion")
+	if apiVersion == "" {
+		return nil, []Problem{{Field: "apiVersion", Message: "is required"}}
+	}
+	version, ok := profileVersions[apiVersion]
+	if !ok {
+		return nil, []Problem{{Line: lines["apiVersion"], Field: "apiVersion", Message: unsupportedVersionError(apiVersion).Error()}}
+	}
+
+	problems := []Problem{}
+	versioned := version.newVersioned()
+	checkFields(root, reflect.TypeOf(versioned), "", func(n *yaml.Node, field, msg string) {
+		problems = append(problems, Problem{Line: n.Line, Field: field, Message: msg})
+	})
+	if err := sigsyaml.Unmarshal(b, versioned); err != nil {
+		return nil, append(problems, Problem{Message: err.Error()})
+	}
+	p := version.toInternal(versioned)
+
+	for _, v := range append(version.validate(versioned), validateProfile(p, version.artifactKinds)...) {
+		v.Line = lineFor(lines, v.Field)
+		problems = append(problems, v)
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Line < problems[j].Line
+	})
+	return p, problems
+}
+
+// artifactKindCount returns the number of kinds that the artifact has, a
+// valid artifact has exactly one of a path, a helm chart, a kustomize path or a
+// profile.
+func artifactKindCount(a Artifact) int {
+	kinds := 0
+	for _, ok := range []bool{a.Path != "", a.Chart != nil, a.Kustomize != nil, a.Profile != nil} {
+		if ok {
+			kinds++
+		}
+	}
+	return kinds
+}
+
+// validateProfile checks the semantics of the Profile, the problems have no
+// lines.
+func validateProfile(p *Profile, artifactKinds string) []Problem {
+	problems := []Problem{}
+	add := func(field, format string, a ...interface{}) {
+		problems = append(problems, Problem{Field: field, Message: fmt.Sprintf(format, a...)})
+	}
+
+	switch p.Kind {
+	case "":
+		add("kind", "is required")
+	case profileKind:
+	default:
+		add("kind", "unsupported kind %q, must be %s", p.Kind, profileKind)
+	}
+	checkName(add, "metadata.name", p.Name)
+	if p.Spec.Version != "" {
+		if _, err := semver.NewVersion(p.Spec.Version); err != nil {
+			add("spec.version", "invalid version %q, must be a semantic version", p.Spec.Version)
+		}
+	}
+
+	if len(p.Spec.Artifacts) == 0 {
+		add("spec.artifacts", "at least one artifact is required")
+	}
+	artifacts := map[string]bool{}
+	for i, a := range p.Spec.Artifacts {
+		field := fmt.Sprintf("spec.artifacts[%d]", i)
+		checkName(add, field+".name", a.Name)
+		if a.Name != "" && artifacts[a.Name] {
+			add(field+".name", "duplicate artifact name %q", a.Name)
+		}
+		artifacts[a.Name] = true
+
+		if a.Chart != nil {
+			if a.Chart.Chart == "" {
+				add(field+".helm.chart", "is required")
+			}
+			if a.Chart.Repository == "" {
+				add(field+".helm.repository", "is required")
+			}
+		}
+		if a.Kustomize != nil {
+			if a.Kustomize.Path == "" {
+				add(field+".kustomize.path", "is required")
+			}
+		}
+		if a.Profile != nil {
+			if a.Profile.URL == "" {
+				add(field+".profile.url", "is required")
+			}
+			if a.Values != nil || a.ValuesSchema != nil {
+				add(field, "profile artifacts can't have values, use params to configure the profile")
+			}
+		}
+		if a.ValuesSchema != nil {
+			keywords, err := unsupportedKeywords(field+".valuesSchema", a.ValuesSchema)
+			if err != nil {
+				add(field+".valuesSchema", "invalid schema: %s", err)
+			}
+			for _, k := range keywords {
+				add(k, "unsupported schema keyword %q", k[strings.LastIndex(k, ".")+1:])
+			}
+		}
+		switch kinds := artifactKindCount(a); {
+		case kinds == 0:
+			add(field, "must have one of %s", artifactKinds)
+		case kinds > 1:
+			add(field, "must have only one of %s", artifactKinds)
+		}
+	}
+	for i, a := range p.Spec.Artifacts {
+		for j, d := range a.DependsOn {
+			if !artifacts[d] {
+				add(fmt.Sprintf("spec.artifacts[%d].dependsOn[%d]", i, j), "unknown artifact %q", d)
+			}
+		}
+	}
+	if cycle := dependencyCycle(p.Spec.Artifacts); cycle != nil {
+		add("spec.artifacts", "dependency cycle detected: %s", strings.Join(cycle, " -> "))
+	}
+
+	params := map[string]bool{}
+	for i, param := range p.Spec.Parameters {
+		field := fmt.Sprintf("spec.parameters[%d]", i)
+		if !parameterNameRE.MatchString(param.Name) {
+			add(field+".name", "invalid parameter name %q, must start with a letter and contain only letters, digits and underscores", param.Name)
+		}
+		if params[param.Name] {
+			add(field+".name", "duplicate parameter name %q", param.Name)
+		}
+		params[param.Name] = true
+		if _, err := zeroParam(param); err != nil {
+			add(field+".type", "invalid type %q, must be one of string, integer, number or boolean", param.Type)
+		} else if param.Default != nil {
+			if _, err := defaultParam(param); err != nil {
+				add(field+".default", "%s", err)
+			}
+		}
+	}
+	return problems
+}
+
+func checkName(add func(string, string, ...interface{}), field, name string) {
+	if name == "" {
+		add(field, "is required")
+		return
+	}
+	for _, msg := range validation.IsDNS1123Label(name) {
+		add(field, "invalid name %q: %s", name, msg)
+	}
+}
+
+// checkFields reports the keys in the YAML that don't match a field in the
+// type that it's decoded to.
+func checkFields(n *yaml.Node, t reflect.Type, path string, unknown func(*yaml.Node, string, string)) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(unmarshalerType) {
+		return
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		if n.Kind != yaml.MappingNode {
+			return
+		}
+		fields := jsonFields(t)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			field, ok := fields[key.Value]
+			if !ok {
+				unknown(key, joinField(path, key.Value), fmt.Sprintf("unknown field %q", key.Value))
+				continue
+			}
+			checkFields(value, field, joinField(path, key.Value), unknown)
+		}
+	case reflect.Slice:
+		if n.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range n.Content {
+			checkFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), unknown)
+		}
+	case reflect.Map:
+		if n.Kind != yaml.MappingNode || t.Elem().Kind() == reflect.Interface {
+			return
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			checkFields(n.Content[i+1], t.Elem(), joinField(path, n.Content[i].Value), unknown)
+		}
+	}
+}
+
+// jsonFields returns the types of the fields in the struct by their JSON
+// names, including the fields of inlined structs.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		name := strings.Split(tag, ",")[0]
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			for k, v := range jsonFields(f.Type) {
+				fields[k] = v
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// indexLines records the line of every key and sequence item in the YAML by
+// its path.
+func indexLines(n *yaml.Node, path string, lines map[string]int) {
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			child := joinField(path, n.Content[i].Value)
+			lines[child] = n.Content[i].Line
+			indexLines(n.Content[i+1], child, lines)
+		}
+	case yaml.SequenceNode:
+		for i, item := range n.Content {
+			child := fmt.Sprintf("%s[%d]", path, i)
+			lines[child] = item.Line
+			indexLines(item, child, lines)
+		}
+	}
+}
+
+// lineFor returns the line for the field, or for its closest parent if the
+// field is not in the YAML.
+func lineFor(lines map[string]int, field string) int {
+	for field != "" {
+		if l, ok := lines[field]; ok {
+			return l
+		}
+		i := strings.LastIndexAny(field, ".[")
+		if i < 0 {
+			break
+		}
+		field = field[:i]
+	}
+	return 0
+}
+
+// scalarValue returns the value of a key in a mapping node, or an empty
+// string if the key is not found.
+func scalarValue(n *yaml.Node, key string) string {
+	if n.Kind != yaml.MappingNode {
+		return ""
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1].Value
+		}
+	}
+	return ""
+}
+
+func joinField(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func yamlProblem(err error) Problem {
+	if m := yamlLineRE.FindStringSubmatch(err.Error()); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		return Problem{Line: line, Message: m[2]}
+	}
+	return Problem{Message: err.Error()}
+}
@@ -0,0 +1,110 @@
+package profiles
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLint(t *testing.T) {
+	_, problems := Lint(mustRead(t, "testdata/invalid_profile.yaml"))
+
+	want := []Problem{
+		{Line: 4, Field: "metadata.name", Message: `invalid name "Nginx_Profile": a lowercase RFC 1123 label must consist of lower case alphanumeric characters or '-', and must start and end with an alphanumeric character (e.g. 'my-name',  or '123-abc', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?')`},
+		{Line: 7, Field: "spec.version", Message: `invalid version "latest", must be a semantic version`},
+		{Line: 9, Field: "spec.parameters[0].name", Message: `invalid parameter name "replica-count", must start with a letter and contain only letters, digits and underscores`},
+		{Line: 10, Field: "spec.parameters[0].type", Message: `invalid type "int", must be one of string, integer, number or boolean`},
+		{Line: 12, Field: "spec.artifacts[0]", Message: "must have only one of path, helm or kustomize"},
+		{Line: 17, Field: "spec.artifacts[1].name", Message: `duplicate artifact name "nginx-server"`},
+		{Line: 17, Field: "spec.artifacts[1]", Message: "must have one of path, helm or kustomize"},
+		{Line: 18, Field: "spec.artifacts[1].kustomise", Message: `unknown field "kustomise"`},
+		{Line: 21, Field: "spec.artifacts[2].helm.repository", Message: "is required"},
+		{Line: 23, Field: "spec.artifacts[2].helm.repo", Message: `unknown field "repo"`},
+	}
+	if diff := cmp.Diff(want, problems); diff != "" {
+		t.Fatalf("failed to lint profile:\n%s", diff)
+	}
+}
+
+func TestParseBytes_errors(t *testing.T) {
+	errorTests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{
+			name:    "invalid yaml",
+			yaml:    "apiVersion: profiles.fluxcd.io/v1alpha1\nkind: [Profile\n",
+			wantErr: "invalid profile:\n  line 1: did not find expected ',' or ']'",
+		},
+		{
+			name:    "empty",
+			yaml:    "",
+			wantErr: "invalid profile:\n  profile is empty",
+		},
+		{
+			name:    "unknown field",
+			yaml:    "apiVersion: profiles.fluxcd.io/v1alpha1\nkind: Profile\nmetadata:\n  name: test\nspec:\n  artifact:\n    - name: test\n",
+			wantErr: "invalid profile:\n  line 5: spec.artifacts: at least one artifact is required\n  line 6: spec.artifact: unknown field \"artifact\"",
+		},
+		{
+			name:    "missing apiVersion",
+			yaml:    "kind: Profile\nspec:\n  artifacts:\n    - name: test\n      path: test\n",
+			wantErr: "invalid profile:\n  apiVersion: is required",
+		},
+		{
+			name:    "unsupported apiVersion",
+			yaml:    "apiVersion: profiles.fluxcd.io/v1beta1\nkind: Profile\n",
+			wantErr: "invalid profile:\n  line 1: apiVersion: unsupported apiVersion \"profiles.fluxcd.io/v1beta1\", must be one of profiles.fluxcd.io/v1alpha1, profiles.fluxcd.io/v1alpha2",
+		},
+		{
+			name:    "missing fields",
+			yaml:    "apiVersion: profiles.fluxcd.io/v1alpha1\nspec:\n  artifacts:\n    - name: test\n      path: test\n",
+			wantErr: "invalid profile:\n  kind: is required\n  metadata.name: is required",
+		},
+		{
+			name:    "v1alpha1 fields in a v1alpha2 profile",
+			yaml:    "apiVersion: profiles.fluxcd.io/v1alpha2\nkind: Profile\nmetadata:\n  name: test\nspec:\n  artifacts:\n    - name: test\n      path: test\n",
+			wantErr: "invalid profile:\n  line 7: spec.artifacts[0]: must have one of helm.path, helm.chart, kustomize or profile\n  line 8: spec.artifacts[0].path: unknown field \"path\"",
+		},
+		{
+			name:    "unknown dependency",
+			yaml:    "apiVersion: profiles.fluxcd.io/v1alpha2\nkind: Profile\nmetadata:\n  name: test\nspec:\n  artifacts:\n    - name: test\n      helm:\n        path: test\n      dependsOn:\n        - missing\n",
+			wantErr: "invalid profile:\n  line 11: spec.artifacts[0].dependsOn[0]: unknown artifact \"missing\"",
+		},
+		{
+			name:    "dependsOn in a v1alpha1 profile",
+			yaml:    "apiVersion: profiles.fluxcd.io/v1alpha1\nkind: Profile\nmetadata:\n  name: test\nspec:\n  artifacts:\n    - name: test\n      path: test\n      dependsOn:\n        - other\n",
+			wantErr: "invalid profile:\n  line 9: spec.artifacts[0].dependsOn: unknown field \"dependsOn\"",
+		},
+		{
+			name:    "helm version for a chart in the profile repo",
+			yaml:    "apiVersion: profiles.fluxcd.io/v1alpha2\nkind: Profile\nmetadata:\n  name: test\nspec:\n  artifacts:\n    - name: test\n      helm:\n        path: test\n        version: 1.0.0\n",
+			wantErr: "invalid profile:\n  line 10: spec.artifacts[0].helm.version: version is only supported for charts from a repository, the version of a chart in the profile repo is in its Chart.yaml",
+		},
+		{
+			name:    "default doesn't match the parameter type",
+			yaml:    "apiVersion: profiles.fluxcd.io/v1alpha1\nkind: Profile\nmetadata:\n  name: test\nspec:\n  parameters:\n    - name: replicas\n      type: integer\n      default: three\n    - name: debug\n      type: boolean\n      default: true\n  artifacts:\n    - name: test\n      path: test\n",
+			wantErr: "invalid profile:\n  line 9: spec.parameters[0].default: invalid default three for parameter \"replicas\", must be an integer",
+		},
+		{
+			name:    "unsupported schema keywords",
+			yaml:    "apiVersion: profiles.fluxcd.io/v1alpha1\nkind: Profile\nmetadata:\n  name: test\nspec:\n  artifacts:\n    - name: test\n      path: test\n      valuesSchema:\n        type: object\n        properties:\n          port:\n            anyOf:\n              - type: integer\n              - type: string\n            x-kubernetes-int-or-string: true\n          tags:\n            type: array\n            uniqueItems: true\n",
+			wantErr: "invalid profile:\n  line 13: spec.artifacts[0].valuesSchema.properties.port.anyOf: unsupported schema keyword \"anyOf\"\n  line 16: spec.artifacts[0].valuesSchema.properties.port.x-kubernetes-int-or-string: unsupported schema keyword \"x-kubernetes-int-or-string\"\n  line 19: spec.artifacts[0].valuesSchema.properties.tags.uniqueItems: unsupported schema keyword \"uniqueItems\"",
+		},
+		{
+			name:    "dependency cycle",
+			yaml:    "apiVersion: profiles.fluxcd.io/v1alpha2\nkind: Profile\nmetadata:\n  name: test\nspec:\n  artifacts:\n    - name: first\n      helm:\n        path: first\n      dependsOn: [second]\n    - name: second\n      helm:\n        path: second\n      dependsOn: [first]\n",
+			wantErr: "invalid profile:\n  line 6: spec.artifacts: dependency cycle detected: first -> second -> first",
+		},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseBytes([]byte(tt.yaml))
+			if msg := errorString(err); msg != tt.wantErr {
+				t.Fatalf("ParseBytes() got error %q, want %q", msg, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,14 +1,38 @@
 package profiles
 
-import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+import (
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
 
 // ProfileSpec defines the desired state of Profile
 type ProfileSpec struct {
 	// Description is some text to allow a user to identify what this profile installs.
 	Description string `json:"description,omitempty"`
-	// Artifacts is a list of Profile artifacts
-	// can be one of HelmChart, TODO
+	// Version is the semantic version of the Profile e.g. v0.1.0.
+	Version string `json:"version,omitempty"`
+	// Artifacts is a list of Profile artifacts, each artifact is installed
+	// with its own HelmRelease or Kustomization.
 	Artifacts []Artifact `json:"artifacts,omitempty"`
+	// Parameters are the parameters that can be provided when the Profile is
+	// installed, these can be referenced in the artifacts' values, chart
+	// versions and namespaces e.g. {{ .params.replicas }}.
+	Parameters []Parameter `json:"parameters,omitempty"`
+}
+
+// Parameter is a named value that is provided when the Profile is installed.
+type Parameter struct {
+	// Name is the name used to reference the parameter.
+	Name string `json:"name"`
+	// Type is the type of the parameter, one of string, integer, number or
+	// boolean, the default is string.
+	Type string `json:"type,omitempty"`
+	// Description describes the parameter for users installing the Profile.
+	Description string `json:"description,omitempty"`
+	// Default is the value used if the parameter is not provided.
+	Default interface{} `json:"default,omitempty"`
+	// Required parameters must be provided when the Profile is installed.
+	Required bool `json:"required,omitempty"`
 }
 
 type Artifact struct {
@@ -16,9 +40,67 @@ type Artifact struct {
 	Name string `json:"name,omitempty"`
 	// Path is the local path to the Artifact in the Profile repo
 	Path string `json:"path,omitempty"`
+	// Namespace is the namespace that the artifact is deployed to.
+	Namespace string `json:"namespace,omitempty"`
 
 	// Chart is a spec for creating a HelmRelease/HelmRepository combination
 	Chart *HelmChartSpec `json:"helm,omitempty"`
+
+	// Kustomize is a spec for creating a Kustomization from a path in the
+	// Profile repo.
+	Kustomize *KustomizeSpec `json:"kustomize,omitempty"`
+
+	// Values are the default Helm values for the artifact, values provided
+	// when the Profile is installed are merged over these.
+	Values map[string]interface{} `json:"values,omitempty"`
+
+	// ValuesSchema is an optional JSON schema that the merged values for the
+	// artifact must match.
+	ValuesSchema *apiextensionsv1.JSONSchemaProps `json:"valuesSchema,omitempty"`
+
+	// Profile is another Profile that is installed as part of this Profile.
+	Profile *ProfileRef `json:"profile,omitempty"`
+
+	// DependsOn is the names of the artifacts in the Profile that must be
+	// ready before this artifact is installed.
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+// ProfileRef references a Profile in another Profile repo.
+type ProfileRef struct {
+	// URL is the URL of the Profile repo.
+	URL    string `json:"url"`
+	Branch string `json:"branch,omitempty"`
+	Tag    string `json:"tag,omitempty"`
+	Commit string `json:"commit,omitempty"`
+	// Version is a semver range that is resolved against the tags in the
+	// Profile repo.
+	Version string `json:"version,omitempty"`
+	// Params are the values for the parameters declared in the Profile.
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// Options returns the options for fetching and installing the referenced
+// Profile, if no ref is provided, the main branch is used.
+func (r *ProfileRef) Options() *ProfileOptions {
+	opts := &ProfileOptions{
+		ProfileURL: r.URL,
+		Branch:     r.Branch,
+		Tag:        r.Tag,
+		Commit:     r.Commit,
+		SemVer:     r.Version,
+	}
+	if opts.Branch == "" && opts.Tag == "" && opts.Commit == "" && opts.SemVer == "" {
+		opts.Branch = defaultBranch
+	}
+	return opts
+}
+
+// KustomizeSpec allows the installation of a directory of manifests with a
+// kustomization.yaml from the Profile repo.
+type KustomizeSpec struct {
+	// Path is the local path to the directory in the Profile repo.
+	Path string `json:"path"`
 }
 
 // HelmChartSpec allows the installation of a HelmChart from a Helm chart
@@ -29,7 +111,9 @@ type HelmChartSpec struct {
 	Version    string `json:"version"`
 }
 
-// Profile is the Schema for the profiles API
+// Profile is the internal representation of a Profile, profile.yaml files are
+// decoded with the type for their apiVersion e.g. v1alpha1.Profile and
+// converted to a Profile, use Marshal to write a Profile in its apiVersion.
 type Profile struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -0,0 +1,92 @@
+package profiles
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	// DefaultNamePrefix is the prefix for the names of the generated resources
+	// if no prefix is provided in the ProfileOptions.
+	DefaultNamePrefix = "subscription"
+
+	maxNameLength  = 63
+	nameHashLength = 8
+)
+
+var invalidNameCharsRE = regexp.MustCompile(`[^a-z0-9]+`)
+
+// makeName joins the parts of a resource name, and sanitises the name so that
+// it's a valid Kubernetes resource name.
+//
+// Names longer than 63 characters are truncated, with a hash of the full name
+// as a suffix, so that truncated names are stable and don't collide.
+func makeName(parts ...string) string {
+	name := sanitizeName(strings.Join(parts, "-"))
+	if len(name) <= maxNameLength {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	truncated := strings.TrimRight(name[:maxNameLength-nameHashLength-1], "-.")
+	return truncated + "-" + hex.EncodeToString(sum[:])[:nameHashLength]
+}
+
+// sanitizeName lowercases the name and replaces the characters that are not
+// valid in a DNS-1123 subdomain with "-", dots are kept so that versions e.g.
+// v0.1.0 are readable, but the segments between them are DNS-1123 labels.
+func sanitizeName(name string) string {
+	labels := []string{}
+	for _, l := range strings.Split(strings.ToLower(name), ".") {
+		l = strings.Trim(invalidNameCharsRE.ReplaceAllString(l, "-"), "-")
+		if l != "" {
+			labels = append(labels, l)
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+// semVerNameRef returns the name for a semver range e.g. "^1.2" is
+// semver-1.2-<hash>, the hash of the range is included because ranges that
+// differ only by their operators e.g. ^1 and ~1 are sanitised to the same name.
+func semVerNameRef(r string) string {
+	sum := sha256.Sum256([]byte(r))
+	return join("semver", sanitizeName(r), hex.EncodeToString(sum[:])[:nameHashLength])
+}
+
+func makeKustomizationName(prefix string, a Artifact) string {
+	return makeName(prefix, "kustomization", a.Name)
+}
+
+func makeHelmReleaseName(prefix string, a Artifact) string {
+	return makeName(prefix, "helm-release", a.Name)
+}
+
+// The GitRepository name is derived from the name of the Profile repo and the
+// ref, so that artifacts from the same repo and ref share the same name.
+func makeGitRepoName(prefix string, opts *ProfileOptions) string {
+	repoParts := strings.Split(strings.TrimSuffix(opts.ProfileURL, "/"), "/")
+	repoName := strings.TrimSuffix(repoParts[len(repoParts)-1], ".git")
+	return makeName(prefix, repoName, opts.nameRef())
+}
+
+// The HelmRepository name is derived from the host and path of the repository
+// URL, so that artifacts using the same repository share the same name.
+func makeHelmRepoName(prefix, repoURL string) (string, error) {
+	parsed, err := url.Parse(repoURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse repository URL %q: %w", repoURL, err)
+	}
+	if parsed.Host == "" {
+		return "", fmt.Errorf("repository URL %q has no host", repoURL)
+	}
+	name := strings.NewReplacer(".", "-", "/", "-", ":", "-").Replace(parsed.Host + parsed.Path)
+	return makeName(prefix, "helm-repository", name), nil
+}
+
+func join(s ...string) string {
+	return strings.Join(s, "-")
+}
@@ -0,0 +1,52 @@
+package profiles
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMakeName(t *testing.T) {
+	longName := strings.Repeat("a", 70)
+	nameTests := []struct {
+		name  string
+		parts []string
+		want  string
+	}{
+		{"joined", []string{"subscription", "nginx-profile", "main"}, "subscription-nginx-profile-main"},
+		{"branch with a slash", []string{"subscription", "nginx-profile", "feature/new-ui"}, "subscription-nginx-profile-feature-new-ui"},
+		{"uppercase", []string{"Team_A", "NGINX"}, "team-a-nginx"},
+		{"dots are kept", []string{"subscription", "nginx-profile", "v0.1.0"}, "subscription-nginx-profile-v0.1.0"},
+		{"invalid dots", []string{"subscription", ".nginx..", "-v1"}, "subscription.nginx.v1"},
+		{"leading and trailing separators", []string{"-nginx", "main/"}, "nginx-main"},
+		{"truncated", []string{"subscription", longName}, "subscription-" + strings.Repeat("a", 41) + "-" + "038a7d21"},
+	}
+
+	for _, tt := range nameTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := makeName(tt.parts...); got != tt.want {
+				t.Fatalf("makeName() got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMakeName_truncated_names_are_unique(t *testing.T) {
+	first := makeName("subscription", strings.Repeat("a", 70), "first")
+	second := makeName("subscription", strings.Repeat("a", 70), "second")
+
+	if len(first) != maxNameLength || len(second) != maxNameLength {
+		t.Fatalf("got names with lengths %d and %d, want %d", len(first), len(second), maxNameLength)
+	}
+	if first == second {
+		t.Fatalf("truncated names collide: %q", first)
+	}
+}
+
+func TestSemVerNameRef(t *testing.T) {
+	if got, want := semVerNameRef(">=1.2.0 <2.0.0"), "semver-1.2.0-2.0.0-4f9d93e4"; got != want {
+		t.Fatalf("semVerNameRef() got %q, want %q", got, want)
+	}
+	if caret, tilde := semVerNameRef("^1"), semVerNameRef("~1"); caret == tilde {
+		t.Fatalf("names for different ranges collide: %q", caret)
+	}
+}
@@ -0,0 +1,212 @@
+package profiles
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// MaxProfileDepth is the maximum depth of nested Profiles that are resolved
+// when installing a Profile.
+const MaxProfileDepth = 5
+
+// ResolvedProfile is a Profile with its nested Profiles resolved by
+// ResolveProfiles, the artifacts in the Profile are the resolved artifacts,
+// and their parameters are rendered.
+type ResolvedProfile struct {
+	*Profile
+	// sources are the Profile repos of the artifacts from nested Profiles,
+	// keyed by the resolved artifact name.
+	sources map[string]*ProfileOptions
+}
+
+// MakeArtifacts creates and returns the resources to deploy the resolved
+// artifacts in the same way as MakeArtifacts, the artifacts from nested
+// Profiles are sourced from the nested Profile's repo.
+func (r *ResolvedProfile) MakeArtifacts(opts *ProfileOptions) ([]runtime.Object, error) {
+	p := *r.Profile
+	p.Spec.Artifacts = append([]Artifact{}, r.Spec.Artifacts...)
+	return makeArtifacts(&p, r.sources, opts)
+}
+
+// resolvedArtifact is an artifact from a Profile or one of its nested
+// Profiles, and the Profile repo that it comes from, if the source is nil, the
+// artifact comes from the Profile repo that is being installed.
+type resolvedArtifact struct {
+	Artifact
+	source *ProfileOptions
+}
+
+// ProfileFetcher fetches and parses the Profile referenced by a profile
+// artifact.
+type ProfileFetcher func(ctx context.Context, ref *ProfileRef) (*Profile, error)
+
+// ResolveProfiles returns a copy of the Profile with the profile artifacts
+// replaced by the artifacts of the Profiles that they reference, recursively,
+// the resources for the ResolvedProfile are made with its MakeArtifacts.
+//
+// The artifacts from a nested Profile are prefixed with the name of the
+// profile artifact, and are sourced from the nested Profile's repo, each
+// distinct repo and ref is only fetched once.
+//
+// The Profile is rendered with the Params in the options, and each nested
+// Profile with the params of its profile artifact, so the artifacts are
+// rendered when they're returned.
+func ResolveProfiles(ctx context.Context, p *Profile, opts *ProfileOptions, fetch ProfileFetcher) (*ResolvedProfile, error) {
+	rendered, err := renderProfile(p, opts.Params)
+	if err != nil {
+		return nil, err
+	}
+	r := &profileResolver{fetch: fetch, fetched: map[string]*Profile{}}
+	artifacts, err := r.resolve(ctx, rendered, nil, []string{profileKey(opts)}, "")
+	if err != nil {
+		return nil, err
+	}
+	resolved := *rendered
+	resolved.Spec.Artifacts = []Artifact{}
+	sources := map[string]*ProfileOptions{}
+	for _, a := range artifacts {
+		if _, ok := sources[a.Name]; ok {
+			return nil, fmt.Errorf("duplicate artifact name %q in the resolved profile", a.Name)
+		}
+		sources[a.Name] = a.source
+		resolved.Spec.Artifacts = append(resolved.Spec.Artifacts, a.Artifact)
+	}
+	return &ResolvedProfile{Profile: &resolved, sources: sources}, nil
+}
+
+type profileResolver struct {
+	fetch   ProfileFetcher
+	fetched map[string]*Profile
+}
+
+// resolve returns the artifacts for the Profile, chain is the keys of the
+// Profiles that led to this Profile, and is used to detect cycles.
+func (r *profileResolver) resolve(ctx context.Context, p *Profile, src *ProfileOptions, chain []string, prefix string) ([]resolvedArtifact, error) {
+	artifacts := []resolvedArtifact{}
+	// names maps the artifacts in this Profile to the names of the resolved
+	// artifacts, so that dependencies on profile artifacts are dependencies
+	// on the artifacts in the nested Profile, and origins are the artifacts in
+	// this Profile that each resolved artifact comes from.
+	names := map[string][]string{}
+	origins := []Artifact{}
+	for _, a := range p.Spec.Artifacts {
+		name := a.Name
+		if prefix != "" {
+			name = join(prefix, a.Name)
+		}
+		if a.Profile == nil {
+			names[a.Name] = []string{name}
+			origins = append(origins, a)
+			a.Name = name
+			a.DependsOn = nil
+			artifacts = append(artifacts, resolvedArtifact{Artifact: a, source: src})
+			continue
+		}
+		if len(chain) > MaxProfileDepth {
+			return nil, fmt.Errorf("artifact %q exceeds the maximum nested profile depth of %d", name, MaxProfileDepth)
+		}
+		nestedOpts := a.Profile.Options()
+		key := profileKey(nestedOpts)
+		for _, k := range chain {
+			if k == key {
+				return nil, fmt.Errorf("profile cycle detected: %s", strings.Join(append(chain, key), " -> "))
+			}
+		}
+		nested, err := r.fetchProfile(ctx, a.Profile, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch the profile for artifact %q: %w", name, err)
+		}
+		rendered, err := renderProfile(nested, a.Profile.Params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render the profile for artifact %q: %w", name, err)
+		}
+		nestedChain := append(append([]string{}, chain...), key)
+		children, err := r.resolve(ctx, rendered, nestedOpts, nestedChain, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range children {
+			names[a.Name] = append(names[a.Name], c.Name)
+			origins = append(origins, a)
+			artifacts = append(artifacts, c)
+		}
+	}
+	if err := resolveDependsOn(p, prefix, artifacts, origins, names); err != nil {
+		return nil, err
+	}
+	return artifacts, nil
+}
+
+// resolveDependsOn adds the dependencies of the artifacts in the Profile to
+// the resolved artifacts that they come from.
+//
+// Flux only orders HelmReleases after HelmReleases and Kustomizations after
+// Kustomizations, so when either side of a dependency is a profile artifact,
+// only the resolved artifacts of the same kind depend on each other, and a
+// dependency with no artifacts of the same kind is an error.
+func resolveDependsOn(p *Profile, prefix string, artifacts []resolvedArtifact, origins []Artifact, names map[string][]string) error {
+	kustomize := map[string]bool{}
+	for _, a := range artifacts {
+		kustomize[a.Name] = a.Kustomize != nil
+	}
+	profileArtifacts := map[string]bool{}
+	for _, a := range p.Spec.Artifacts {
+		profileArtifacts[a.Name] = a.Profile != nil
+	}
+	resolved := map[string]bool{}
+	for i, origin := range origins {
+		for _, d := range origin.DependsOn {
+			deps, ok := names[d]
+			if !ok {
+				deps = []string{d}
+			}
+			sameKind := origin.Profile != nil || profileArtifacts[d]
+			for _, dep := range deps {
+				if sameKind && kustomize[dep] != (artifacts[i].Kustomize != nil) {
+					continue
+				}
+				resolved[origin.Name+"/"+d] = true
+				artifacts[i].DependsOn = append(artifacts[i].DependsOn, dep)
+			}
+		}
+	}
+	for _, a := range p.Spec.Artifacts {
+		for _, d := range a.DependsOn {
+			if resolved[a.Name+"/"+d] {
+				continue
+			}
+			name := a.Name
+			if prefix != "" {
+				name = join(prefix, a.Name)
+			}
+			return fmt.Errorf("artifact %q can't depend on artifact %q, there are no artifacts of the same kind to order, HelmReleases can only depend on HelmReleases and Kustomizations on Kustomizations", name, d)
+		}
+	}
+	return nil
+}
+
+func (r *profileResolver) fetchProfile(ctx context.Context, ref *ProfileRef, key string) (*Profile, error) {
+	if p, ok := r.fetched[key]; ok {
+		return p, nil
+	}
+	p, err := r.fetch(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	r.fetched[key] = p
+	return p, nil
+}
+
+// profileKey identifies a Profile repo and ref, URLs that differ only by a
+// trailing slash or .git suffix are the same repo.
+func profileKey(opts *ProfileOptions) string {
+	url := strings.TrimSuffix(strings.TrimSuffix(opts.ProfileURL, "/"), ".git")
+	ref := opts.Ref()
+	if opts.Commit == "" && opts.SemVer != "" {
+		ref = opts.SemVer
+	}
+	return url + "@" + ref
+}
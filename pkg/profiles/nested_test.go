@@ -0,0 +1,303 @@
+package profiles
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const testNestedProfileURL = "https://example.com/testing/logging.git"
+
+func TestResolveProfiles(t *testing.T) {
+	logging := makeTestProfile(
+		Artifact{Name: "fluentd", Path: "fluentd/chart", Namespace: "{{ .params.namespace }}"},
+		Artifact{Name: "elasticsearch", Kustomize: &KustomizeSpec{Path: "elasticsearch"}},
+	)
+	logging.Spec.Parameters = []Parameter{{Name: "namespace", Default: "logging"}}
+	fetcher, fetches := testFetcher(map[string]*Profile{
+		"https://example.com/testing/logging@v0.1.0": logging,
+	})
+	p := makeTestProfile(
+		Artifact{Name: testChartname, Path: testChartPath},
+		Artifact{Name: "logging", Profile: &ProfileRef{URL: testNestedProfileURL, Tag: "v0.1.0", Params: map[string]string{"namespace": "observability"}}},
+		Artifact{Name: "audit", Profile: &ProfileRef{URL: testNestedProfileURL, Tag: "v0.1.0"}},
+	)
+	opts := &ProfileOptions{ProfileURL: testProfileURL, Branch: "main"}
+
+	resolved, err := ResolveProfiles(context.TODO(), p, opts, fetcher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := resolved.MakeArtifacts(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []runtime.Object{
+		testMakeGitRepository("subscription-testing-main", testProfileURL, branch("main")),
+		testMakeGitRepository("subscription-logging-v0.1.0", testNestedProfileURL, tag("v0.1.0")),
+		testMakeHelmRelease("subscription-helm-release-test-chart",
+			gitRepositorySourceRef(testChartPath, "subscription-testing-main")),
+		testMakeHelmRelease("subscription-helm-release-logging-fluentd",
+			gitRepositorySourceRef("fluentd/chart", "subscription-logging-v0.1.0"),
+			targetNamespace("observability")),
+		testMakeKustomization("subscription-kustomization-logging-elasticsearch", "elasticsearch", "subscription-logging-v0.1.0"),
+		testMakeHelmRelease("subscription-helm-release-audit-fluentd",
+			gitRepositorySourceRef("fluentd/chart", "subscription-logging-v0.1.0"),
+			targetNamespace("logging")),
+		testMakeKustomization("subscription-kustomization-audit-elasticsearch", "elasticsearch", "subscription-logging-v0.1.0"),
+	}
+	if diff := cmp.Diff(want, o); diff != "" {
+		t.Fatalf("failed to resolve nested profiles:\n%s", diff)
+	}
+	if *fetches != 1 {
+		t.Fatalf("got %d fetches, want the nested profile to be fetched once", *fetches)
+	}
+}
+
+func TestResolveProfiles_params(t *testing.T) {
+	logging := makeTestProfile(
+		Artifact{Name: "fluentd", Path: "fluentd/chart", Namespace: "{{ .params.namespace }}",
+			Values: map[string]interface{}{"format": "{{ .params.format }}"}},
+	)
+	logging.Spec.Parameters = []Parameter{
+		{Name: "namespace", Required: true},
+		{Name: "format", Default: "{{ .params.time }} {{ .params.message }}"},
+	}
+	fetcher, _ := testFetcher(map[string]*Profile{
+		"https://example.com/testing/logging@v0.2.0": logging,
+	})
+	p := makeTestProfile(
+		Artifact{Name: "logging", Profile: &ProfileRef{
+			URL:    testNestedProfileURL,
+			Tag:    "{{ .params.loggingVersion }}",
+			Params: map[string]string{"namespace": "logging-{{ .params.environment }}"},
+		}},
+	)
+	p.Spec.Parameters = []Parameter{
+		{Name: "environment", Required: true},
+		{Name: "loggingVersion", Default: "v0.2.0"},
+	}
+	opts := &ProfileOptions{ProfileURL: testProfileURL, Branch: "main", Params: map[string]string{"environment": "staging"}}
+
+	resolved, err := ResolveProfiles(context.TODO(), p, opts, fetcher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := resolved.MakeArtifacts(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []runtime.Object{
+		testMakeGitRepository("subscription-logging-v0.2.0", testNestedProfileURL, tag("v0.2.0")),
+		testMakeHelmRelease("subscription-helm-release-logging-fluentd",
+			gitRepositorySourceRef("fluentd/chart", "subscription-logging-v0.2.0"),
+			targetNamespace("logging-staging"),
+			releaseValues(`{"format":"{{ .params.time }} {{ .params.message }}"}`)),
+	}
+	if diff := cmp.Diff(want, o); diff != "" {
+		t.Fatalf("failed to render nested profile parameters:\n%s", diff)
+	}
+}
+
+func TestResolveProfiles_dependsOn(t *testing.T) {
+	fetcher, _ := testFetcher(map[string]*Profile{
+		"https://example.com/testing/logging@main": makeTestProfile(
+			Artifact{Name: "elasticsearch", Path: "elasticsearch/chart"},
+			Artifact{Name: "fluentd", Path: "fluentd/chart", DependsOn: []string{"elasticsearch"}},
+		),
+	})
+	p := makeTestProfile(
+		Artifact{Name: "cert-manager", Path: "cert-manager/chart"},
+		Artifact{Name: "logging", Profile: &ProfileRef{URL: testNestedProfileURL}, DependsOn: []string{"cert-manager"}},
+		Artifact{Name: "dashboard", Path: "dashboard/chart", DependsOn: []string{"logging"}},
+	)
+
+	resolved, err := ResolveProfiles(context.TODO(), p, &ProfileOptions{ProfileURL: testProfileURL, Branch: "main"}, fetcher)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{
+		"cert-manager":          nil,
+		"logging-elasticsearch": {"cert-manager"},
+		"logging-fluentd":       {"logging-elasticsearch", "cert-manager"},
+		"dashboard":             {"logging-elasticsearch", "logging-fluentd"},
+	}
+	got := map[string][]string{}
+	for _, a := range resolved.Spec.Artifacts {
+		got[a.Name] = a.DependsOn
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("failed to resolve dependencies:\n%s", diff)
+	}
+}
+
+func TestResolveProfiles_dependsOn_kinds(t *testing.T) {
+	fetcher, _ := testFetcher(map[string]*Profile{
+		"https://example.com/testing/logging@main": makeTestProfile(
+			Artifact{Name: "fluentd", Path: "fluentd/chart"},
+			Artifact{Name: "policies", Kustomize: &KustomizeSpec{Path: "policies"}},
+		),
+	})
+	p := makeTestProfile(
+		Artifact{Name: "cert-manager", Path: "cert-manager/chart"},
+		Artifact{Name: "crds", Kustomize: &KustomizeSpec{Path: "crds"}},
+		Artifact{Name: "logging", Profile: &ProfileRef{URL: testNestedProfileURL}, DependsOn: []string{"cert-manager", "crds"}},
+		Artifact{Name: "dashboard", Path: "dashboard/chart", DependsOn: []string{"logging"}},
+		Artifact{Name: "alerts", Kustomize: &KustomizeSpec{Path: "alerts"}, DependsOn: []string{"logging"}},
+	)
+	opts := &ProfileOptions{ProfileURL: testProfileURL, Branch: "main"}
+
+	resolved, err := ResolveProfiles(context.TODO(), p, opts, fetcher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := resolved.MakeArtifacts(opts); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{
+		"cert-manager":     nil,
+		"crds":             nil,
+		"logging-fluentd":  {"cert-manager"},
+		"logging-policies": {"crds"},
+		"dashboard":        {"logging-fluentd"},
+		"alerts":           {"logging-policies"},
+	}
+	got := map[string][]string{}
+	for _, a := range resolved.Spec.Artifacts {
+		got[a.Name] = a.DependsOn
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("failed to resolve dependencies:\n%s", diff)
+	}
+}
+
+func TestResolveProfiles_errors(t *testing.T) {
+	nested := func(url string) *Profile {
+		return makeTestProfile(Artifact{Name: "nested", Profile: &ProfileRef{URL: url}})
+	}
+	deep := map[string]*Profile{}
+	for i := 0; i <= MaxProfileDepth; i++ {
+		deep[fmt.Sprintf("https://example.com/testing/%d@main", i)] = nested(fmt.Sprintf("https://example.com/testing/%d.git", i+1))
+	}
+
+	errorTests := []struct {
+		name     string
+		profiles map[string]*Profile
+		wantErr  string
+	}{
+		{
+			name: "cycle",
+			profiles: map[string]*Profile{
+				"https://example.com/testing/0@main":       nested("https://example.com/testing/logging.git"),
+				"https://example.com/testing/logging@main": nested(testProfileURL),
+			},
+			wantErr: "profile cycle detected: https://example.com/testing/testing@main -> https://example.com/testing/0@main -> https://example.com/testing/logging@main -> https://example.com/testing/testing@main",
+		},
+		{
+			name:     "too deep",
+			profiles: deep,
+			wantErr:  `artifact "nested-nested-nested-nested-nested-nested" exceeds the maximum nested profile depth of 5`,
+		},
+		{
+			name:     "fetch failure",
+			profiles: map[string]*Profile{},
+			wantErr:  `failed to fetch the profile for artifact "nested": profile not found`,
+		},
+		{
+			name: "missing required parameter",
+			profiles: map[string]*Profile{
+				"https://example.com/testing/0@main": {
+					Spec: ProfileSpec{
+						Artifacts:  []Artifact{{Name: "fluentd", Path: "fluentd/chart"}},
+						Parameters: []Parameter{{Name: "namespace", Required: true}},
+					},
+				},
+			},
+			wantErr: `failed to render the profile for artifact "nested": missing required parameters: namespace`,
+		},
+		{
+			name: "dependency without artifacts of the same kind",
+			profiles: map[string]*Profile{
+				"https://example.com/testing/0@main": makeTestProfile(
+					Artifact{Name: "policies", Kustomize: &KustomizeSpec{Path: "policies"}},
+					Artifact{Name: "fluentd", Path: "fluentd/chart", DependsOn: []string{"alerts"}},
+					Artifact{Name: "alerts", Profile: &ProfileRef{URL: "https://example.com/testing/1.git"}},
+				),
+				"https://example.com/testing/1@main": makeTestProfile(
+					Artifact{Name: "rules", Kustomize: &KustomizeSpec{Path: "rules"}},
+				),
+			},
+			wantErr: `artifact "nested-fluentd" can't depend on artifact "alerts", there are no artifacts of the same kind to order, HelmReleases can only depend on HelmReleases and Kustomizations on Kustomizations`,
+		},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetcher, _ := testFetcher(tt.profiles)
+			_, err := ResolveProfiles(context.TODO(), nested("https://example.com/testing/0.git"),
+				&ProfileOptions{ProfileURL: testProfileURL, Branch: "main"}, fetcher)
+			if msg := errorString(err); msg != tt.wantErr {
+				t.Fatalf("ResolveProfiles() got error %q, want %q", msg, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolveProfiles_duplicate_names(t *testing.T) {
+	fetcher, _ := testFetcher(map[string]*Profile{
+		"https://example.com/testing/logging@main": makeTestProfile(Artifact{Name: "fluentd", Path: "fluentd/chart"}),
+	})
+	p := makeTestProfile(
+		Artifact{Name: "logging-fluentd", Path: "fluentd/chart"},
+		Artifact{Name: "logging", Profile: &ProfileRef{URL: testNestedProfileURL}},
+	)
+
+	_, err := ResolveProfiles(context.TODO(), p, &ProfileOptions{ProfileURL: testProfileURL, Branch: "main"}, fetcher)
+	want := `duplicate artifact name "logging-fluentd" in the resolved profile`
+	if msg := errorString(err); msg != want {
+		t.Fatalf("ResolveProfiles() got error %q, want %q", msg, want)
+	}
+}
+
+func TestResolvedProfile_MakeArtifacts_git_repository_name_collision(t *testing.T) {
+	fetcher, _ := testFetcher(map[string]*Profile{
+		"https://example.com/testing/logging@feature/x": makeTestProfile(Artifact{Name: "fluentd", Path: "fluentd/chart"}),
+		"https://example.com/testing/logging@feature-x": makeTestProfile(Artifact{Name: "fluentd", Path: "fluentd/chart"}),
+	})
+	p := makeTestProfile(
+		Artifact{Name: "first", Profile: &ProfileRef{URL: testNestedProfileURL, Branch: "feature/x"}},
+		Artifact{Name: "second", Profile: &ProfileRef{URL: testNestedProfileURL, Branch: "feature-x"}},
+	)
+	opts := &ProfileOptions{ProfileURL: testProfileURL, Branch: "main"}
+	resolved, err := ResolveProfiles(context.TODO(), p, opts, fetcher)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = resolved.MakeArtifacts(opts)
+	want := `GitRepository name "subscription-logging-feature-x" is used for both https://example.com/testing/logging@feature/x and https://example.com/testing/logging@feature-x`
+	if msg := errorString(err); msg != want {
+		t.Fatalf("MakeArtifacts() got error %q, want %q", msg, want)
+	}
+}
+
+// testFetcher returns a ProfileFetcher that returns the profiles keyed by
+// profileKey, and a count of the fetches.
+func testFetcher(profiles map[string]*Profile) (ProfileFetcher, *int) {
+	fetches := 0
+	return func(ctx context.Context, ref *ProfileRef) (*Profile, error) {
+		fetches++
+		p, ok := profiles[profileKey(ref.Options())]
+		if !ok {
+			return nil, fmt.Errorf("profile not found")
+		}
+		return p, nil
+	}, &fetches
+}
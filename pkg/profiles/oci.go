@@ -0,0 +1,68 @@
+package profiles
+
+import (
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	ociScheme = "oci://"
+
+	// ociHelmRepositoryAPIVersion is the first version of the Flux source API
+	// that supports OCI Helm repositories, the v1beta1 API used for the other
+	// sources only supports HTTP chart repositories.
+	ociHelmRepositoryAPIVersion = "source.toolkit.fluxcd.io/v1beta2"
+	ociHelmRepositoryType       = "oci"
+)
+
+// IsOCIRepository returns true if the Helm repository URL refers to an OCI
+// registry rather than an HTTP chart repository.
+func IsOCIRepository(repoURL string) bool {
+	return strings.HasPrefix(repoURL, ociScheme)
+}
+
+// OCIHelmRepository is a HelmRepository that fetches charts from an OCI
+// registry.
+type OCIHelmRepository struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec OCIHelmRepositorySpec `json:"spec,omitempty"`
+}
+
+// OCIHelmRepositorySpec is the subset of the v1beta2 HelmRepositorySpec that
+// is needed to fetch charts from an OCI registry.
+type OCIHelmRepositorySpec struct {
+	URL      string          `json:"url"`
+	Type     string          `json:"type"`
+	Interval metav1.Duration `json:"interval"`
+}
+
+// DeepCopyObject implements runtime.Object.
+func (in *OCIHelmRepository) DeepCopyObject() runtime.Object {
+	out := &OCIHelmRepository{
+		TypeMeta: in.TypeMeta,
+		Spec:     in.Spec,
+	}
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	return out
+}
+
+func createOCIHelmRepository(name string, c *HelmChartSpec) *OCIHelmRepository {
+	return &OCIHelmRepository{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		TypeMeta: metav1.TypeMeta{
+			Kind:       helmRepositoryKind,
+			APIVersion: ociHelmRepositoryAPIVersion,
+		},
+		Spec: OCIHelmRepositorySpec{
+			URL:      c.Repository,
+			Type:     ociHelmRepositoryType,
+			Interval: metav1.Duration{Duration: defaultInterval},
+		},
+	}
+}
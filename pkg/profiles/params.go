@@ -0,0 +1,264 @@
+package profiles
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const (
+	stringParam  = "string"
+	integerParam = "integer"
+	numberParam  = "number"
+	booleanParam = "boolean"
+)
+
+// singleParamRE matches strings that are only a reference to a parameter,
+// these are replaced with the typed value of the parameter, so that e.g. an
+// integer parameter is an integer in the values.
+var singleParamRE = regexp.MustCompile(`^\{\{-?\s*\.params\.(\w+)\s*-?\}\}$`)
+
+// renderProfile returns a copy of the Profile with the parameters rendered in
+// the artifacts' values, chart versions, namespaces and profile references.
+//
+// Strings that contain "{{" are rendered, and references to anything other
+// than the declared .params are errors, values for charts that use Helm's tpl
+// function must escape the template e.g. {{ "{{ .Release.Name }}" }}.
+func renderProfile(p *Profile, provided map[string]string) (*Profile, error) {
+	params, err := resolveParams(p.Spec.Parameters, provided)
+	if err != nil {
+		return nil, err
+	}
+	rendered := *p
+	rendered.Spec.Artifacts = make([]Artifact, len(p.Spec.Artifacts))
+	for i, a := range p.Spec.Artifacts {
+		fail := func(err error) (*Profile, error) {
+			return nil, fmt.Errorf("failed to render parameters for artifact %q: %w", a.Name, err)
+		}
+		if a.Namespace, err = renderString(a.Namespace, params); err != nil {
+			return fail(err)
+		}
+		if a.Chart != nil {
+			chart := *a.Chart
+			if chart.Version, err = renderString(chart.Version, params); err != nil {
+				return fail(err)
+			}
+			a.Chart = &chart
+		}
+		if a.Values != nil {
+			v, err := renderValue(a.Values, params)
+			if err != nil {
+				return fail(err)
+			}
+			a.Values = v.(map[string]interface{})
+		}
+		if a.Profile != nil {
+			ref, err := renderProfileRef(a.Profile, params)
+			if err != nil {
+				return fail(err)
+			}
+			a.Profile = ref
+		}
+		rendered.Spec.Artifacts[i] = a
+	}
+	return &rendered, nil
+}
+
+func renderProfileRef(ref *ProfileRef, params map[string]interface{}) (*ProfileRef, error) {
+	rendered := *ref
+	for _, s := range []*string{&rendered.URL, &rendered.Branch, &rendered.Tag, &rendered.Commit, &rendered.Version} {
+		v, err := renderString(*s, params)
+		if err != nil {
+			return nil, err
+		}
+		*s = v
+	}
+	if ref.Params != nil {
+		rendered.Params = map[string]string{}
+		for k, v := range ref.Params {
+			r, err := renderString(v, params)
+			if err != nil {
+				return nil, err
+			}
+			rendered.Params[k] = r
+		}
+	}
+	return &rendered, nil
+}
+
+// resolveParams returns the values for the declared parameters, from the
+// provided values or the defaults.
+func resolveParams(declared []Parameter, provided map[string]string) (map[string]interface{}, error) {
+	params := map[string]interface{}{}
+	missing := []string{}
+	for _, d := range declared {
+		if _, ok := params[d.Name]; ok {
+			return nil, fmt.Errorf("duplicate parameter %q in profile", d.Name)
+		}
+		s, ok := provided[d.Name]
+		switch {
+		case ok:
+			v, err := parseParam(d, s)
+			if err != nil {
+				return nil, err
+			}
+			params[d.Name] = v
+		case d.Default != nil:
+			v, err := defaultParam(d)
+			if err != nil {
+				return nil, err
+			}
+			params[d.Name] = v
+		case d.Required:
+			missing = append(missing, d.Name)
+		default:
+			v, err := zeroParam(d)
+			if err != nil {
+				return nil, err
+			}
+			params[d.Name] = v
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing required parameters: %s", strings.Join(missing, ", "))
+	}
+	names := []string{}
+	for name := range provided {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := params[name]; !ok {
+			return nil, fmt.Errorf("unknown parameter %q, the profile does not declare it", name)
+		}
+	}
+	return params, nil
+}
+
+func parseParam(d Parameter, s string) (interface{}, error) {
+	switch d.Type {
+	case "", stringParam:
+		return s, nil
+	case integerParam:
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q for parameter %q, must be an integer", s, d.Name)
+		}
+		return v, nil
+	case numberParam:
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q for parameter %q, must be a number", s, d.Name)
+		}
+		return v, nil
+	case booleanParam:
+		v, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q for parameter %q, must be a boolean", s, d.Name)
+		}
+		return v, nil
+	}
+	return nil, fmt.Errorf("invalid type %q for parameter %q", d.Type, d.Name)
+}
+
+// defaultParam checks that the parameter's default matches its type, and
+// returns it with the same type that parseParam returns.
+func defaultParam(d Parameter) (interface{}, error) {
+	invalid := func(t string) error {
+		return fmt.Errorf("invalid default %v for parameter %q, must be %s", d.Default, d.Name, t)
+	}
+	switch d.Type {
+	case "", stringParam:
+		if _, ok := d.Default.(string); !ok {
+			return nil, invalid("a string")
+		}
+		return d.Default, nil
+	case integerParam:
+		n, ok := toFloat(d.Default)
+		if !ok || n != math.Trunc(n) {
+			return nil, invalid("an integer")
+		}
+		return int64(n), nil
+	case numberParam:
+		n, ok := toFloat(d.Default)
+		if !ok {
+			return nil, invalid("a number")
+		}
+		return n, nil
+	case booleanParam:
+		if _, ok := d.Default.(bool); !ok {
+			return nil, invalid("a boolean")
+		}
+		return d.Default, nil
+	}
+	return nil, fmt.Errorf("invalid type %q for parameter %q", d.Type, d.Name)
+}
+
+func zeroParam(d Parameter) (interface{}, error) {
+	switch d.Type {
+	case "", stringParam:
+		return "", nil
+	case integerParam:
+		return int64(0), nil
+	case numberParam:
+		return float64(0), nil
+	case booleanParam:
+		return false, nil
+	}
+	return nil, fmt.Errorf("invalid type %q for parameter %q", d.Type, d.Name)
+}
+
+func renderValue(v interface{}, params map[string]interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		rendered := map[string]interface{}{}
+		for k, item := range val {
+			r, err := renderValue(item, params)
+			if err != nil {
+				return nil, err
+			}
+			rendered[k] = r
+		}
+		return rendered, nil
+	case []interface{}:
+		rendered := []interface{}{}
+		for _, item := range val {
+			r, err := renderValue(item, params)
+			if err != nil {
+				return nil, err
+			}
+			rendered = append(rendered, r)
+		}
+		return rendered, nil
+	case string:
+		if m := singleParamRE.FindStringSubmatch(val); m != nil {
+			p, ok := params[m[1]]
+			if !ok {
+				return nil, fmt.Errorf("unknown parameter %q in %q", m[1], val)
+			}
+			return p, nil
+		}
+		return renderString(val, params)
+	}
+	return v, nil
+}
+
+func renderString(s string, params map[string]interface{}) (string, error) {
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+	t, err := template.New("param").Option("missingkey=error").Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %q: %w", s, err)
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, map[string]interface{}{"params": params}); err != nil {
+		return "", fmt.Errorf("failed to render %q: %w", s, err)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,115 @@
+package profiles
+
+import (
+	"testing"
+
+	helmv2beta1 "github.com/fluxcd/helm-controller/api/v2beta1"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestMakeArtifacts_params(t *testing.T) {
+	p, err := ParseBytes(mustRead(t, "testdata/profile_with_params.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := MakeArtifacts(p, &ProfileOptions{
+		ProfileURL: testProfileURL,
+		Branch:     "main",
+		Params:     map[string]string{"environment": "staging", "replicas": "3"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []runtime.Object{
+		testMakeGitRepository("subscription-testing-main", testProfileURL, branch("main")),
+		testMakeHelmRepository("subscription-helm-repository-charts-bitnami-com-bitnami", testHelmRepoURL),
+		testMakeHelmRelease("subscription-helm-release-nginx-server",
+			gitRepositorySourceRef("nginx/chart", "subscription-testing-main"),
+			targetNamespace("web-staging"),
+			releaseValues(`{"debug":false,"ingress":{"annotations":["{{ .Release.Name }}"],"hostname":"nginx.staging.example.com"},"replicaCount":3}`)),
+		testMakeHelmRelease("subscription-helm-release-redis-server",
+			helmRepositorySourceRef("redis", "12.10.0", "subscription-helm-repository-charts-bitnami-com-bitnami")),
+	}
+	if diff := cmp.Diff(want, o); diff != "" {
+		t.Fatalf("failed to render parameters:\n%s", diff)
+	}
+	if v := p.Spec.Artifacts[1].Chart.Version; v != "{{ .params.redisVersion }}" {
+		t.Fatalf("the profile was modified, got version %q", v)
+	}
+}
+
+func TestMakeArtifacts_params_errors(t *testing.T) {
+	errorTests := []struct {
+		name    string
+		params  map[string]string
+		wantErr string
+	}{
+		{
+			name:    "missing required parameter",
+			params:  map[string]string{"replicas": "3"},
+			wantErr: "missing required parameters: environment",
+		},
+		{
+			name:    "unknown parameter",
+			params:  map[string]string{"environment": "prod", "region": "eu"},
+			wantErr: `unknown parameter "region", the profile does not declare it`,
+		},
+		{
+			name:    "invalid integer",
+			params:  map[string]string{"environment": "prod", "replicas": "three"},
+			wantErr: `invalid value "three" for parameter "replicas", must be an integer`,
+		},
+		{
+			name:    "invalid boolean",
+			params:  map[string]string{"environment": "prod", "debug": "maybe"},
+			wantErr: `invalid value "maybe" for parameter "debug", must be a boolean`,
+		},
+	}
+
+	p, err := ParseBytes(mustRead(t, "testdata/profile_with_params.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := MakeArtifacts(p, &ProfileOptions{
+				ProfileURL: testProfileURL,
+				Branch:     "main",
+				Params:     tt.params,
+			})
+			if msg := errorString(err); msg != tt.wantErr {
+				t.Fatalf("MakeArtifacts() got error %q, want %q", msg, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMakeArtifacts_undeclared_param_reference(t *testing.T) {
+	p := makeTestProfile(Artifact{Name: testChartname, Path: testChartPath, Namespace: "{{ .params.unknown }}"})
+
+	_, err := MakeArtifacts(p, &ProfileOptions{ProfileURL: testProfileURL, Branch: "main"})
+	want := `failed to render parameters for artifact "test-chart": failed to render "{{ .params.unknown }}": template: param:1:10: executing "param" at <.params.unknown>: map has no entry for key "unknown"`
+	if msg := errorString(err); msg != want {
+		t.Fatalf("MakeArtifacts() got error %q, want %q", msg, want)
+	}
+}
+
+func TestMakeArtifacts_unescaped_template(t *testing.T) {
+	p := makeTestProfile(Artifact{Name: testChartname, Path: testChartPath,
+		Values: map[string]interface{}{"annotations": []interface{}{"{{ .Release.Name }}"}}})
+
+	_, err := MakeArtifacts(p, &ProfileOptions{ProfileURL: testProfileURL, Branch: "main"})
+	want := `failed to render parameters for artifact "test-chart": failed to render "{{ .Release.Name }}": template: param:1:11: executing "param" at <.Release.Name>: map has no entry for key "Release"`
+	if msg := errorString(err); msg != want {
+		t.Fatalf("MakeArtifacts() got error %q, want %q", msg, want)
+	}
+}
+
+func targetNamespace(ns string) helmReleaseSpecFunc {
+	return func(o *helmv2beta1.HelmReleaseSpec) {
+		o.TargetNamespace = ns
+	}
+}
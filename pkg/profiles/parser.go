@@ -1,17 +1,14 @@
 package profiles
 
-import (
-	"fmt"
-
-	"sigs.k8s.io/yaml"
-)
-
 // ParseBytes takes a slice of bytes, parses it as YAML and returns the
 // resulting Profile.
+//
+// Parsing is strict, if the Profile has unknown fields or fails the checks
+// done by Lint, a LintError with all the problems is returned.
 func ParseBytes(b []byte) (*Profile, error) {
-	p := &Profile{}
-	if err := yaml.Unmarshal(b, &p); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal yaml: %w", err)
+	p, problems := Lint(b)
+	if len(problems) > 0 {
+		return nil, &LintError{Problems: problems}
 	}
 	return p, nil
 }
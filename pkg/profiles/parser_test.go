@@ -25,6 +25,7 @@ func TestParse(t *testing.T) {
 		},
 		Spec: ProfileSpec{
 			Description: "Profile for deploying nginx",
+			Version:     "v0.0.1",
 			Artifacts: []Artifact{
 				{Name: "nginx-server", Path: "nginx/chart"},
 			},
@@ -1,20 +1,39 @@
 package profiles
 
 import (
+	"errors"
+	"fmt"
 	"strings"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
 
 	helmv2beta1 "github.com/fluxcd/helm-controller/api/v2beta1"
+	kustomizev1beta1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
 	sourcev1beta1 "github.com/fluxcd/source-controller/api/v1beta1"
 )
 
 const (
-	gitRepositoryKind       = "GitRepository"
-	gitRepositoryAPIVersion = "source.toolkit.fluxcd.io/v1beta1"
-	helmReleaseKind         = "HelmRelease"
-	helmReleaseAPIVersion   = "helm.toolkit.fluxcd.io/v2beta1"
+	gitRepositoryKind        = "GitRepository"
+	gitRepositoryAPIVersion  = "source.toolkit.fluxcd.io/v1beta1"
+	helmRepositoryKind       = "HelmRepository"
+	helmRepositoryAPIVersion = "source.toolkit.fluxcd.io/v1beta1"
+	helmReleaseKind          = "HelmRelease"
+	helmReleaseAPIVersion    = "helm.toolkit.fluxcd.io/v2beta1"
+	kustomizationKind        = "Kustomization"
+	kustomizationAPIVersion  = "kustomize.toolkit.fluxcd.io/v1beta1"
+	namespaceKind            = "Namespace"
+	namespaceAPIVersion      = "v1"
+
+	shortCommitLength = 7
+	defaultBranch     = "main"
+
+	// defaultInterval is the reconciliation interval for the generated
+	// resources, the Flux CRDs require an interval for each of them.
+	defaultInterval = 5 * time.Minute
 )
 
 // ProfileOptions is a set of configuration options to use when creating the
@@ -22,29 +41,292 @@ const (
 type ProfileOptions struct {
 	ProfileURL string
 	Branch     string
+	Tag        string
+	Commit     string
+	// SemVer is a semver range that is resolved against the tags in the
+	// Profile repo.
+	SemVer string
+	// Values are the Helm values for the artifacts, keyed by the artifact
+	// name, values for AllArtifacts are applied to every Helm artifact.
+	Values map[string]*ReleaseValues
+	// Params are the values for the parameters declared in the Profile.
+	Params map[string]string
+	// Namespace is the namespace for the HelmReleases and Kustomizations, if
+	// this is empty, the resources have no namespace.
+	Namespace string
+	// TargetNamespace is the namespace that artifacts without a namespace are
+	// deployed to.
+	TargetNamespace string
+	// SourceNamespace is the namespace for the GitRepositories and
+	// HelmRepositories e.g. a shared flux-system namespace, if this is empty,
+	// the sources are in the Namespace.
+	SourceNamespace string
+	// CreateNamespace adds Namespace resources for the Namespace and the
+	// namespaces that the artifacts are deployed to.
+	CreateNamespace bool
+	// NamePrefix is the prefix for the names of the generated resources, if
+	// this is empty, DefaultNamePrefix is used.
+	NamePrefix string
+}
+
+func (o *ProfileOptions) namePrefix() string {
+	if o.NamePrefix != "" {
+		return o.NamePrefix
+	}
+	return DefaultNamePrefix
+}
+
+func (o *ProfileOptions) sourceNamespace() string {
+	if o.SourceNamespace != "" {
+		return o.SourceNamespace
+	}
+	return o.Namespace
+}
+
+// Ref returns the git reference that the Profile should be fetched from.
+//
+// A Commit takes precedence over a Tag, which takes precedence over a Branch,
+// matching the precedence used by Flux when checking out a GitRepository.
+//
+// A SemVer range can't be fetched from directly, it must be resolved to a Tag
+// by the caller.
+func (o *ProfileOptions) Ref() string {
+	switch {
+	case o.Commit != "":
+		return o.Commit
+	case o.Tag != "":
+		return o.Tag
+	}
+	return o.branch()
+}
+
+// branch returns the Branch, or the main branch if no Branch, Tag, Commit or
+// SemVer range is provided.
+func (o *ProfileOptions) branch() string {
+	if o.Branch == "" && o.Tag == "" && o.Commit == "" && o.SemVer == "" {
+		return defaultBranch
+	}
+	return o.Branch
+}
+
+// nameRef returns the git reference to use when naming resources, commits are
+// shortened to keep the names readable, and semver ranges are named by the
+// range so that different ranges of the same repo don't share a name.
+func (o *ProfileOptions) nameRef() string {
+	if o.Commit != "" && len(o.Commit) > shortCommitLength {
+		return o.Commit[:shortCommitLength]
+	}
+	if o.Commit == "" && o.SemVer != "" {
+		return semVerNameRef(o.SemVer)
+	}
+	return o.Ref()
+}
+
+// MakeArtifacts creates and returns the resources to deploy the artifacts in
+// a Profile, a HelmRelease or Kustomization for each artifact, and the
+// GitRepositories and HelmRepositories that they're sourced from.
+//
+// Profiles with no artifacts, duplicate artifact names, artifacts without a
+// path, a helm chart or a kustomize path, or dependency cycles are rejected,
+// and nested Profiles must be resolved with ResolveProfiles. If the values in
+// the options don't match the artifacts' schemas, a ValuesError with every
+// violation is returned.
+func MakeArtifacts(p *Profile, opts *ProfileOptions) ([]runtime.Object, error) {
+	p, err := renderProfile(p, opts.Params)
+	if err != nil {
+		return nil, err
+	}
+	return makeArtifacts(p, nil, opts)
+}
+
+// makeArtifacts makes the resources for the rendered Profile, the artifacts
+// in repos are sourced from the Profile repos keyed by artifact name, or the
+// Profile repo in the options.
+func makeArtifacts(p *Profile, repos map[string]*ProfileOptions, opts *ProfileOptions) ([]runtime.Object, error) {
+	if err := validateNamespaces(opts.Namespace, opts.TargetNamespace, opts.SourceNamespace); err != nil {
+		return nil, err
+	}
+	if len(p.Spec.Artifacts) == 0 {
+		return nil, errors.New("no artifacts found in profile")
+	}
+	artifacts := map[string]Artifact{}
+	for i, a := range p.Spec.Artifacts {
+		if a.Namespace == "" {
+			a.Namespace = opts.TargetNamespace
+			p.Spec.Artifacts[i] = a
+		}
+		if err := validateNamespaces(a.Namespace); err != nil {
+			return nil, fmt.Errorf("invalid namespace for artifact %q: %w", a.Name, err)
+		}
+		if _, ok := artifacts[a.Name]; ok {
+			return nil, fmt.Errorf("duplicate artifact name %q in profile", a.Name)
+		}
+		artifacts[a.Name] = a
+		if a.Profile != nil {
+			return nil, fmt.Errorf("artifact %q is a nested profile, nested profiles must be resolved before making artifacts", a.Name)
+		}
+		if artifactKindCount(a) == 0 {
+			return nil, fmt.Errorf("artifact %q must have a path, a helm chart or a kustomize path", a.Name)
+		}
+	}
+	if cycle := dependencyCycle(p.Spec.Artifacts); cycle != nil {
+		return nil, fmt.Errorf("dependency cycle detected: %s", strings.Join(cycle, " -> "))
+	}
+	if err := validateValues(p, opts.Values); err != nil {
+		return nil, err
+	}
+
+	prefix := opts.namePrefix()
+	gitRepositories := []runtime.Object{}
+	gitRepositorySources := map[string]string{}
+	sources := []runtime.Object{}
+	releases := []runtime.Object{}
+	valuesErr := &ValuesError{}
+	helmRepositories := map[string]bool{}
+	for _, a := range p.Spec.Artifacts {
+		src := opts
+		if s := repos[a.Name]; s != nil {
+			src = s
+		}
+		if a.Chart == nil {
+			name := makeGitRepoName(prefix, src)
+			key, ok := gitRepositorySources[name]
+			if ok && key != profileKey(src) {
+				return nil, fmt.Errorf("GitRepository name %q is used for both %s and %s", name, key, profileKey(src))
+			}
+			if !ok {
+				gitRepositorySources[name] = profileKey(src)
+				gitRepositories = append(gitRepositories, createGitRepository(name, src))
+			}
+		}
+		dependsOn, err := makeDependsOn(prefix, a, artifacts)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case a.Chart != nil:
+			name, err := makeHelmRepoName(prefix, a.Chart.Repository)
+			if err != nil {
+				return nil, fmt.Errorf("invalid helm repository for artifact %q: %w", a.Name, err)
+			}
+			if !helmRepositories[name] {
+				helmRepositories[name] = true
+				if IsOCIRepository(a.Chart.Repository) {
+					sources = append(sources, createOCIHelmRepository(name, a.Chart))
+				} else {
+					sources = append(sources, createHelmRepository(name, a.Chart))
+				}
+			}
+			hr := createHelmReleaseFromHelmRepository(a, makeHelmReleaseName(prefix, a), name)
+			hr.Spec.DependsOn = dependsOn
+			if err := collectValuesErrors(valuesErr, applyValues(hr, a, opts)); err != nil {
+				return nil, err
+			}
+			releases = append(releases, hr)
+		case a.Kustomize != nil:
+			k := createKustomization(a, makeKustomizationName(prefix, a), makeGitRepoName(prefix, src))
+			k.Spec.DependsOn = dependsOn
+			releases = append(releases, k)
+		default:
+			hr := createHelmRelease(a, makeHelmReleaseName(prefix, a), makeGitRepoName(prefix, src))
+			hr.Spec.DependsOn = dependsOn
+			if err := collectValuesErrors(valuesErr, applyValues(hr, a, opts)); err != nil {
+				return nil, err
+			}
+			releases = append(releases, hr)
+		}
+	}
+	if len(valuesErr.Violations) > 0 {
+		return nil, valuesErr
+	}
+	sources = append(gitRepositories, sources...)
+	result := append(sources, releases...)
+	setNamespaces(result, opts)
+	if opts.CreateNamespace {
+		result = append(createNamespaces(p, opts), result...)
+	}
+	return result, nil
+}
+
+// setNamespaces sets the namespaces of the resources, if the sources are in a
+// different namespace to the releases, the sourceRefs have the namespace of
+// the sources.
+func setNamespaces(objs []runtime.Object, opts *ProfileOptions) {
+	sourceNamespace := opts.sourceNamespace()
+	refNamespace := ""
+	if sourceNamespace != opts.Namespace {
+		refNamespace = sourceNamespace
+	}
+	for _, o := range objs {
+		switch v := o.(type) {
+		case *helmv2beta1.HelmRelease:
+			v.Namespace = opts.Namespace
+			v.Spec.Chart.Spec.SourceRef.Namespace = refNamespace
+		case *kustomizev1beta1.Kustomization:
+			v.Namespace = opts.Namespace
+			v.Spec.SourceRef.Namespace = refNamespace
+		case metav1.Object:
+			v.SetNamespace(sourceNamespace)
+		}
+	}
+}
+
+// createNamespaces returns a Namespace for the namespace of the releases, and
+// each of the namespaces that the artifacts are deployed to.
+func createNamespaces(p *Profile, opts *ProfileOptions) []runtime.Object {
+	namespaces := []runtime.Object{}
+	seen := map[string]bool{}
+	add := func(name string) {
+		if name == "" || seen[name] {
+			return
+		}
+		seen[name] = true
+		namespaces = append(namespaces, &corev1.Namespace{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       namespaceKind,
+				APIVersion: namespaceAPIVersion,
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+			},
+		})
+	}
+	add(opts.Namespace)
+	for _, a := range p.Spec.Artifacts {
+		add(a.Namespace)
+	}
+	return namespaces
 }
 
-// MakeArtifacts creates and returns the artifacts necessary to deploy a Profile.
-func MakeArtifacts(p *Profile, opts *ProfileOptions) []runtime.Object {
-	objects := []runtime.Object{}
-	objects = append(objects, createGitRepository(p, opts))
-	objects = append(objects, createHelmRelease(p, opts))
-	return objects
+func validateNamespaces(namespaces ...string) error {
+	for _, ns := range namespaces {
+		if ns == "" {
+			continue
+		}
+		if msgs := validation.IsDNS1123Label(ns); len(msgs) > 0 {
+			return fmt.Errorf("invalid namespace %q: %s", ns, strings.Join(msgs, ", "))
+		}
+	}
+	return nil
 }
 
-func createGitRepository(p *Profile, opts *ProfileOptions) *sourcev1beta1.GitRepository {
+func createGitRepository(name string, opts *ProfileOptions) *sourcev1beta1.GitRepository {
 	return &sourcev1beta1.GitRepository{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: makeGitRepoName(opts.ProfileURL, opts.Branch),
+			Name: name,
 		},
 		TypeMeta: metav1.TypeMeta{
 			Kind:       gitRepositoryKind,
 			APIVersion: gitRepositoryAPIVersion,
 		},
 		Spec: sourcev1beta1.GitRepositorySpec{
-			URL: opts.ProfileURL,
+			URL:      opts.ProfileURL,
+			Interval: metav1.Duration{Duration: defaultInterval},
 			Reference: &sourcev1beta1.GitRepositoryRef{
-				Branch: opts.Branch,
+				Branch: opts.branch(),
+				Tag:    opts.Tag,
+				SemVer: opts.SemVer,
+				Commit: opts.Commit,
 			},
 		},
 	}
@@ -54,23 +336,24 @@ func createGitRepository(p *Profile, opts *ProfileOptions) *sourcev1beta1.GitRep
 	// }
 }
 
-func createHelmRelease(p *Profile, opts *ProfileOptions) *helmv2beta1.HelmRelease {
+func createHelmRelease(a Artifact, name, repositoryName string) *helmv2beta1.HelmRelease {
 	return &helmv2beta1.HelmRelease{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: makeHelmReleaseName(p.Spec.Artifacts[0]),
+			Name: name,
 		},
 		TypeMeta: metav1.TypeMeta{
 			Kind:       helmReleaseKind,
 			APIVersion: helmReleaseAPIVersion,
 		},
 		Spec: helmv2beta1.HelmReleaseSpec{
+			Interval:        metav1.Duration{Duration: defaultInterval},
+			TargetNamespace: a.Namespace,
 			Chart: helmv2beta1.HelmChartTemplate{
 				Spec: helmv2beta1.HelmChartTemplateSpec{
-					// TODO obvs don't rely on index 0
-					Chart: p.Spec.Artifacts[0].Path,
+					Chart: a.Path,
 					SourceRef: helmv2beta1.CrossNamespaceObjectReference{
 						Kind: gitRepositoryKind,
-						Name: makeGitRepoName(opts.ProfileURL, opts.Branch),
+						Name: repositoryName,
 					},
 				},
 			},
@@ -82,17 +365,74 @@ func createHelmRelease(p *Profile, opts *ProfileOptions) *helmv2beta1.HelmReleas
 	// 	}
 }
 
-func makeHelmReleaseName(a Artifact) string {
-	return join("subscription", "helm-release", a.Name)
+func createHelmRepository(name string, c *HelmChartSpec) *sourcev1beta1.HelmRepository {
+	return &sourcev1beta1.HelmRepository{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		TypeMeta: metav1.TypeMeta{
+			Kind:       helmRepositoryKind,
+			APIVersion: helmRepositoryAPIVersion,
+		},
+		Spec: sourcev1beta1.HelmRepositorySpec{
+			URL:      c.Repository,
+			Interval: metav1.Duration{Duration: defaultInterval},
+		},
+	}
 }
 
-// TODO: error if this is more than 63 chars long.
-func makeGitRepoName(profileURL, branch string) string {
-	repoParts := strings.Split(profileURL, "/")
-	repoName := strings.TrimSuffix(repoParts[len(repoParts)-1], ".git")
-	return join("subscription", repoName, branch)
+func createHelmReleaseFromHelmRepository(a Artifact, name, repositoryName string) *helmv2beta1.HelmRelease {
+	return &helmv2beta1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		TypeMeta: metav1.TypeMeta{
+			Kind:       helmReleaseKind,
+			APIVersion: helmReleaseAPIVersion,
+		},
+		Spec: helmv2beta1.HelmReleaseSpec{
+			Interval:        metav1.Duration{Duration: defaultInterval},
+			TargetNamespace: a.Namespace,
+			Chart: helmv2beta1.HelmChartTemplate{
+				Spec: helmv2beta1.HelmChartTemplateSpec{
+					Chart:     a.Chart.Chart,
+					Version:   a.Chart.Version,
+					SourceRef: makeHelmRepositorySourceRef(a.Chart, repositoryName),
+				},
+			},
+		},
+	}
+}
+
+func makeHelmRepositorySourceRef(c *HelmChartSpec, name string) helmv2beta1.CrossNamespaceObjectReference {
+	ref := helmv2beta1.CrossNamespaceObjectReference{
+		Kind: helmRepositoryKind,
+		Name: name,
+	}
+	if IsOCIRepository(c.Repository) {
+		ref.APIVersion = ociHelmRepositoryAPIVersion
+	}
+	return ref
 }
 
-func join(s ...string) string {
-	return strings.Join(s, "-")
+func createKustomization(a Artifact, name, repositoryName string) *kustomizev1beta1.Kustomization {
+	return &kustomizev1beta1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		TypeMeta: metav1.TypeMeta{
+			Kind:       kustomizationKind,
+			APIVersion: kustomizationAPIVersion,
+		},
+		Spec: kustomizev1beta1.KustomizationSpec{
+			Path:            a.Kustomize.Path,
+			Interval:        metav1.Duration{Duration: defaultInterval},
+			Prune:           true,
+			TargetNamespace: a.Namespace,
+			SourceRef: kustomizev1beta1.CrossNamespaceSourceReference{
+				Kind: gitRepositoryKind,
+				Name: repositoryName,
+			},
+		},
+	}
 }
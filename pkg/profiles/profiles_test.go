@@ -2,20 +2,27 @@ package profiles
 
 import (
 	"testing"
+	"time"
 
 	helmv2beta1 "github.com/fluxcd/helm-controller/api/v2beta1"
+	kustomizev1beta1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+	"github.com/fluxcd/pkg/runtime/dependency"
 	sourcev1beta1 "github.com/fluxcd/source-controller/api/v1beta1"
 	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
 const (
-	testChartname  = "test-chart"
-	testChartPath  = "artifacts"
-	testProfileURL = "https://example.com/testing/testing.git"
+	testChartname   = "test-chart"
+	testChartPath   = "artifacts"
+	testProfileURL  = "https://example.com/testing/testing.git"
+	testHelmRepoURL = "https://charts.bitnami.com/bitnami"
 )
 
+var testInterval = metav1.Duration{Duration: 5 * time.Minute}
+
 type gitRepositoryRefFunc func(*sourcev1beta1.GitRepositoryRef)
 
 func branch(n string) gitRepositoryRefFunc {
@@ -24,6 +31,24 @@ func branch(n string) gitRepositoryRefFunc {
 	}
 }
 
+func tag(n string) gitRepositoryRefFunc {
+	return func(o *sourcev1beta1.GitRepositoryRef) {
+		o.Tag = n
+	}
+}
+
+func commit(n string) gitRepositoryRefFunc {
+	return func(o *sourcev1beta1.GitRepositoryRef) {
+		o.Commit = n
+	}
+}
+
+func semVer(n string) gitRepositoryRefFunc {
+	return func(o *sourcev1beta1.GitRepositoryRef) {
+		o.SemVer = n
+	}
+}
+
 func testMakeGitRepository(name, repoURL string, opts ...gitRepositoryRefFunc) *sourcev1beta1.GitRepository {
 	ref := &sourcev1beta1.GitRepositoryRef{}
 	for _, o := range opts {
@@ -35,6 +60,7 @@ func testMakeGitRepository(name, repoURL string, opts ...gitRepositoryRefFunc) *
 		ObjectMeta: metav1.ObjectMeta{Name: name},
 		Spec: sourcev1beta1.GitRepositorySpec{
 			URL:       repoURL,
+			Interval:  testInterval,
 			Reference: ref,
 		},
 	}
@@ -56,8 +82,34 @@ func gitRepositorySourceRef(chart, repositoryName string) helmReleaseSpecFunc {
 	}
 }
 
+func helmRepositorySourceRef(chart, version, repositoryName string) helmReleaseSpecFunc {
+	return func(o *helmv2beta1.HelmReleaseSpec) {
+		o.Chart = helmv2beta1.HelmChartTemplate{
+			Spec: helmv2beta1.HelmChartTemplateSpec{
+				Chart:   chart,
+				Version: version,
+				SourceRef: helmv2beta1.CrossNamespaceObjectReference{
+					Kind: "HelmRepository",
+					Name: repositoryName,
+				},
+			},
+		}
+	}
+}
+
+func testMakeHelmRepository(name, repoURL string) *sourcev1beta1.HelmRepository {
+	return &sourcev1beta1.HelmRepository{
+		TypeMeta:   metav1.TypeMeta{Kind: "HelmRepository", APIVersion: "source.toolkit.fluxcd.io/v1beta1"},
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: sourcev1beta1.HelmRepositorySpec{
+			URL:      repoURL,
+			Interval: testInterval,
+		},
+	}
+}
+
 func testMakeHelmRelease(name string, opts ...helmReleaseSpecFunc) *helmv2beta1.HelmRelease {
-	spec := helmv2beta1.HelmReleaseSpec{}
+	spec := helmv2beta1.HelmReleaseSpec{Interval: testInterval}
 	for _, o := range opts {
 		o(&spec)
 	}
@@ -69,6 +121,22 @@ func testMakeHelmRelease(name string, opts ...helmReleaseSpecFunc) *helmv2beta1.
 	}
 }
 
+func testMakeKustomization(name, path, repositoryName string) *kustomizev1beta1.Kustomization {
+	return &kustomizev1beta1.Kustomization{
+		TypeMeta:   metav1.TypeMeta{Kind: "Kustomization", APIVersion: "kustomize.toolkit.fluxcd.io/v1beta1"},
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: kustomizev1beta1.KustomizationSpec{
+			Path:     path,
+			Interval: testInterval,
+			Prune:    true,
+			SourceRef: kustomizev1beta1.CrossNamespaceSourceReference{
+				Kind: "GitRepository",
+				Name: repositoryName,
+			},
+		},
+	}
+}
+
 func TestMakeArtifacts(t *testing.T) {
 	artifactTests := []struct {
 		name      string
@@ -83,14 +151,148 @@ func TestMakeArtifacts(t *testing.T) {
 				testMakeHelmRelease("subscription-helm-release-test-chart", gitRepositorySourceRef(testChartPath, "subscription-testing-main")),
 			},
 		},
+		{
+			name: "multiple helm releases from a git repository",
+			profile: makeTestProfile(
+				Artifact{Name: "nginx-server", Path: "nginx/chart"},
+				Artifact{Name: "redis-server", Path: "redis/chart"},
+			),
+			artifacts: []runtime.Object{
+				testMakeGitRepository("subscription-testing-main", testProfileURL, branch("main")),
+				testMakeHelmRelease("subscription-helm-release-nginx-server", gitRepositorySourceRef("nginx/chart", "subscription-testing-main")),
+				testMakeHelmRelease("subscription-helm-release-redis-server", gitRepositorySourceRef("redis/chart", "subscription-testing-main")),
+			},
+		},
+		{
+			name: "helm releases from a shared helm repository",
+			profile: makeTestProfile(
+				Artifact{Name: "nginx-server", Chart: &HelmChartSpec{Chart: "nginx", Repository: testHelmRepoURL, Version: "8.9.0"}},
+				Artifact{Name: "redis-server", Chart: &HelmChartSpec{Chart: "redis", Repository: testHelmRepoURL, Version: "12.10.0"}},
+			),
+			artifacts: []runtime.Object{
+				testMakeHelmRepository("subscription-helm-repository-charts-bitnami-com-bitnami", testHelmRepoURL),
+				testMakeHelmRelease("subscription-helm-release-nginx-server", helmRepositorySourceRef("nginx", "8.9.0", "subscription-helm-repository-charts-bitnami-com-bitnami")),
+				testMakeHelmRelease("subscription-helm-release-redis-server", helmRepositorySourceRef("redis", "12.10.0", "subscription-helm-repository-charts-bitnami-com-bitnami")),
+			},
+		},
+		{
+			name: "helm releases from git and helm repositories",
+			profile: makeTestProfile(
+				Artifact{Name: "nginx-server", Path: "nginx/chart"},
+				Artifact{Name: "redis-server", Chart: &HelmChartSpec{Chart: "redis", Repository: testHelmRepoURL, Version: "12.10.0"}},
+				Artifact{Name: "podinfo", Chart: &HelmChartSpec{Chart: "podinfo", Repository: "https://stefanprodan.github.io/podinfo", Version: "5.2.0"}},
+			),
+			artifacts: []runtime.Object{
+				testMakeGitRepository("subscription-testing-main", testProfileURL, branch("main")),
+				testMakeHelmRepository("subscription-helm-repository-charts-bitnami-com-bitnami", testHelmRepoURL),
+				testMakeHelmRepository("subscription-helm-repository-stefanprodan-github-io-podinfo", "https://stefanprodan.github.io/podinfo"),
+				testMakeHelmRelease("subscription-helm-release-nginx-server", gitRepositorySourceRef("nginx/chart", "subscription-testing-main")),
+				testMakeHelmRelease("subscription-helm-release-redis-server", helmRepositorySourceRef("redis", "12.10.0", "subscription-helm-repository-charts-bitnami-com-bitnami")),
+				testMakeHelmRelease("subscription-helm-release-podinfo", helmRepositorySourceRef("podinfo", "5.2.0", "subscription-helm-repository-stefanprodan-github-io-podinfo")),
+			},
+		},
+		{
+			name: "helm release from an oci repository",
+			profile: makeTestProfile(
+				Artifact{Name: "podinfo", Chart: &HelmChartSpec{Chart: "podinfo", Repository: "oci://ghcr.io/stefanprodan/charts", Version: "6.1.0"}},
+			),
+			artifacts: []runtime.Object{
+				&OCIHelmRepository{
+					TypeMeta:   metav1.TypeMeta{Kind: "HelmRepository", APIVersion: "source.toolkit.fluxcd.io/v1beta2"},
+					ObjectMeta: metav1.ObjectMeta{Name: "subscription-helm-repository-ghcr-io-stefanprodan-charts"},
+					Spec:       OCIHelmRepositorySpec{URL: "oci://ghcr.io/stefanprodan/charts", Type: "oci", Interval: testInterval},
+				},
+				testMakeHelmRelease("subscription-helm-release-podinfo", func(o *helmv2beta1.HelmReleaseSpec) {
+					helmRepositorySourceRef("podinfo", "6.1.0", "subscription-helm-repository-ghcr-io-stefanprodan-charts")(o)
+					o.Chart.Spec.SourceRef.APIVersion = "source.toolkit.fluxcd.io/v1beta2"
+				}),
+			},
+		},
+		{
+			name: "kustomization from a git repository",
+			profile: makeTestProfile(
+				Artifact{Name: "nginx-server", Path: "nginx/chart"},
+				Artifact{Name: "policies", Kustomize: &KustomizeSpec{Path: "policies/base"}},
+			),
+			artifacts: []runtime.Object{
+				testMakeGitRepository("subscription-testing-main", testProfileURL, branch("main")),
+				testMakeHelmRelease("subscription-helm-release-nginx-server", gitRepositorySourceRef("nginx/chart", "subscription-testing-main")),
+				testMakeKustomization("subscription-kustomization-policies", "policies/base", "subscription-testing-main"),
+			},
+		},
 	}
 
 	for _, tt := range artifactTests {
 		t.Run(tt.name, func(t *testing.T) {
-			o := MakeArtifacts(tt.profile, &ProfileOptions{
+			o, err := MakeArtifacts(tt.profile, &ProfileOptions{
 				ProfileURL: testProfileURL,
 				Branch:     "main",
 			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tt.artifacts, o); diff != "" {
+				t.Fatalf("failed to make artifacts:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMakeArtifacts_refs(t *testing.T) {
+	refTests := []struct {
+		name      string
+		opts      *ProfileOptions
+		artifacts []runtime.Object
+	}{
+		{
+			name: "tag",
+			opts: &ProfileOptions{ProfileURL: testProfileURL, Branch: "main", Tag: "v0.1.0"},
+			artifacts: []runtime.Object{
+				testMakeGitRepository("subscription-testing-v0.1.0", testProfileURL, branch("main"), tag("v0.1.0")),
+				testMakeHelmRelease("subscription-helm-release-test-chart", gitRepositorySourceRef(testChartPath, "subscription-testing-v0.1.0")),
+			},
+		},
+		{
+			name: "tag without a branch",
+			opts: &ProfileOptions{ProfileURL: testProfileURL, Tag: "v0.1.0"},
+			artifacts: []runtime.Object{
+				testMakeGitRepository("subscription-testing-v0.1.0", testProfileURL, tag("v0.1.0")),
+				testMakeHelmRelease("subscription-helm-release-test-chart", gitRepositorySourceRef(testChartPath, "subscription-testing-v0.1.0")),
+			},
+		},
+		{
+			name: "no ref",
+			opts: &ProfileOptions{ProfileURL: testProfileURL},
+			artifacts: []runtime.Object{
+				testMakeGitRepository("subscription-testing-main", testProfileURL, branch("main")),
+				testMakeHelmRelease("subscription-helm-release-test-chart", gitRepositorySourceRef(testChartPath, "subscription-testing-main")),
+			},
+		},
+		{
+			name: "commit",
+			opts: &ProfileOptions{ProfileURL: testProfileURL, Branch: "main", Commit: "0e8da0c7d1e1ec1f4fd0a8ab53bda0a4c6d3b4a5"},
+			artifacts: []runtime.Object{
+				testMakeGitRepository("subscription-testing-0e8da0c", testProfileURL, branch("main"), commit("0e8da0c7d1e1ec1f4fd0a8ab53bda0a4c6d3b4a5")),
+				testMakeHelmRelease("subscription-helm-release-test-chart", gitRepositorySourceRef(testChartPath, "subscription-testing-0e8da0c")),
+			},
+		},
+		{
+			name: "semver",
+			opts: &ProfileOptions{ProfileURL: testProfileURL, Branch: "main", SemVer: ">=1.2.0 <2.0.0"},
+			artifacts: []runtime.Object{
+				testMakeGitRepository("subscription-testing-semver-1.2.0-2.0.0-4f9d93e4", testProfileURL, branch("main"), semVer(">=1.2.0 <2.0.0")),
+				testMakeHelmRelease("subscription-helm-release-test-chart", gitRepositorySourceRef(testChartPath, "subscription-testing-semver-1.2.0-2.0.0-4f9d93e4")),
+			},
+		},
+	}
+
+	for _, tt := range refTests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, err := MakeArtifacts(makeTestProfile(Artifact{Name: testChartname, Path: testChartPath}), tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
 
 			if diff := cmp.Diff(tt.artifacts, o); diff != "" {
 				t.Fatalf("failed to make artifacts:\n%s", diff)
@@ -99,6 +301,247 @@ func TestMakeArtifacts(t *testing.T) {
 	}
 }
 
+func TestProfileOptionsRef(t *testing.T) {
+	refTests := []struct {
+		opts *ProfileOptions
+		want string
+	}{
+		{&ProfileOptions{Branch: "main"}, "main"},
+		{&ProfileOptions{}, "main"},
+		{&ProfileOptions{Branch: "main", Tag: "v0.1.0"}, "v0.1.0"},
+		{&ProfileOptions{Branch: "main", Tag: "v0.1.0", Commit: "0e8da0c"}, "0e8da0c"},
+	}
+
+	for _, tt := range refTests {
+		if r := tt.opts.Ref(); r != tt.want {
+			t.Errorf("Ref() got %q, want %q", r, tt.want)
+		}
+	}
+}
+
+func TestMakeArtifacts_dependsOn(t *testing.T) {
+	p := makeTestProfile(
+		Artifact{Name: "cert-manager", Chart: &HelmChartSpec{Chart: "cert-manager", Repository: testHelmRepoURL, Version: "1.3.0"}},
+		Artifact{Name: "ingress", Path: testChartPath, DependsOn: []string{"cert-manager"}},
+		Artifact{Name: "crds", Kustomize: &KustomizeSpec{Path: "crds"}},
+		Artifact{Name: "policies", Kustomize: &KustomizeSpec{Path: "policies"}, DependsOn: []string{"crds"}},
+	)
+
+	o, err := MakeArtifacts(p, &ProfileOptions{ProfileURL: testProfileURL, Branch: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policies := testMakeKustomization("subscription-kustomization-policies", "policies", "subscription-testing-main")
+	policies.Spec.DependsOn = []dependency.CrossNamespaceDependencyReference{{Name: "subscription-kustomization-crds"}}
+	want := []runtime.Object{
+		testMakeGitRepository("subscription-testing-main", testProfileURL, branch("main")),
+		testMakeHelmRepository("subscription-helm-repository-charts-bitnami-com-bitnami", testHelmRepoURL),
+		testMakeHelmRelease("subscription-helm-release-cert-manager",
+			helmRepositorySourceRef("cert-manager", "1.3.0", "subscription-helm-repository-charts-bitnami-com-bitnami")),
+		testMakeHelmRelease("subscription-helm-release-ingress",
+			gitRepositorySourceRef(testChartPath, "subscription-testing-main"),
+			dependsOn("subscription-helm-release-cert-manager")),
+		testMakeKustomization("subscription-kustomization-crds", "crds", "subscription-testing-main"),
+		policies,
+	}
+	if diff := cmp.Diff(want, o); diff != "" {
+		t.Fatalf("failed to make artifacts with dependencies:\n%s", diff)
+	}
+}
+
+func TestMakeArtifacts_name_prefix(t *testing.T) {
+	p := makeTestProfile(
+		Artifact{Name: testChartname, Path: testChartPath},
+		Artifact{Name: "redis", Chart: &HelmChartSpec{Chart: "redis", Repository: testHelmRepoURL, Version: "12.10.0"}},
+	)
+
+	o, err := MakeArtifacts(p, &ProfileOptions{
+		ProfileURL: testProfileURL,
+		Branch:     "feature/New-UI",
+		NamePrefix: "team-a",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []runtime.Object{
+		testMakeGitRepository("team-a-testing-feature-new-ui", testProfileURL, branch("feature/New-UI")),
+		testMakeHelmRepository("team-a-helm-repository-charts-bitnami-com-bitnami", testHelmRepoURL),
+		testMakeHelmRelease("team-a-helm-release-test-chart",
+			gitRepositorySourceRef(testChartPath, "team-a-testing-feature-new-ui")),
+		testMakeHelmRelease("team-a-helm-release-redis",
+			helmRepositorySourceRef("redis", "12.10.0", "team-a-helm-repository-charts-bitnami-com-bitnami")),
+	}
+	if diff := cmp.Diff(want, o); diff != "" {
+		t.Fatalf("failed to make artifacts with a name prefix:\n%s", diff)
+	}
+}
+
+func TestMakeArtifacts_namespaces(t *testing.T) {
+	p := makeTestProfile(
+		Artifact{Name: testChartname, Path: testChartPath},
+		Artifact{Name: "redis", Chart: &HelmChartSpec{Chart: "redis", Repository: testHelmRepoURL, Version: "12.10.0"}, Namespace: "cache"},
+		Artifact{Name: "policies", Kustomize: &KustomizeSpec{Path: "policies"}},
+	)
+
+	o, err := MakeArtifacts(p, &ProfileOptions{
+		ProfileURL:      testProfileURL,
+		Branch:          "main",
+		Namespace:       "apps",
+		TargetNamespace: "web",
+		SourceNamespace: "flux-system",
+		CreateNamespace: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inNamespace := func(o metav1.Object, ns string) runtime.Object {
+		o.SetNamespace(ns)
+		return o.(runtime.Object)
+	}
+	policies := testMakeKustomization("subscription-kustomization-policies", "policies", "subscription-testing-main")
+	policies.Spec.TargetNamespace = "web"
+	policies.Spec.SourceRef.Namespace = "flux-system"
+	want := []runtime.Object{
+		testMakeNamespace("apps"),
+		testMakeNamespace("web"),
+		testMakeNamespace("cache"),
+		inNamespace(testMakeGitRepository("subscription-testing-main", testProfileURL, branch("main")), "flux-system"),
+		inNamespace(testMakeHelmRepository("subscription-helm-repository-charts-bitnami-com-bitnami", testHelmRepoURL), "flux-system"),
+		inNamespace(testMakeHelmRelease("subscription-helm-release-test-chart",
+			gitRepositorySourceRef(testChartPath, "subscription-testing-main"),
+			targetNamespace("web"), sourceRefNamespace("flux-system")), "apps"),
+		inNamespace(testMakeHelmRelease("subscription-helm-release-redis",
+			helmRepositorySourceRef("redis", "12.10.0", "subscription-helm-repository-charts-bitnami-com-bitnami"),
+			targetNamespace("cache"), sourceRefNamespace("flux-system")), "apps"),
+		inNamespace(policies, "apps"),
+	}
+	if diff := cmp.Diff(want, o); diff != "" {
+		t.Fatalf("failed to make artifacts in namespaces:\n%s", diff)
+	}
+}
+
+func testMakeNamespace(name string) *corev1.Namespace {
+	return &corev1.Namespace{
+		TypeMeta:   metav1.TypeMeta{Kind: "Namespace", APIVersion: "v1"},
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+	}
+}
+
+func sourceRefNamespace(ns string) helmReleaseSpecFunc {
+	return func(o *helmv2beta1.HelmReleaseSpec) {
+		o.Chart.Spec.SourceRef.Namespace = ns
+	}
+}
+
+func dependsOn(names ...string) helmReleaseSpecFunc {
+	return func(o *helmv2beta1.HelmReleaseSpec) {
+		for _, n := range names {
+			o.DependsOn = append(o.DependsOn, dependency.CrossNamespaceDependencyReference{Name: n})
+		}
+	}
+}
+
+func TestMakeArtifacts_errors(t *testing.T) {
+	errorTests := []struct {
+		name    string
+		profile *Profile
+		wantErr string
+	}{
+		{
+			name:    "no artifacts",
+			profile: makeTestProfile(),
+			wantErr: "no artifacts found in profile",
+		},
+		{
+			name: "duplicate artifact names",
+			profile: makeTestProfile(
+				Artifact{Name: testChartname, Path: testChartPath},
+				Artifact{Name: testChartname, Path: "other/chart"},
+			),
+			wantErr: `duplicate artifact name "test-chart" in profile`,
+		},
+		{
+			name:    "no path, chart or kustomize",
+			profile: makeTestProfile(Artifact{Name: testChartname}),
+			wantErr: `artifact "test-chart" must have a path, a helm chart or a kustomize path`,
+		},
+		{
+			name: "unresolved nested profile",
+			profile: makeTestProfile(
+				Artifact{Name: "logging", Profile: &ProfileRef{URL: "https://example.com/testing/logging.git"}},
+			),
+			wantErr: `artifact "logging" is a nested profile, nested profiles must be resolved before making artifacts`,
+		},
+		{
+			name: "unknown dependency",
+			profile: makeTestProfile(
+				Artifact{Name: testChartname, Path: testChartPath, DependsOn: []string{"cert-manager"}},
+			),
+			wantErr: `artifact "test-chart" depends on unknown artifact "cert-manager"`,
+		},
+		{
+			name: "dependency cycle",
+			profile: makeTestProfile(
+				Artifact{Name: "first", Path: testChartPath, DependsOn: []string{"second"}},
+				Artifact{Name: "second", Path: testChartPath, DependsOn: []string{"third"}},
+				Artifact{Name: "third", Path: testChartPath, DependsOn: []string{"second"}},
+			),
+			wantErr: "dependency cycle detected: second -> third -> second",
+		},
+		{
+			name: "depends on itself",
+			profile: makeTestProfile(
+				Artifact{Name: testChartname, Path: testChartPath, DependsOn: []string{testChartname}},
+			),
+			wantErr: "dependency cycle detected: test-chart -> test-chart",
+		},
+		{
+			name: "helm release depends on kustomization",
+			profile: makeTestProfile(
+				Artifact{Name: "policies", Kustomize: &KustomizeSpec{Path: "policies"}},
+				Artifact{Name: testChartname, Path: testChartPath, DependsOn: []string{"policies"}},
+			),
+			wantErr: `artifact "test-chart" can't depend on artifact "policies", HelmReleases can only depend on HelmReleases and Kustomizations on Kustomizations`,
+		},
+		{
+			name: "invalid artifact namespace",
+			profile: makeTestProfile(
+				Artifact{Name: testChartname, Path: testChartPath, Namespace: "Web_Apps"},
+			),
+			wantErr: `invalid namespace for artifact "test-chart": invalid namespace "Web_Apps": a lowercase RFC 1123 label must consist of lower case alphanumeric characters or '-', and must start and end with an alphanumeric character (e.g. 'my-name',  or '123-abc', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?')`,
+		},
+		{
+			name: "helm repository without a host",
+			profile: makeTestProfile(
+				Artifact{Name: testChartname, Chart: &HelmChartSpec{Chart: "nginx", Repository: "bitnami"}},
+			),
+			wantErr: `invalid helm repository for artifact "test-chart": repository URL "bitnami" has no host`,
+		},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := MakeArtifacts(tt.profile, &ProfileOptions{
+				ProfileURL: testProfileURL,
+				Branch:     "main",
+			})
+			if msg := errorString(err); msg != tt.wantErr {
+				t.Fatalf("MakeArtifacts() got error %q, want %q", msg, tt.wantErr)
+			}
+		})
+	}
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
 func makeTestProfile(a ...Artifact) *Profile {
 	return &Profile{
 		Spec: ProfileSpec{
@@ -0,0 +1,276 @@
+package profiles
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+// ValuesError is returned when the values for artifacts don't match the
+// artifacts' values schemas.
+type ValuesError struct {
+	// Violations are the schema violations, each is prefixed with the
+	// artifact name and the JSON path to the invalid value.
+	Violations []string
+}
+
+func (e *ValuesError) Error() string {
+	return "values do not match the schema:\n  " + strings.Join(e.Violations, "\n  ")
+}
+
+// validateSchema checks the value against the schema, and returns a
+// description of every violation, prefixed with the JSON path of the value.
+//
+// This supports the subset of JSON schema that Kubernetes structural schemas
+// use to describe values: type, nullable, enum, properties, required,
+// additionalProperties, items, minItems, maxItems, minLength, maxLength,
+// pattern, minimum and maximum, Lint rejects schemas that use other keywords,
+// so that they're not silently ignored.
+func validateSchema(path string, s *apiextensionsv1.JSONSchemaProps, v interface{}) []string {
+	if v == nil {
+		if s.Type == "" || s.Nullable {
+			return nil
+		}
+		return []string{fmt.Sprintf("%s: must be of type %s, got null", path, s.Type)}
+	}
+	if s.Type != "" && !hasSchemaType(v, s.Type) {
+		return []string{fmt.Sprintf("%s: must be of type %s, got %s", path, s.Type, schemaTypeOf(v))}
+	}
+
+	violations := []string{}
+	if len(s.Enum) > 0 && !inEnum(v, s.Enum) {
+		violations = append(violations, fmt.Sprintf("%s: must be one of %s", path, enumString(s.Enum)))
+	}
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		violations = append(violations, validateObject(path, s, val)...)
+	case []interface{}:
+		if s.MinItems != nil && int64(len(val)) < *s.MinItems {
+			violations = append(violations, fmt.Sprintf("%s: must have at least %d items", path, *s.MinItems))
+		}
+		if s.MaxItems != nil && int64(len(val)) > *s.MaxItems {
+			violations = append(violations, fmt.Sprintf("%s: must have at most %d items", path, *s.MaxItems))
+		}
+		if s.Items != nil && s.Items.Schema != nil {
+			for i, item := range val {
+				violations = append(violations, validateSchema(fmt.Sprintf("%s[%d]", path, i), s.Items.Schema, item)...)
+			}
+		}
+	case string:
+		if s.MinLength != nil && int64(len(val)) < *s.MinLength {
+			violations = append(violations, fmt.Sprintf("%s: must be at least %d characters", path, *s.MinLength))
+		}
+		if s.MaxLength != nil && int64(len(val)) > *s.MaxLength {
+			violations = append(violations, fmt.Sprintf("%s: must be at most %d characters", path, *s.MaxLength))
+		}
+		if s.Pattern != "" {
+			re, err := regexp.Compile(s.Pattern)
+			if err != nil {
+				violations = append(violations, fmt.Sprintf("%s: invalid pattern %q in schema: %s", path, s.Pattern, err))
+			} else if !re.MatchString(val) {
+				violations = append(violations, fmt.Sprintf("%s: must match pattern %q", path, s.Pattern))
+			}
+		}
+	default:
+		if n, ok := toFloat(v); ok {
+			violations = append(violations, validateNumber(path, s, n)...)
+		}
+	}
+	return violations
+}
+
+// supportedSchemaKeywords are the keywords that validateSchema checks, and the
+// annotations that don't affect validation.
+var supportedSchemaKeywords = map[string]bool{
+	"type": true, "nullable": true, "enum": true, "properties": true,
+	"required": true, "additionalProperties": true, "items": true,
+	"minItems": true, "maxItems": true, "minLength": true, "maxLength": true,
+	"pattern": true, "minimum": true, "maximum": true, "exclusiveMinimum": true,
+	"exclusiveMaximum": true, "description": true, "title": true,
+	"default": true, "example": true, "externalDocs": true,
+}
+
+// unsupportedKeywords returns the paths of the keywords in the schema that
+// validateSchema doesn't support.
+func unsupportedKeywords(path string, s *apiextensionsv1.JSONSchemaProps) ([]string, error) {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(b, &schema); err != nil {
+		return nil, err
+	}
+	return findUnsupportedKeywords(path, schema), nil
+}
+
+func findUnsupportedKeywords(path string, schema map[string]interface{}) []string {
+	keys := []string{}
+	for k := range schema {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	found := []string{}
+	for _, k := range keys {
+		field := path + "." + k
+		if !supportedSchemaKeywords[k] {
+			found = append(found, field)
+			continue
+		}
+		switch k {
+		case "properties":
+			props, _ := schema[k].(map[string]interface{})
+			names := []string{}
+			for name := range props {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				if prop, ok := props[name].(map[string]interface{}); ok {
+					found = append(found, findUnsupportedKeywords(field+"."+name, prop)...)
+				}
+			}
+		case "items", "additionalProperties":
+			switch v := schema[k].(type) {
+			case map[string]interface{}:
+				found = append(found, findUnsupportedKeywords(field, v)...)
+			case []interface{}:
+				// Only a single schema for all the items is supported.
+				found = append(found, field)
+			}
+		}
+	}
+	return found
+}
+
+func validateObject(path string, s *apiextensionsv1.JSONSchemaProps, val map[string]interface{}) []string {
+	violations := []string{}
+	for _, r := range s.Required {
+		if _, ok := val[r]; !ok {
+			violations = append(violations, fmt.Sprintf("%s.%s: is required", path, r))
+		}
+	}
+	keys := []string{}
+	for k := range val {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if prop, ok := s.Properties[k]; ok {
+			violations = append(violations, validateSchema(path+"."+k, &prop, val[k])...)
+			continue
+		}
+		if s.AdditionalProperties == nil {
+			continue
+		}
+		if s.AdditionalProperties.Schema != nil {
+			violations = append(violations, validateSchema(path+"."+k, s.AdditionalProperties.Schema, val[k])...)
+			continue
+		}
+		if !s.AdditionalProperties.Allows {
+			violations = append(violations, fmt.Sprintf("%s.%s: is not a supported value", path, k))
+		}
+	}
+	return violations
+}
+
+func validateNumber(path string, s *apiextensionsv1.JSONSchemaProps, n float64) []string {
+	violations := []string{}
+	if s.Minimum != nil {
+		if s.ExclusiveMinimum && n <= *s.Minimum {
+			violations = append(violations, fmt.Sprintf("%s: must be greater than %v", path, *s.Minimum))
+		} else if n < *s.Minimum {
+			violations = append(violations, fmt.Sprintf("%s: must be greater than or equal to %v", path, *s.Minimum))
+		}
+	}
+	if s.Maximum != nil {
+		if s.ExclusiveMaximum && n >= *s.Maximum {
+			violations = append(violations, fmt.Sprintf("%s: must be less than %v", path, *s.Maximum))
+		} else if n > *s.Maximum {
+			violations = append(violations, fmt.Sprintf("%s: must be less than or equal to %v", path, *s.Maximum))
+		}
+	}
+	return violations
+}
+
+func hasSchemaType(v interface{}, t string) bool {
+	switch t {
+	case "integer":
+		n, ok := toFloat(v)
+		return ok && n == math.Trunc(n)
+	case "number":
+		_, ok := toFloat(v)
+		return ok
+	}
+	return schemaTypeOf(v) == t
+}
+
+func schemaTypeOf(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	}
+	if n, ok := toFloat(v); ok {
+		if n == math.Trunc(n) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// toFloat converts the numeric types that values can be parsed to, YAML
+// values are float64, and values set on the command-line are int64.
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func inEnum(v interface{}, enum []apiextensionsv1.JSON) bool {
+	for _, e := range enum {
+		var ev interface{}
+		if err := json.Unmarshal(e.Raw, &ev); err != nil {
+			continue
+		}
+		if n, ok := toFloat(v); ok {
+			if en, ok := ev.(float64); ok && en == n {
+				return true
+			}
+			continue
+		}
+		if reflect.DeepEqual(v, ev) {
+			return true
+		}
+	}
+	return false
+}
+
+func enumString(enum []apiextensionsv1.JSON) string {
+	s := []string{}
+	for _, e := range enum {
+		s = append(s, string(e.Raw))
+	}
+	return strings.Join(s, ", ")
+}
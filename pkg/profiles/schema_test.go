@@ -0,0 +1,126 @@
+package profiles
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func TestMakeArtifacts_default_values(t *testing.T) {
+	p, err := ParseBytes(mustRead(t, "testdata/profile_with_values.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := MakeArtifacts(p, &ProfileOptions{
+		ProfileURL: testProfileURL,
+		Branch:     "main",
+		Values: map[string]*ReleaseValues{
+			AllArtifacts:   {Values: map[string]interface{}{"commonLabels": map[string]interface{}{"team": "web"}}},
+			"nginx-server": {Values: map[string]interface{}{"replicaCount": int64(3), "service": map[string]interface{}{"type": "NodePort"}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]string{}
+	for _, obj := range o {
+		if hr, ok := obj.(interface{ GetValues() map[string]interface{} }); ok {
+			b, err := json.Marshal(hr.GetValues())
+			if err != nil {
+				t.Fatal(err)
+			}
+			got[obj.(interface{ GetName() string }).GetName()] = string(b)
+		}
+	}
+	want := map[string]string{
+		"subscription-helm-release-nginx-server": `{"commonLabels":{"team":"web"},"replicaCount":3,"service":{"port":80,"type":"NodePort"}}`,
+		"subscription-helm-release-redis-server": `{"commonLabels":{"team":"web"},"password":""}`,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("failed to merge default values:\n%s", diff)
+	}
+}
+
+func TestMakeArtifacts_values_schema_violations(t *testing.T) {
+	p, err := ParseBytes(mustRead(t, "testdata/profile_with_values.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = MakeArtifacts(p, &ProfileOptions{
+		ProfileURL: testProfileURL,
+		Branch:     "main",
+		Values: map[string]*ReleaseValues{
+			"nginx-server": {Values: map[string]interface{}{
+				"replicaCount": int64(10),
+				"service":      map[string]interface{}{"type": "ExternalName", "port": "http", "name": "web"},
+			}},
+			"redis-server": {Values: map[string]interface{}{"password": "a-very-long-password"}},
+		},
+	})
+	want := &ValuesError{
+		Violations: []string{
+			`artifact "nginx-server": $.replicaCount: must be less than or equal to 5`,
+			`artifact "nginx-server": $.service.name: is not a supported value`,
+			`artifact "nginx-server": $.service.port: must be of type integer, got string`,
+			`artifact "nginx-server": $.service.type: must be one of "ClusterIP", "NodePort", "LoadBalancer"`,
+			`artifact "redis-server": $.password: must be at most 16 characters`,
+		},
+	}
+	if diff := cmp.Diff(want, err); diff != "" {
+		t.Fatalf("failed to validate values:\n%s", diff)
+	}
+}
+
+func TestValidateSchema(t *testing.T) {
+	schemaTests := []struct {
+		name   string
+		schema string
+		value  interface{}
+		want   []string
+	}{
+		{"matching type", `type: string`, "test", nil},
+		{"wrong type", `type: string`, true, []string{"$: must be of type string, got boolean"}},
+		{"integer from yaml", `type: integer`, float64(2), nil},
+		{"number is not an integer", `type: integer`, 2.5, []string{"$: must be of type integer, got number"}},
+		{"null", `type: string`, nil, []string{"$: must be of type string, got null"}},
+		{"nullable", "type: string\nnullable: true", nil, nil},
+		{"required", "type: object\nrequired: [name]", map[string]interface{}{}, []string{"$.name: is required"}},
+		{
+			"additional properties schema",
+			"type: object\nadditionalProperties:\n  type: string",
+			map[string]interface{}{"a": "b", "c": int64(1)},
+			[]string{"$.c: must be of type string, got integer"},
+		},
+		{
+			"array items",
+			"type: array\nmaxItems: 2\nitems:\n  type: string",
+			[]interface{}{"a", int64(1), "c"},
+			[]string{"$: must have at most 2 items", "$[1]: must be of type string, got integer"},
+		},
+		{"pattern", "type: string\npattern: ^[a-z]+$", "ABC", []string{`$: must match pattern "^[a-z]+$"`}},
+		{"exclusive minimum", "type: number\nminimum: 1\nexclusiveMinimum: true", int64(1), []string{"$: must be greater than 1"}},
+		{"enum numbers", "enum: [80, 443]", int64(443), nil},
+	}
+
+	for _, tt := range schemaTests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &apiextensionsv1.JSONSchemaProps{}
+			if err := yaml.Unmarshal([]byte(tt.schema), s); err != nil {
+				t.Fatal(err)
+			}
+			got := validateSchema("$", s, tt.value)
+			if len(got) == 0 {
+				got = nil
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatalf("failed to validate:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+// Package v1alpha1 contains the v1alpha1 version of the Profile API.
+//
+// In this version, Helm charts in the Profile repo are artifacts with a path,
+// and Helm charts from chart repositories are artifacts with a helm chart.
+package v1alpha1
+
+import (
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// APIVersion is the apiVersion of Profiles in this version.
+const APIVersion = "profiles.fluxcd.io/v1alpha1"
+
+// Profile is the Schema for the profiles API
+type Profile struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ProfileSpec `json:"spec,omitempty"`
+}
+
+// ProfileSpec defines the desired state of Profile
+type ProfileSpec struct {
+	// Description is some text to allow a user to identify what this profile installs.
+	Description string `json:"description,omitempty"`
+	// Version is the semantic version of the Profile e.g. v0.1.0.
+	Version string `json:"version,omitempty"`
+	// Artifacts is a list of Profile artifacts, each artifact is installed
+	// with its own HelmRelease or Kustomization.
+	Artifacts []Artifact `json:"artifacts,omitempty"`
+	// Parameters are the parameters that can be provided when the Profile is
+	// installed.
+	Parameters []Parameter `json:"parameters,omitempty"`
+}
+
+// Parameter is a named value that is provided when the Profile is installed.
+type Parameter struct {
+	Name        string      `json:"name"`
+	Type        string      `json:"type,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Default     interface{} `json:"default,omitempty"`
+	Required    bool        `json:"required,omitempty"`
+}
+
+// Artifact is a component of the Profile.
+type Artifact struct {
+	// Name is the name of the Artifact
+	Name string `json:"name,omitempty"`
+	// Path is the local path to a Helm chart in the Profile repo
+	Path string `json:"path,omitempty"`
+	// Namespace is the namespace that the artifact is deployed to.
+	Namespace string `json:"namespace,omitempty"`
+	// Chart is a spec for creating a HelmRelease/HelmRepository combination
+	Chart *HelmChartSpec `json:"helm,omitempty"`
+	// Kustomize is a spec for creating a Kustomization from a path in the
+	// Profile repo.
+	Kustomize *KustomizeSpec `json:"kustomize,omitempty"`
+	// Values are the default Helm values for the artifact.
+	Values map[string]interface{} `json:"values,omitempty"`
+	// ValuesSchema is an optional JSON schema for the values.
+	ValuesSchema *apiextensionsv1.JSONSchemaProps `json:"valuesSchema,omitempty"`
+}
+
+// KustomizeSpec allows the installation of a directory of manifests with a
+// kustomization.yaml from the Profile repo.
+type KustomizeSpec struct {
+	Path string `json:"path"`
+}
+
+// HelmChartSpec allows the installation of a HelmChart from a Helm chart
+// server.
+type HelmChartSpec struct {
+	Chart      string `json:"chart"`
+	Repository string `json:"repository"`
+	Version    string `json:"version"`
+}
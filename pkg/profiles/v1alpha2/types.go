@@ -0,0 +1,93 @@
+// Package v1alpha2 contains the v1alpha2 version of the Profile API.
+//
+// In this version, all Helm charts are helm artifacts, either with a path to
+// the chart in the Profile repo, or a chart from a chart repository.
+package v1alpha2
+
+import (
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// APIVersion is the apiVersion of Profiles in this version.
+const APIVersion = "profiles.fluxcd.io/v1alpha2"
+
+// Profile is the Schema for the profiles API
+type Profile struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ProfileSpec `json:"spec,omitempty"`
+}
+
+// ProfileSpec defines the desired state of Profile
+type ProfileSpec struct {
+	// Description is some text to allow a user to identify what this profile installs.
+	Description string `json:"description,omitempty"`
+	// Version is the semantic version of the Profile e.g. v0.1.0.
+	Version string `json:"version,omitempty"`
+	// Artifacts is a list of Profile artifacts, each artifact is installed
+	// with its own HelmRelease or Kustomization.
+	Artifacts []Artifact `json:"artifacts,omitempty"`
+	// Parameters are the parameters that can be provided when the Profile is
+	// installed.
+	Parameters []Parameter `json:"parameters,omitempty"`
+}
+
+// Parameter is a named value that is provided when the Profile is installed.
+type Parameter struct {
+	Name        string      `json:"name"`
+	Type        string      `json:"type,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Default     interface{} `json:"default,omitempty"`
+	Required    bool        `json:"required,omitempty"`
+}
+
+// Artifact is a component of the Profile, with either a Helm chart or a
+// Kustomize path.
+type Artifact struct {
+	// Name is the name of the Artifact
+	Name string `json:"name,omitempty"`
+	// Namespace is the namespace that the artifact is deployed to.
+	Namespace string `json:"namespace,omitempty"`
+	// Helm is a Helm chart that is installed with a HelmRelease.
+	Helm *HelmSpec `json:"helm,omitempty"`
+	// Kustomize is a spec for creating a Kustomization from a path in the
+	// Profile repo.
+	Kustomize *KustomizeSpec `json:"kustomize,omitempty"`
+	// Values are the default Helm values for the artifact.
+	Values map[string]interface{} `json:"values,omitempty"`
+	// ValuesSchema is an optional JSON schema for the values.
+	ValuesSchema *apiextensionsv1.JSONSchemaProps `json:"valuesSchema,omitempty"`
+	// Profile is another Profile that is installed as part of this Profile.
+	Profile *ProfileRef `json:"profile,omitempty"`
+	// DependsOn is the names of the artifacts that this artifact depends on.
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+// ProfileRef references a Profile in another Profile repo.
+type ProfileRef struct {
+	URL     string            `json:"url"`
+	Branch  string            `json:"branch,omitempty"`
+	Tag     string            `json:"tag,omitempty"`
+	Commit  string            `json:"commit,omitempty"`
+	Version string            `json:"version,omitempty"`
+	Params  map[string]string `json:"params,omitempty"`
+}
+
+// HelmSpec is a Helm chart, either at a path in the Profile repo, or in a
+// chart repository.
+type HelmSpec struct {
+	// Path is the local path to the chart in the Profile repo.
+	Path string `json:"path,omitempty"`
+	// Chart is the name of the chart in the Repository.
+	Chart      string `json:"chart,omitempty"`
+	Repository string `json:"repository,omitempty"`
+	Version    string `json:"version,omitempty"`
+}
+
+// KustomizeSpec allows the installation of a directory of manifests with a
+// kustomization.yaml from the Profile repo.
+type KustomizeSpec struct {
+	Path string `json:"path"`
+}
@@ -0,0 +1,132 @@
+package profiles
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	helmv2beta1 "github.com/fluxcd/helm-controller/api/v2beta1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+// AllArtifacts is the key in ProfileOptions.Values for values that apply to
+// every Helm artifact in the Profile.
+const AllArtifacts = ""
+
+// ReleaseValues are the values for the HelmRelease for an artifact.
+type ReleaseValues struct {
+	// Values are set in the HelmRelease, and override the chart's defaults.
+	Values map[string]interface{}
+	// Set are values that are merged over the Values for all artifacts and
+	// for the artifact, like helm's --set, which always takes precedence over
+	// values files.
+	Set map[string]interface{}
+	// ValuesFrom are references to ConfigMaps and Secrets with values.
+	ValuesFrom []helmv2beta1.ValuesReference
+}
+
+// MergeValues merges the src values into dst, nested maps are merged, and
+// other values in src replace the values in dst.
+//
+// Maps are copied from src so that later merges into dst don't modify src.
+func MergeValues(dst, src map[string]interface{}) map[string]interface{} {
+	if dst == nil {
+		dst = map[string]interface{}{}
+	}
+	for k, v := range src {
+		srcMap, ok := v.(map[string]interface{})
+		if !ok {
+			dst[k] = v
+			continue
+		}
+		dstMap, _ := dst[k].(map[string]interface{})
+		dst[k] = MergeValues(dstMap, srcMap)
+	}
+	return dst
+}
+
+// validateValues checks that the values only target Helm artifacts in the
+// Profile.
+func validateValues(p *Profile, values map[string]*ReleaseValues) error {
+	artifacts := map[string]Artifact{}
+	for _, a := range p.Spec.Artifacts {
+		artifacts[a.Name] = a
+		if a.Kustomize != nil && (a.Values != nil || a.ValuesSchema != nil) {
+			return fmt.Errorf("artifact %q has values but is not a helm release", a.Name)
+		}
+	}
+	names := []string{}
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == AllArtifacts {
+			continue
+		}
+		a, ok := artifacts[name]
+		if !ok {
+			return fmt.Errorf("values provided for unknown artifact %q", name)
+		}
+		if a.Kustomize != nil {
+			return fmt.Errorf("values provided for artifact %q which is not a helm release", name)
+		}
+	}
+	return nil
+}
+
+// applyValues sets the values for the artifact in the HelmRelease, the
+// values for all artifacts are merged over the artifact's default values, and
+// the values for the artifact are merged over these, the Set values are then
+// merged in the same order, so they take precedence over all the Values.
+//
+// If the artifact has a values schema, the merged values are validated, values
+// from ConfigMaps and Secrets can't be validated as they're only available in
+// the cluster.
+func applyValues(hr *helmv2beta1.HelmRelease, a Artifact, opts *ProfileOptions) error {
+	values := MergeValues(nil, a.Values)
+	releaseValues := []*ReleaseValues{}
+	for _, name := range []string{AllArtifacts, a.Name} {
+		if v, ok := opts.Values[name]; ok && v != nil {
+			releaseValues = append(releaseValues, v)
+		}
+	}
+	for _, v := range releaseValues {
+		values = MergeValues(values, v.Values)
+		hr.Spec.ValuesFrom = append(hr.Spec.ValuesFrom, v.ValuesFrom...)
+	}
+	for _, v := range releaseValues {
+		values = MergeValues(values, v.Set)
+	}
+	if a.ValuesSchema != nil {
+		violations := validateSchema("$", a.ValuesSchema, values)
+		if len(violations) > 0 {
+			for i := range violations {
+				violations[i] = fmt.Sprintf("artifact %q: %s", a.Name, violations[i])
+			}
+			return &ValuesError{Violations: violations}
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	b, err := json.Marshal(values)
+	if err != nil {
+		return fmt.Errorf("failed to marshal values for artifact %q: %w", a.Name, err)
+	}
+	hr.Spec.Values = &apiextensionsv1.JSON{Raw: b}
+	return nil
+}
+
+// collectValuesErrors adds the violations from a ValuesError to the
+// collected errors so that they can all be reported, other errors are
+// returned.
+func collectValuesErrors(collected *ValuesError, err error) error {
+	var valuesErr *ValuesError
+	if errors.As(err, &valuesErr) {
+		collected.Violations = append(collected.Violations, valuesErr.Violations...)
+		return nil
+	}
+	return err
+}
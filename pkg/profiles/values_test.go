@@ -0,0 +1,170 @@
+package profiles
+
+import (
+	"testing"
+
+	helmv2beta1 "github.com/fluxcd/helm-controller/api/v2beta1"
+	"github.com/google/go-cmp/cmp"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestMakeArtifacts_values(t *testing.T) {
+	profile := makeTestProfile(
+		Artifact{Name: "nginx-server", Path: "nginx/chart"},
+		Artifact{Name: "redis-server", Chart: &HelmChartSpec{Chart: "redis", Repository: testHelmRepoURL, Version: "12.10.0"}},
+		Artifact{Name: "policies", Kustomize: &KustomizeSpec{Path: "policies/base"}},
+	)
+	values := map[string]*ReleaseValues{
+		AllArtifacts: {
+			Values:     map[string]interface{}{"replicaCount": 2, "service": map[string]interface{}{"type": "ClusterIP", "port": 80}},
+			ValuesFrom: []helmv2beta1.ValuesReference{{Kind: "ConfigMap", Name: "shared-values"}},
+		},
+		"nginx-server": {
+			Values:     map[string]interface{}{"service": map[string]interface{}{"port": 8080}},
+			ValuesFrom: []helmv2beta1.ValuesReference{{Kind: "Secret", Name: "nginx-values", ValuesKey: "nginx.yaml"}},
+		},
+	}
+
+	o, err := MakeArtifacts(profile, &ProfileOptions{
+		ProfileURL: testProfileURL,
+		Branch:     "main",
+		Values:     values,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []runtime.Object{
+		testMakeGitRepository("subscription-testing-main", testProfileURL, branch("main")),
+		testMakeHelmRepository("subscription-helm-repository-charts-bitnami-com-bitnami", testHelmRepoURL),
+		testMakeHelmRelease("subscription-helm-release-nginx-server",
+			gitRepositorySourceRef("nginx/chart", "subscription-testing-main"),
+			releaseValues(`{"replicaCount":2,"service":{"port":8080,"type":"ClusterIP"}}`,
+				helmv2beta1.ValuesReference{Kind: "ConfigMap", Name: "shared-values"},
+				helmv2beta1.ValuesReference{Kind: "Secret", Name: "nginx-values", ValuesKey: "nginx.yaml"})),
+		testMakeHelmRelease("subscription-helm-release-redis-server",
+			helmRepositorySourceRef("redis", "12.10.0", "subscription-helm-repository-charts-bitnami-com-bitnami"),
+			releaseValues(`{"replicaCount":2,"service":{"port":80,"type":"ClusterIP"}}`,
+				helmv2beta1.ValuesReference{Kind: "ConfigMap", Name: "shared-values"})),
+		testMakeKustomization("subscription-kustomization-policies", "policies/base", "subscription-testing-main"),
+	}
+	if diff := cmp.Diff(want, o); diff != "" {
+		t.Fatalf("failed to make artifacts:\n%s", diff)
+	}
+	if diff := cmp.Diff(map[string]interface{}{"replicaCount": 2, "service": map[string]interface{}{"type": "ClusterIP", "port": 80}}, values[AllArtifacts].Values); diff != "" {
+		t.Fatalf("values were modified:\n%s", diff)
+	}
+}
+
+func TestMakeArtifacts_values_set_takes_precedence(t *testing.T) {
+	profile := makeTestProfile(Artifact{Name: "nginx-server", Path: "nginx/chart"})
+	values := map[string]*ReleaseValues{
+		AllArtifacts: {
+			Set: map[string]interface{}{"replicaCount": 3},
+		},
+		"nginx-server": {
+			Values: map[string]interface{}{"replicaCount": 2, "image": map[string]interface{}{"tag": "1.19"}},
+		},
+	}
+
+	o, err := MakeArtifacts(profile, &ProfileOptions{
+		ProfileURL: testProfileURL,
+		Branch:     "main",
+		Values:     values,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := testMakeHelmRelease("subscription-helm-release-nginx-server",
+		gitRepositorySourceRef("nginx/chart", "subscription-testing-main"),
+		releaseValues(`{"image":{"tag":"1.19"},"replicaCount":3}`))
+	if diff := cmp.Diff(want, o[1]); diff != "" {
+		t.Fatalf("failed to make artifacts:\n%s", diff)
+	}
+}
+
+func TestMakeArtifacts_values_errors(t *testing.T) {
+	errorTests := []struct {
+		name    string
+		values  map[string]*ReleaseValues
+		wantErr string
+	}{
+		{
+			name:    "unknown artifact",
+			values:  map[string]*ReleaseValues{"unknown": {Values: map[string]interface{}{"a": "b"}}},
+			wantErr: `values provided for unknown artifact "unknown"`,
+		},
+		{
+			name:    "kustomize artifact",
+			values:  map[string]*ReleaseValues{"policies": {Values: map[string]interface{}{"a": "b"}}},
+			wantErr: `values provided for artifact "policies" which is not a helm release`,
+		},
+	}
+
+	profile := makeTestProfile(
+		Artifact{Name: "nginx-server", Path: "nginx/chart"},
+		Artifact{Name: "policies", Kustomize: &KustomizeSpec{Path: "policies/base"}},
+	)
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := MakeArtifacts(profile, &ProfileOptions{
+				ProfileURL: testProfileURL,
+				Branch:     "main",
+				Values:     tt.values,
+			})
+			if msg := errorString(err); msg != tt.wantErr {
+				t.Fatalf("MakeArtifacts() got error %q, want %q", msg, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMergeValues(t *testing.T) {
+	mergeTests := []struct {
+		name string
+		dst  map[string]interface{}
+		src  map[string]interface{}
+		want map[string]interface{}
+	}{
+		{
+			name: "nil destination",
+			src:  map[string]interface{}{"a": "b"},
+			want: map[string]interface{}{"a": "b"},
+		},
+		{
+			name: "nested maps are merged",
+			dst:  map[string]interface{}{"service": map[string]interface{}{"type": "ClusterIP", "port": 80}},
+			src:  map[string]interface{}{"service": map[string]interface{}{"port": 8080}},
+			want: map[string]interface{}{"service": map[string]interface{}{"type": "ClusterIP", "port": 8080}},
+		},
+		{
+			name: "values replace maps",
+			dst:  map[string]interface{}{"service": map[string]interface{}{"port": 80}},
+			src:  map[string]interface{}{"service": "disabled"},
+			want: map[string]interface{}{"service": "disabled"},
+		},
+		{
+			name: "lists are replaced",
+			dst:  map[string]interface{}{"args": []interface{}{"a", "b"}},
+			src:  map[string]interface{}{"args": []interface{}{"c"}},
+			want: map[string]interface{}{"args": []interface{}{"c"}},
+		},
+	}
+
+	for _, tt := range mergeTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, MergeValues(tt.dst, tt.src)); diff != "" {
+				t.Fatalf("failed to merge values:\n%s", diff)
+			}
+		})
+	}
+}
+
+func releaseValues(raw string, refs ...helmv2beta1.ValuesReference) helmReleaseSpecFunc {
+	return func(o *helmv2beta1.HelmReleaseSpec) {
+		o.Values = &apiextensionsv1.JSON{Raw: []byte(raw)}
+		o.ValuesFrom = refs
+	}
+}
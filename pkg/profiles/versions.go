@@ -0,0 +1,261 @@
+package profiles
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/bigkevmcd/askja/pkg/profiles/v1alpha1"
+	"github.com/bigkevmcd/askja/pkg/profiles/v1alpha2"
+)
+
+const profileGroup = "profiles.fluxcd.io"
+
+// LatestAPIVersion is the newest version of the Profile API, new Profiles are
+// written in this version.
+const LatestAPIVersion = v1alpha2.APIVersion
+
+// profileVersion converts between a version of the Profile API and Profile,
+// which is the internal type that all versions are converted to.
+type profileVersion struct {
+	// newVersioned returns a pointer to an empty Profile of the version to
+	// decode into.
+	newVersioned func() interface{}
+	toInternal   func(interface{}) *Profile
+	// fromInternal returns an error if the Profile uses fields that are not
+	// in the version.
+	fromInternal func(*Profile) (interface{}, error)
+	// validate returns the problems in the versioned Profile that are lost
+	// when it's converted to a Profile, the problems have no lines.
+	validate func(interface{}) []Problem
+	// artifactKinds describes the fields that make the kind of an artifact
+	// in this version.
+	artifactKinds string
+}
+
+var profileVersions = map[string]profileVersion{
+	v1alpha1.APIVersion: {
+		newVersioned:  func() interface{} { return &v1alpha1.Profile{} },
+		toInternal:    func(v interface{}) *Profile { return fromV1alpha1(v.(*v1alpha1.Profile)) },
+		fromInternal:  func(p *Profile) (interface{}, error) { return toV1alpha1(p) },
+		validate:      func(interface{}) []Problem { return nil },
+		artifactKinds: "path, helm or kustomize",
+	},
+	v1alpha2.APIVersion: {
+		newVersioned:  func() interface{} { return &v1alpha2.Profile{} },
+		toInternal:    func(v interface{}) *Profile { return fromV1alpha2(v.(*v1alpha2.Profile)) },
+		fromInternal:  func(p *Profile) (interface{}, error) { return toV1alpha2(p), nil },
+		validate:      func(v interface{}) []Problem { return validateV1alpha2(v.(*v1alpha2.Profile)) },
+		artifactKinds: "helm.path, helm.chart, kustomize or profile",
+	},
+}
+
+// ResolveAPIVersion returns the Profile apiVersion for a version, which can be
+// the full apiVersion e.g. profiles.fluxcd.io/v1alpha2 or just the version
+// e.g. v1alpha2.
+func ResolveAPIVersion(version string) (string, error) {
+	if !strings.Contains(version, "/") {
+		version = profileGroup + "/" + version
+	}
+	if _, ok := profileVersions[version]; !ok {
+		return "", unsupportedVersionError(version)
+	}
+	return version, nil
+}
+
+// Marshal returns the Profile as YAML in the apiVersion of the Profile.
+func Marshal(p *Profile) ([]byte, error) {
+	v, ok := profileVersions[p.APIVersion]
+	if !ok {
+		return nil, unsupportedVersionError(p.APIVersion)
+	}
+	versioned, err := v.fromInternal(p)
+	if err != nil {
+		return nil, err
+	}
+	b, err := yaml.Marshal(versioned)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal profile: %w", err)
+	}
+	return b, nil
+}
+
+func unsupportedVersionError(version string) error {
+	return fmt.Errorf("unsupported apiVersion %q, must be one of %s", version, strings.Join(supportedVersions(), ", "))
+}
+
+func supportedVersions() []string {
+	versions := []string{}
+	for k := range profileVersions {
+		versions = append(versions, k)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+func fromV1alpha1(in *v1alpha1.Profile) *Profile {
+	out := &Profile{
+		TypeMeta:   in.TypeMeta,
+		ObjectMeta: in.ObjectMeta,
+		Spec: ProfileSpec{
+			Description: in.Spec.Description,
+			Version:     in.Spec.Version,
+		},
+	}
+	for _, p := range in.Spec.Parameters {
+		out.Spec.Parameters = append(out.Spec.Parameters, Parameter(p))
+	}
+	for _, a := range in.Spec.Artifacts {
+		artifact := Artifact{
+			Name:         a.Name,
+			Path:         a.Path,
+			Namespace:    a.Namespace,
+			Values:       a.Values,
+			ValuesSchema: a.ValuesSchema,
+		}
+		if a.Chart != nil {
+			artifact.Chart = &HelmChartSpec{Chart: a.Chart.Chart, Repository: a.Chart.Repository, Version: a.Chart.Version}
+		}
+		if a.Kustomize != nil {
+			artifact.Kustomize = &KustomizeSpec{Path: a.Kustomize.Path}
+		}
+		out.Spec.Artifacts = append(out.Spec.Artifacts, artifact)
+	}
+	return out
+}
+
+// toV1alpha1 returns an error if the Profile has profile artifacts or
+// dependencies, which were added in v1alpha2.
+func toV1alpha1(in *Profile) (*v1alpha1.Profile, error) {
+	out := &v1alpha1.Profile{
+		TypeMeta:   in.TypeMeta,
+		ObjectMeta: in.ObjectMeta,
+		Spec: v1alpha1.ProfileSpec{
+			Description: in.Spec.Description,
+			Version:     in.Spec.Version,
+		},
+	}
+	out.APIVersion = v1alpha1.APIVersion
+	for _, p := range in.Spec.Parameters {
+		out.Spec.Parameters = append(out.Spec.Parameters, v1alpha1.Parameter(p))
+	}
+	for _, a := range in.Spec.Artifacts {
+		if a.Profile != nil {
+			return nil, fmt.Errorf("artifact %q is a profile, which is not supported in %s", a.Name, v1alpha1.APIVersion)
+		}
+		if len(a.DependsOn) > 0 {
+			return nil, fmt.Errorf("artifact %q has dependsOn, which is not supported in %s", a.Name, v1alpha1.APIVersion)
+		}
+		artifact := v1alpha1.Artifact{
+			Name:         a.Name,
+			Path:         a.Path,
+			Namespace:    a.Namespace,
+			Values:       a.Values,
+			ValuesSchema: a.ValuesSchema,
+		}
+		if a.Chart != nil {
+			artifact.Chart = &v1alpha1.HelmChartSpec{Chart: a.Chart.Chart, Repository: a.Chart.Repository, Version: a.Chart.Version}
+		}
+		if a.Kustomize != nil {
+			artifact.Kustomize = &v1alpha1.KustomizeSpec{Path: a.Kustomize.Path}
+		}
+		out.Spec.Artifacts = append(out.Spec.Artifacts, artifact)
+	}
+	return out, nil
+}
+
+func fromV1alpha2(in *v1alpha2.Profile) *Profile {
+	out := &Profile{
+		TypeMeta:   in.TypeMeta,
+		ObjectMeta: in.ObjectMeta,
+		Spec: ProfileSpec{
+			Description: in.Spec.Description,
+			Version:     in.Spec.Version,
+		},
+	}
+	for _, p := range in.Spec.Parameters {
+		out.Spec.Parameters = append(out.Spec.Parameters, Parameter(p))
+	}
+	for _, a := range in.Spec.Artifacts {
+		artifact := Artifact{
+			Name:         a.Name,
+			Namespace:    a.Namespace,
+			Values:       a.Values,
+			ValuesSchema: a.ValuesSchema,
+			DependsOn:    a.DependsOn,
+		}
+		if a.Helm != nil {
+			artifact.Path = a.Helm.Path
+			if a.Helm.Path == "" || a.Helm.Chart != "" || a.Helm.Repository != "" {
+				artifact.Chart = &HelmChartSpec{Chart: a.Helm.Chart, Repository: a.Helm.Repository, Version: a.Helm.Version}
+			}
+		}
+		if a.Kustomize != nil {
+			artifact.Kustomize = &KustomizeSpec{Path: a.Kustomize.Path}
+		}
+		if a.Profile != nil {
+			ref := ProfileRef(*a.Profile)
+			artifact.Profile = &ref
+		}
+		out.Spec.Artifacts = append(out.Spec.Artifacts, artifact)
+	}
+	return out
+}
+
+// validateV1alpha2 reports a helm.version for a chart in the Profile repo,
+// the version is in the chart, so it's dropped when converting.
+func validateV1alpha2(in *v1alpha2.Profile) []Problem {
+	problems := []Problem{}
+	for i, a := range in.Spec.Artifacts {
+		if a.Helm != nil && a.Helm.Path != "" && a.Helm.Chart == "" && a.Helm.Repository == "" && a.Helm.Version != "" {
+			problems = append(problems, Problem{
+				Field:   fmt.Sprintf("spec.artifacts[%d].helm.version", i),
+				Message: "version is only supported for charts from a repository, the version of a chart in the profile repo is in its Chart.yaml",
+			})
+		}
+	}
+	return problems
+}
+
+func toV1alpha2(in *Profile) *v1alpha2.Profile {
+	out := &v1alpha2.Profile{
+		TypeMeta:   in.TypeMeta,
+		ObjectMeta: in.ObjectMeta,
+		Spec: v1alpha2.ProfileSpec{
+			Description: in.Spec.Description,
+			Version:     in.Spec.Version,
+		},
+	}
+	out.APIVersion = v1alpha2.APIVersion
+	for _, p := range in.Spec.Parameters {
+		out.Spec.Parameters = append(out.Spec.Parameters, v1alpha2.Parameter(p))
+	}
+	for _, a := range in.Spec.Artifacts {
+		artifact := v1alpha2.Artifact{
+			Name:         a.Name,
+			Namespace:    a.Namespace,
+			Values:       a.Values,
+			ValuesSchema: a.ValuesSchema,
+			DependsOn:    a.DependsOn,
+		}
+		if a.Path != "" || a.Chart != nil {
+			artifact.Helm = &v1alpha2.HelmSpec{Path: a.Path}
+		}
+		if a.Chart != nil {
+			artifact.Helm.Chart = a.Chart.Chart
+			artifact.Helm.Repository = a.Chart.Repository
+			artifact.Helm.Version = a.Chart.Version
+		}
+		if a.Kustomize != nil {
+			artifact.Kustomize = &v1alpha2.KustomizeSpec{Path: a.Kustomize.Path}
+		}
+		if a.Profile != nil {
+			ref := v1alpha2.ProfileRef(*a.Profile)
+			artifact.Profile = &ref
+		}
+		out.Spec.Artifacts = append(out.Spec.Artifacts, artifact)
+	}
+	return out
+}
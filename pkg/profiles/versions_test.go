@@ -0,0 +1,150 @@
+package profiles
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseBytes_v1alpha2(t *testing.T) {
+	p, err := ParseBytes(mustRead(t, "testdata/profile_v1alpha2.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(testVersionedProfile("profiles.fluxcd.io/v1alpha2"), p); diff != "" {
+		t.Fatalf("failed to parse profile:\n%s", diff)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	for _, v := range []string{"profiles.fluxcd.io/v1alpha1", "profiles.fluxcd.io/v1alpha2"} {
+		t.Run(v, func(t *testing.T) {
+			want := testVersionedProfile(v)
+			b, err := Marshal(want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err := ParseBytes(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(want, p); diff != "" {
+				t.Fatalf("failed to round-trip profile:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMarshal_converts(t *testing.T) {
+	p := testVersionedProfile("profiles.fluxcd.io/v1alpha2")
+	p.APIVersion = "profiles.fluxcd.io/v1alpha1"
+	b, err := Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `apiVersion: profiles.fluxcd.io/v1alpha1
+kind: Profile
+metadata:
+  creationTimestamp: null
+  name: nginx
+spec:
+  artifacts:
+  - name: nginx-server
+    path: nginx/chart
+  - helm:
+      chart: redis
+      repository: https://charts.bitnami.com/bitnami
+      version: 12.10.0
+    name: redis-server
+  - kustomize:
+      path: policies/base
+    name: policies
+  description: Profile for deploying nginx
+  version: v0.0.1
+`
+	if diff := cmp.Diff(want, string(b)); diff != "" {
+		t.Fatalf("failed to convert profile:\n%s", diff)
+	}
+}
+
+func TestMarshal_unsupported_version(t *testing.T) {
+	p := testVersionedProfile("profiles.fluxcd.io/v2")
+	_, err := Marshal(p)
+	want := `unsupported apiVersion "profiles.fluxcd.io/v2", must be one of profiles.fluxcd.io/v1alpha1, profiles.fluxcd.io/v1alpha2`
+	if msg := errorString(err); msg != want {
+		t.Fatalf("Marshal() got error %q, want %q", msg, want)
+	}
+}
+
+func TestMarshal_v1alpha2_fields(t *testing.T) {
+	fieldTests := []struct {
+		name     string
+		artifact Artifact
+		wantErr  string
+	}{
+		{
+			name:     "profile artifact",
+			artifact: Artifact{Name: "logging", Profile: &ProfileRef{URL: "https://example.com/testing/logging.git"}},
+			wantErr:  `artifact "logging" is a profile, which is not supported in profiles.fluxcd.io/v1alpha1`,
+		},
+		{
+			name:     "dependsOn",
+			artifact: Artifact{Name: "ingress", Path: "ingress/chart", DependsOn: []string{"nginx-server"}},
+			wantErr:  `artifact "ingress" has dependsOn, which is not supported in profiles.fluxcd.io/v1alpha1`,
+		},
+	}
+
+	for _, tt := range fieldTests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testVersionedProfile("profiles.fluxcd.io/v1alpha1")
+			p.Spec.Artifacts = append(p.Spec.Artifacts, tt.artifact)
+			_, err := Marshal(p)
+			if msg := errorString(err); msg != tt.wantErr {
+				t.Fatalf("Marshal() got error %q, want %q", msg, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolveAPIVersion(t *testing.T) {
+	versionTests := []struct {
+		version string
+		want    string
+		wantErr string
+	}{
+		{"v1alpha1", "profiles.fluxcd.io/v1alpha1", ""},
+		{"profiles.fluxcd.io/v1alpha2", "profiles.fluxcd.io/v1alpha2", ""},
+		{"v1", "", `unsupported apiVersion "profiles.fluxcd.io/v1", must be one of profiles.fluxcd.io/v1alpha1, profiles.fluxcd.io/v1alpha2`},
+	}
+
+	for _, tt := range versionTests {
+		t.Run(tt.version, func(t *testing.T) {
+			v, err := ResolveAPIVersion(tt.version)
+			if msg := errorString(err); msg != tt.wantErr {
+				t.Fatalf("ResolveAPIVersion() got error %q, want %q", msg, tt.wantErr)
+			}
+			if v != tt.want {
+				t.Fatalf("ResolveAPIVersion() got %q, want %q", v, tt.want)
+			}
+		})
+	}
+}
+
+func testVersionedProfile(apiVersion string) *Profile {
+	return &Profile{
+		TypeMeta:   metav1.TypeMeta{Kind: "Profile", APIVersion: apiVersion},
+		ObjectMeta: metav1.ObjectMeta{Name: "nginx"},
+		Spec: ProfileSpec{
+			Description: "Profile for deploying nginx",
+			Version:     "v0.0.1",
+			Artifacts: []Artifact{
+				{Name: "nginx-server", Path: "nginx/chart"},
+				{Name: "redis-server", Chart: &HelmChartSpec{Chart: "redis", Repository: testHelmRepoURL, Version: "12.10.0"}},
+				{Name: "policies", Kustomize: &KustomizeSpec{Path: "policies/base"}},
+			},
+		},
+	}
+}
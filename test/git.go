@@ -30,7 +30,7 @@ func GetFilesInCommit(t *testing.T, commit *object.Commit, base string) map[stri
 		files.ForEach(func(f *object.File) error {
 			b, err := os.ReadFile(f.Name)
 			if err != nil {
-				t.Fatalf("failed to read file %s: %w", f.Name, err)
+				t.Fatalf("failed to read file %s: %s", f.Name, err)
 			}
 			found[f.Name] = b
 			return nil
@@ -54,7 +54,7 @@ func GetFilesInCommit(t *testing.T, commit *object.Commit, base string) map[stri
 			if action == merkletrie.Insert {
 				b, err := os.ReadFile(filepath.Join(base, ch.To.Name))
 				if err != nil {
-					t.Fatalf("failed to read file %s: %w", ch.To.Name, err)
+					t.Fatalf("failed to read file %s: %s", ch.To.Name, err)
 				}
 				found[ch.To.Name] = b
 			}
@@ -8,7 +8,10 @@ import (
 
 	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-billy/v5/util"
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
 )
 
@@ -97,3 +100,67 @@ func MakeTempGitRepo(t *testing.T) (string, billy.Filesystem) {
 	}
 	return dir, osfs.New(dir)
 }
+
+// MakeBareRepository creates a bare git repository with a single commit on
+// the main branch containing the provided files, the commit is tagged with
+// each of the provided tags.
+//
+// It returns the directory of the bare repository and the SHA of the commit.
+//
+// The directories are deleted at the end of the test.
+func MakeBareRepository(t *testing.T, files map[string]string, tags ...string) (string, string) {
+	t.Helper()
+	r, err := git.PlainInit(MakeTempDir(t), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.NewBranchReferenceName("main"))); err != nil {
+		t.Fatal(err)
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := util.WriteFile(w.Filesystem, name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Add(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h, err := w.Commit("initial commit", &git.CommitOptions{
+		Author: &object.Signature{
+			Name:  "Testing",
+			Email: "test@example.com",
+			When:  time.Now(),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tag := range tags {
+		if _, err := r.CreateTag(tag, h, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bareDir := MakeTempDir(t)
+	bare, err := git.PlainInit(bareDir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bare.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{w.Filesystem.Root()}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bare.Fetch(&git.FetchOptions{
+		RemoteName: "origin",
+		RefSpecs:   []config.RefSpec{"refs/heads/*:refs/heads/*", "refs/tags/*:refs/tags/*"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bare.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.NewBranchReferenceName("main"))); err != nil {
+		t.Fatal(err)
+	}
+	return bareDir, h.String()
+}